@@ -0,0 +1,107 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
+)
+
+// fieldPaths caches reflected field index paths of metric containers by container type and namespace tail,
+// so metric values are extracted without walking struct tags on each collection
+var fieldPaths = struct {
+	sync.RWMutex
+	paths map[string][]int
+}{paths: map[string][]int{}}
+
+// getValueByNamespace returns value from container under given namespace tail (eg. volumes/count)
+// It returns nil when namespace does not point to any container field, optional fields are dereferenced
+// and nil when not set
+func getValueByNamespace(container interface{}, tail []string) interface{} {
+	path := fieldPath(reflect.TypeOf(container), tail)
+	if path == nil {
+		return nil
+	}
+	value := reflect.ValueOf(container).FieldByIndex(path)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	return value.Interface()
+}
+
+// knownNamespace checks if namespace of tenant or cloud-wide metric points to any container field, regardless
+// of its value being set, so namespaces which do not map to any metric (ex. mistyped in task) are told apart
+// from optional values not reported by cloud
+func knownNamespace(namespace []string, cloudNs string) bool {
+	switch {
+	case namespace[3] == cloudNs:
+		return fieldPath(reflect.TypeOf(cloudContainer{}), namespace[4:]) != nil
+	case len(namespace) == 8 && namespace[4] == "limits" && namespace[5] == "by_type":
+		return fieldPath(reflect.TypeOf(types.TypeLimits{}), namespace[7:]) != nil
+	}
+	return fieldPath(reflect.TypeOf(metricContainer{}), namespace[4:]) != nil
+}
+
+// fieldPath returns cached index path of field of given type matching namespace tail, nil when there is none
+func fieldPath(t reflect.Type, tail []string) []int {
+	key := t.String() + ":" + strings.Join(tail, "/")
+
+	fieldPaths.RLock()
+	path, found := fieldPaths.paths[key]
+	fieldPaths.RUnlock()
+
+	if !found {
+		path = resolveFieldPath(t, tail)
+		fieldPaths.Lock()
+		fieldPaths.paths[key] = path
+		fieldPaths.Unlock()
+	}
+	return path
+}
+
+// resolveFieldPath finds index path of field matching namespace tail, fields are matched by json tag only,
+// so fields without tag or tagged "-" (internal ones) are never reached by namespace
+func resolveFieldPath(t reflect.Type, tail []string) []int {
+	path := []int{}
+	for _, element := range tail {
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := strings.Split(field.Tag.Get("json"), ",")[0]
+			if tag != "" && tag != "-" && tag == element {
+				path = append(path, i)
+				t = field.Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	if t.Kind() == reflect.Struct {
+		return nil
+	}
+	return path
+}
@@ -0,0 +1,106 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/ns"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
+)
+
+func TestGetValueByNamespace(t *testing.T) {
+	Convey("Given metric container", t, func() {
+		container := metricContainer{
+			S: types.Snapshots{Count: 1, Bytes: 2},
+			V: types.Volumes{Count: 3, Bytes: 4},
+			L: types.Limits{MaxTotalVolumes: 5, MaxTotalVolumeGigabytes: 6},
+		}
+
+		Convey("When values are extracted by namespace", func() {
+			Convey("Then the same values as by namespace utilities are returned", func() {
+				for _, tail := range [][]string{
+					{"snapshots", "count"},
+					{"volumes", "bytes"},
+					{"limits", "MaxTotalVolumes"},
+				} {
+					So(getValueByNamespace(container, tail), ShouldEqual, ns.GetValueByNamespace(container, tail))
+				}
+			})
+
+			Convey("and nil is returned for unknown namespace", func() {
+				So(getValueByNamespace(container, []string{"volumes", "unknown"}), ShouldBeNil)
+				So(getValueByNamespace(container, []string{"volumes"}), ShouldBeNil)
+			})
+
+			Convey("and internal fields do not resolve by their name", func() {
+				container.V.Errors = 8
+				So(getValueByNamespace(container, []string{"volumes", "Errors"}), ShouldBeNil)
+				So(getValueByNamespace(container, []string{"limits", "ReservedQueried"}), ShouldBeNil)
+				So(getValueByNamespace(container, []string{"V", "count"}), ShouldBeNil)
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "demo", "limits", "GroupsQueried"}, "_cloud"), ShouldBeFalse)
+			})
+
+			Convey("and optional values are dereferenced or nil when not set", func() {
+				backups := 7
+				container.L.Backups = &backups
+				So(getValueByNamespace(container, []string{"limits", "backups"}), ShouldEqual, 7)
+				So(getValueByNamespace(container, []string{"limits", "backup_gigabytes"}), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestKnownNamespace(t *testing.T) {
+	Convey("Given namespaces of tenant and cloud-wide metrics", t, func() {
+		Convey("When namespaces point to container fields", func() {
+			Convey("Then those are known regardless of value being set", func() {
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "demo", "volumes", "count"}, "_cloud"), ShouldBeTrue)
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "demo", "limits", "backups"}, "_cloud"), ShouldBeTrue)
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "demo", "limits", "by_type", "ssd", "gigabytes"}, "_cloud"), ShouldBeTrue)
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "_cloud", "volumes", "total"}, "_cloud"), ShouldBeTrue)
+			})
+		})
+
+		Convey("When namespaces do not point to any field", func() {
+			Convey("Then those are unknown", func() {
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "demo", "volumes", "countt"}, "_cloud"), ShouldBeFalse)
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "demo", "volumes", "total"}, "_cloud"), ShouldBeFalse)
+				So(knownNamespace([]string{"intel", "openstack", "cinder", "_cloud", "volumes", "count"}, "_cloud"), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func BenchmarkGetValueByNamespaceReflection(b *testing.B) {
+	container := metricContainer{V: types.Volumes{Count: 3, Bytes: 4}}
+	tail := []string{"volumes", "bytes"}
+	for i := 0; i < b.N; i++ {
+		ns.GetValueByNamespace(container, tail)
+	}
+}
+
+func BenchmarkGetValueByNamespaceCached(b *testing.B) {
+	container := metricContainer{V: types.Volumes{Count: 3, Bytes: 4}}
+	tail := []string{"volumes", "bytes"}
+	for i := 0; i < b.N; i++ {
+		getValueByNamespace(container, tail)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
+)
+
+const (
+	// otherValue is breakdown value which values exceeding max_cardinality are bucketed into
+	otherValue = "_other"
+	// truncatedElement is last namespace element of flag telling whether breakdown exceeded max_cardinality
+	truncatedElement = "_truncated"
+)
+
+// capCounts keeps at most max values with highest counts, counts of remaining values are summed under otherValue
+// (see otherKey), so total is preserved. Counts are returned unchanged when max is not positive or is not exceeded
+func capCounts(counts map[string]uint, max int) (map[string]uint, bool) {
+	if max <= 0 || len(counts) <= max {
+		return counts, false
+	}
+	keys := sortedCountKeys(counts)
+	sort.Stable(keysByCount{keys, func(key string) uint { return counts[key] }})
+
+	other := otherKey(func(key string) bool { _, found := counts[key]; return found })
+	capped := make(map[string]uint, max+1)
+	for i, key := range keys {
+		if i < max {
+			capped[key] = counts[key]
+		} else {
+			capped[other] += counts[key]
+		}
+	}
+	return capped, true
+}
+
+// capGroups keeps at most max values with highest counts, remaining groups are merged under otherValue
+// (see otherKey), so totals are preserved. Groups are returned unchanged when max is not positive or is not exceeded
+func capGroups(groups map[string]types.Group, max int) (map[string]types.Group, bool) {
+	if max <= 0 || len(groups) <= max {
+		return groups, false
+	}
+	keys := sortedGroupKeys(groups)
+	sort.Stable(keysByCount{keys, func(key string) uint { return groups[key].Count }})
+
+	other := otherKey(func(key string) bool { _, found := groups[key]; return found })
+	capped := make(map[string]types.Group, max+1)
+	for i, key := range keys {
+		if i < max {
+			capped[key] = groups[key]
+		} else {
+			bucket := capped[other]
+			bucket.Count += groups[key].Count
+			bucket.Gigabytes += groups[key].Gigabytes
+			capped[other] = bucket
+		}
+	}
+	return capped, true
+}
+
+// otherKey returns value which capped values are bucketed into, otherValue unless breakdown has real value
+// of the same name, in which case numbered suffix is appended (eg. _other_1) so real value is not merged with bucket
+func otherKey(exists func(key string) bool) string {
+	key := otherValue
+	for i := 1; exists(key); i++ {
+		key = otherValue + "_" + strconv.Itoa(i)
+	}
+	return key
+}
+
+// keysByCount sorts breakdown values by descending count, sort.SliceStable is not available in Go versions
+// plugin is built with
+type keysByCount struct {
+	keys  []string
+	count func(key string) uint
+}
+
+func (s keysByCount) Len() int           { return len(s.keys) }
+func (s keysByCount) Swap(i, j int)      { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s keysByCount) Less(i, j int) bool { return s.count(s.keys[i]) > s.count(s.keys[j]) }
+
+// getCardinalityCaps returns cap of breakdown (eg. by_status of volumes) configured by max_cardinality_<family>_<breakdown>
+// (snapshots_by_metadata for all metadata keys), falling back to max_cardinality
+func getCardinalityCaps(cfg interface{}) func(family, breakdown string) int {
+	max := getConfigInt(cfg, "max_cardinality", 0)
+	return func(family, breakdown string) int {
+		return getConfigInt(cfg, "max_cardinality_"+family+"_"+breakdown, max)
+	}
+}
+
+// capBreakdowns applies cap of each breakdown to snapshot metadata counts (by tenant ID, key and value)
+// and groups (by family, tenant ID, field and value) in place, returned set holds breakdowns which were truncated
+// identified by breakdownKey
+func capBreakdowns(metadata map[string]map[string]map[string]uint, groups map[string]map[string]types.Groups, capOf func(family, breakdown string) int) map[string]bool {
+	truncated := map[string]bool{}
+	for tenantID, byKey := range metadata {
+		for key, counts := range byKey {
+			if capped, ok := capCounts(counts, capOf("snapshots", "by_metadata")); ok {
+				byKey[key] = capped
+				truncated[breakdownKey(tenantID, "snapshots", "by_metadata", key)] = true
+			}
+		}
+	}
+	for family, byTenant := range groups {
+		for tenantID, byField := range byTenant {
+			for field, values := range byField {
+				if capped, ok := capGroups(values, capOf(family, "by_"+field)); ok {
+					byField[field] = capped
+					truncated[breakdownKey(tenantID, family, "by_"+field)] = true
+				}
+			}
+		}
+	}
+	return truncated
+}
+
+// breakdownKey identifies breakdown of tenant by namespace elements following tenant, up to breakdown value
+func breakdownKey(tenantID string, elements ...string) string {
+	return strings.Join(append([]string{tenantID}, elements...), "/")
+}
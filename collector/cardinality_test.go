@@ -0,0 +1,136 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCapCounts(t *testing.T) {
+	Convey("Given counts by value", t, func() {
+		counts := map[string]uint{"a": 1, "b": 5, "c": 3, "d": 1}
+
+		Convey("When number of values does not exceed cap", func() {
+			capped, truncated := capCounts(counts, 4)
+
+			Convey("Then counts are kept as they are", func() {
+				So(truncated, ShouldBeFalse)
+				So(capped, ShouldResemble, counts)
+			})
+		})
+
+		Convey("When cap is not set", func() {
+			capped, truncated := capCounts(counts, 0)
+
+			Convey("Then counts are kept as they are", func() {
+				So(truncated, ShouldBeFalse)
+				So(capped, ShouldResemble, counts)
+			})
+		})
+
+		Convey("When number of values exceeds cap", func() {
+			capped, truncated := capCounts(counts, 2)
+
+			Convey("Then values with highest counts are kept and the rest is bucketed preserving total", func() {
+				So(truncated, ShouldBeTrue)
+				So(capped, ShouldResemble, map[string]uint{"b": 5, "c": 3, otherValue: 2})
+			})
+		})
+
+		Convey("When real value collides with bucket of capped values", func() {
+			counts[otherValue] = 4
+			capped, truncated := capCounts(counts, 2)
+
+			Convey("Then real value is kept apart from bucket", func() {
+				So(truncated, ShouldBeTrue)
+				So(capped, ShouldResemble, map[string]uint{"b": 5, otherValue: 4, otherValue + "_1": 5})
+			})
+		})
+	})
+}
+
+func TestCapGroups(t *testing.T) {
+	Convey("Given groups by value", t, func() {
+		groups := map[string]types.Group{
+			"available": {Count: 4, Gigabytes: 40},
+			"error":     {Count: 1, Gigabytes: 5},
+			"in-use":    {Count: 2, Gigabytes: 30},
+		}
+
+		Convey("When number of values exceeds cap", func() {
+			capped, truncated := capGroups(groups, 1)
+
+			Convey("Then largest group is kept and the rest is merged preserving totals", func() {
+				So(truncated, ShouldBeTrue)
+				So(capped, ShouldResemble, map[string]types.Group{
+					"available": {Count: 4, Gigabytes: 40},
+					otherValue:  {Count: 3, Gigabytes: 35},
+				})
+			})
+		})
+
+		Convey("When real values collide with bucket of capped groups", func() {
+			groups[otherValue] = types.Group{Count: 1, Gigabytes: 1}
+			groups[otherValue+"_1"] = types.Group{Count: 1, Gigabytes: 2}
+			capped, truncated := capGroups(groups, 1)
+
+			Convey("Then bucket gets name not used by any real value", func() {
+				So(truncated, ShouldBeTrue)
+				So(capped, ShouldResemble, map[string]types.Group{
+					"available":       {Count: 4, Gigabytes: 40},
+					otherValue + "_2": {Count: 5, Gigabytes: 38},
+				})
+			})
+		})
+	})
+}
+
+func TestCapBreakdowns(t *testing.T) {
+	Convey("Given groups of two breakdowns with different caps", t, func() {
+		byValue := func() map[string]types.Group {
+			return map[string]types.Group{
+				"a": {Count: 3, Gigabytes: 30},
+				"b": {Count: 2, Gigabytes: 20},
+				"c": {Count: 1, Gigabytes: 10},
+			}
+		}
+		groups := map[string]map[string]types.Groups{
+			"volumes":   {"demo_id123": {"status": byValue()}},
+			"snapshots": {"demo_id123": {"status": byValue()}},
+		}
+		caps := map[string]int{"volumes/by_status": 1, "snapshots/by_status": 2}
+		capOf := func(family, breakdown string) int { return caps[family+"/"+breakdown] }
+
+		Convey("When breakdowns are capped", func() {
+			truncated := capBreakdowns(nil, groups, capOf)
+
+			Convey("Then each breakdown is capped by its own cap", func() {
+				So(truncated, ShouldResemble, map[string]bool{
+					"demo_id123/volumes/by_status":   true,
+					"demo_id123/snapshots/by_status": true,
+				})
+				So(groups["volumes"]["demo_id123"]["status"], ShouldHaveLength, 2)
+				So(groups["snapshots"]["demo_id123"]["status"], ShouldHaveLength, 3)
+				So(groups["snapshots"]["demo_id123"]["status"][otherValue], ShouldResemble, types.Group{Count: 1, Gigabytes: 10})
+			})
+		})
+	})
+}
@@ -15,9 +15,20 @@ limitations under the License.
 package collector
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rackspace/gophercloud"
@@ -35,12 +46,65 @@ import (
 	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
 )
 
+// Plugin identity reported by Meta, vendored builds may override it before plugin is started
+var (
+	Name    = "cinder"
+	Version = 3
+)
+
+// Plugin loading options reported by Meta, deployments may tune them before plugin is started.
+// Caches of tenants, limits and snapshots are kept per plugin instance, so routing other than sticky makes
+// tasks hit instances with cold caches; ConcurrencyCount of zero keeps snap default.
+var (
+	RoutingStrategy  = plugin.StickyRouting
+	ConcurrencyCount = 0
+)
+
 const (
+	// name is namespace element of plugin metrics, it does not follow reported plugin name
 	name    = "cinder"
-	version = 3
 	plgtype = plugin.CollectorPluginType
 	vendor  = "intel"
 	fs      = "openstack"
+
+	// defaultResyncInterval is default time in seconds between full snapshot listings in incremental mode
+	defaultResyncInterval = 3600
+
+	// systemScopeKey identifies system scoped provider, it does not clash with tenant names as those are namespace elements
+	// and cannot contain slash
+	systemScopeKey = "/system"
+
+	// default HTTP transport timeouts in seconds
+	defaultDialTimeout           = 10
+	defaultTLSHandshakeTimeout   = 10
+	defaultResponseHeaderTimeout = 60
+	defaultIdleConnTimeout       = 90
+
+	// defaultCloudNamespace is default namespace element, in place of tenant name, of cloud-wide metrics
+	defaultCloudNamespace = "_cloud"
+
+	// defaultAllTenantsMinTenants is number of distinct tenants which volumes have to be listed with admin scope
+	// for all tenants visibility to be considered working
+	defaultAllTenantsMinTenants = 2
+
+	// collection scopes of volumes and snapshots: single listing of all tenants with admin scope,
+	// listing of each tenant with token scoped to it, or listing of each project of domain enumerated
+	// with domain scoped token
+	allTenantsScope = "all_tenants"
+	perTenantScope  = "per_tenant"
+	domainScope     = "domain"
+
+	// defaultSizeBuckets are default upper bounds in GB of volume size buckets: 0-10GB, 10-100GB, 100GB-1TB and >1TB
+	defaultSizeBuckets = "10,100,1024"
+
+	// projectIDPlaceholder is replaced with ID of scoped project in configured Cinder endpoint
+	projectIDPlaceholder = "%(project_id)s"
+
+	// defaultMaxPageSize is default maximal number of items returned by Cinder in single response (osapi_max_limit)
+	defaultMaxPageSize = 1000
+
+	// defaultFilteredBatchSize is default number of tenants listed at once by project ID when tenants are filtered by name
+	defaultFilteredBatchSize = 10
 )
 
 // New creates initialized instance of Cinder collector
@@ -48,69 +112,558 @@ func New() *collector {
 	providers := map[string]*gophercloud.ProviderClient{}
 	allTenants := map[string]string{}
 	allLimits := map[string]types.Limits{}
+	allTypeLimits := map[string]map[string]types.TypeLimits{}
+	lastValues := map[string]interface{}{}
 	return &collector{
-		allTenants: allTenants,
-		providers:  providers,
-		allLimits:  allLimits,
+		allTenants:    allTenants,
+		common:        openstackintel.Common{},
+		providers:     providers,
+		authCalls:     map[string]*authCall{},
+		services:      map[string]services.Service{},
+		allLimits:     allLimits,
+		allTypeLimits: allTypeLimits,
+		limitsFetched: map[string]time.Time{},
+		limitsETags:   map[string]string{},
+		snapshotIndex: types.NewSnapshotIndex(),
+		lastValues:    lastValues,
+		lastCounts:    map[string]uint{},
+		keystoneTimer: &apiTimer{},
+		cinderTimer:   &apiTimer{},
+		apiClock:      &apiClock{},
+		apiCalls:      services.NewCallCounter(),
 	}
 }
 
 // GetMetricTypes returns list of available metric types
 // It returns error in case retrieval was not successful
 func (c *collector) GetMetricTypes(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
-	mts := []plugin.MetricType{}
-
+	c.collectMutex.Lock()
+	defer c.collectMutex.Unlock()
 	var err error
 	c.allTenants, err = getTenants(cfg)
 	if err != nil {
 		return nil, err
 	}
+	checkTenantsVisibility(cfg, c.allTenants)
+	// domains of tenants are listed only when domain element of namespace is enabled
+	if c.tenantDomains, err = getTenantDomains(cfg); err != nil {
+		return nil, err
+	}
 
-	// Generate available namespace for limits
-	namespaces := []string{}
+	// Cloud-wide metrics are not scoped to any tenant, their namespace element must not clash with tenant names
+	cloudNs := getConfigString(cfg, "cloud_namespace", defaultCloudNamespace)
 	for _, tenantName := range c.allTenants {
-		// Construct temporary struct to generate namespace based on tags
-		var metrics struct {
-			S types.Snapshots `json:"snapshots"`
-			V types.Volumes   `json:"volumes"`
-			L types.Limits    `json:"limits"`
+		if tenantName == cloudNs {
+			return nil, fmt.Errorf("Cloud namespace %s clashes with tenant name, set different cloud_namespace", cloudNs)
 		}
-		current := strings.Join([]string{vendor, fs, name, tenantName}, "/")
-		ns.FromCompositionTags(metrics, current, &namespaces)
 	}
 
-	for _, namespace := range namespaces {
+	layout, err := getNamespaceLayout(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mts := buildMetricTypes(c.allTenants, cloudNs, cfg)
+	// tenants and descriptive elements are tags in flat layout, metric types of all of them share namespace
+	if layout == flatLayout {
+		mts = flattenMetricTypes(mts)
+	}
+	// domain of tenant precedes tenant element in nested layout when domains are known
+	if c.tenantDomains != nil {
+		domains := domainsByTenantName(c.allTenants, c.tenantDomains)
+		for i := range mts {
+			mts[i].Namespace_ = insertDomain(mts[i].Namespace(), domains, cloudNs)
+		}
+	}
+
+	// tenant names and configured names may be sanitized for Prometheus, clashing tenants are rejected
+	if getConfigBool(cfg, "sanitize_namespace", false) {
+		if _, err := newSanitizer(c.allTenants, cfg); err != nil {
+			return nil, err
+		}
+		for i := range mts {
+			mts[i].Namespace_ = sanitizeNamespace(mts[i].Namespace())
+		}
+	}
+
+	return mts, nil
+}
+
+// buildMetricTypes generates available metric types for given tenants and cloud-wide metrics, ordered by tenant name
+// and namespace. Namespace suffixes are tenant independent, so they are generated from container tags only once
+func buildMetricTypes(tenants map[string]string, cloudNs string, cfg plugin.ConfigType) []plugin.MetricType {
+	var metrics metricContainer
+	var cloud cloudContainer
+	tenantSuffixes := compositionSuffixes(metrics)
+	cloudSuffixes := compositionSuffixes(cloud)
+
+	// extra volume fields are configured by user and available under volumes/extra
+	for _, extra := range sortedKeys(getExtraVolumeFields(cfg)) {
+		tenantSuffixes = append(tenantSuffixes, []string{"volumes", "extra", extra})
+	}
+	// quotas of volume types are configured by user and available under limits/by_type
+	var typeLimits types.TypeLimits
+	for _, volumeType := range getQuotaVolumeTypes(cfg) {
+		for _, suffix := range compositionSuffixes(typeLimits) {
+			tenantSuffixes = append(tenantSuffixes, append([]string{"limits", "by_type", volumeType}, suffix...))
+		}
+	}
+
+	mts := make([]plugin.MetricType, 0, len(tenants)*len(tenantSuffixes)+len(cloudSuffixes))
+	appendTypes := func(element string, suffixes [][]string) {
+		for _, suffix := range suffixes {
+			elements := make([]string, 0, 4+len(suffix))
+			elements = append(elements, vendor, fs, name, element)
+			elements = append(elements, suffix...)
+			mts = append(mts, plugin.MetricType{
+				Namespace_: core.NewNamespace(elements...),
+				Config_:    cfg.ConfigDataNode,
+			})
+		}
+	}
+
+	for _, tenantName := range tenants {
+		appendTypes(tenantName, tenantSuffixes)
+	}
+	appendTypes(cloudNs, cloudSuffixes)
+
+	// QoS specs are not known in advance, number of associations of each spec is dynamic element under qos_specs
+	mts = append(mts, plugin.MetricType{
+		Namespace_: core.NewNamespace(vendor, fs, name, cloudNs, "qos_specs").
+			AddDynamicElement("spec", "QoS spec name").
+			AddStaticElement("associations"),
+		Config_: cfg.ConfigDataNode,
+	})
+	// back-end hosts are not known in advance, number of snapshots of volumes on each host is dynamic element under hosts
+	mts = append(mts, plugin.MetricType{
+		Namespace_: core.NewNamespace(vendor, fs, name, cloudNs, "hosts").
+			AddDynamicElement("host", "back-end host").
+			AddStaticElement("snapshot_count"),
+		Config_: cfg.ConfigDataNode,
+	})
+	// pools are not known in advance either, overcommit ratio of each pool is dynamic element under pools
+	mts = append(mts, plugin.MetricType{
+		Namespace_: core.NewNamespace(vendor, fs, name, cloudNs, "pools").
+			AddDynamicElement("pool", "back-end storage pool name").
+			AddStaticElement("overcommit_ratio"),
+		Config_: cfg.ConfigDataNode,
+	})
+
+	// Cinder services are not known in advance either, age of heartbeat of each binary on each host is dynamic
+	// element under services
+	mts = append(mts, plugin.MetricType{
+		Namespace_: core.NewNamespace(vendor, fs, name, cloudNs, "services").
+			AddDynamicElement("host", "Cinder service host").
+			AddDynamicElement("binary", "Cinder service binary").
+			AddStaticElement("heartbeat_age_seconds"),
+		Config_: cfg.ConfigDataNode,
+	})
+
+	// extra spec values are not known in advance either, those are dynamic element under volume_types/by_extraspec/<key>
+	for _, key := range getConfigList(cfg, "group_types_by_extra_spec") {
 		mts = append(mts, plugin.MetricType{
-			Namespace_: core.NewNamespace(strings.Split(namespace, "/")...),
-			Config_:    cfg.ConfigDataNode,
+			Namespace_: core.NewNamespace(vendor, fs, name, cloudNs, "volume_types", "by_extraspec", key).
+				AddDynamicElement("value", "extra spec value").
+				AddStaticElement("count"),
+			Config_: cfg.ConfigDataNode,
 		})
 	}
 
-	return mts, nil
+	// snapshot metadata values are not known in advance, those are dynamic element under snapshots/by_metadata/<key>
+	for _, tenantName := range tenants {
+		for _, key := range getConfigList(cfg, "group_by_snapshot_metadata") {
+			mts = append(mts, plugin.MetricType{
+				Namespace_: core.NewNamespace(vendor, fs, name, tenantName, "snapshots", "by_metadata", key).
+					AddDynamicElement("value", "snapshot metadata value").
+					AddStaticElement("count"),
+				Config_: cfg.ConfigDataNode,
+			})
+		}
+	}
+
+	// volumes and snapshots are grouped by values of configured fields, those are dynamic element under by_<field>,
+	// generic volume groups are always grouped by status
+	var group types.Group
+	groupSuffixes := compositionSuffixes(group)
+	// breakdowns capped by max_cardinality (or cap of breakdown) report whether their values were truncated
+	capOf := getCardinalityCaps(cfg)
+	breakdowns := [][]string{}
+	if capOf("groups", "by_status") > 0 {
+		breakdowns = append(breakdowns, []string{"groups", "by_status"})
+	}
+	if capOf("snapshots", "by_metadata") > 0 {
+		for _, key := range getConfigList(cfg, "group_by_snapshot_metadata") {
+			breakdowns = append(breakdowns, []string{"snapshots", "by_metadata", key})
+		}
+	}
+	for _, family := range []string{"volumes", "snapshots"} {
+		for _, field := range getGroupFields(cfg, family) {
+			if capOf(family, "by_"+field) > 0 {
+				breakdowns = append(breakdowns, []string{family, "by_" + field})
+			}
+		}
+	}
+	if len(breakdowns) > 0 {
+		suffixes := make([][]string, 0, len(breakdowns))
+		for _, breakdown := range breakdowns {
+			suffixes = append(suffixes, append(breakdown, truncatedElement))
+		}
+		for _, tenantName := range tenants {
+			appendTypes(tenantName, suffixes)
+		}
+	}
+
+	for _, tenantName := range tenants {
+		mts = append(mts, plugin.MetricType{
+			Namespace_: core.NewNamespace(vendor, fs, name, tenantName, "groups", "by_status").
+				AddDynamicElement("value", "status value").
+				AddStaticElement("count"),
+			Config_: cfg.ConfigDataNode,
+		})
+		for _, family := range []string{"volumes", "snapshots"} {
+			for _, field := range getGroupFields(cfg, family) {
+				for _, suffix := range groupSuffixes {
+					mts = append(mts, plugin.MetricType{
+						Namespace_: core.NewNamespace(vendor, fs, name, tenantName, family, "by_"+field).
+							AddDynamicElement("value", field+" value").
+							AddStaticElement(suffix[0]),
+						Config_: cfg.ConfigDataNode,
+					})
+				}
+			}
+		}
+	}
+
+	// tenants are iterated in map order, catalog is sorted so it is the same across calls
+	sort.Stable(metricTypesBy{mts, func(a, b plugin.MetricType) bool {
+		if a.Namespace()[3].Value != b.Namespace()[3].Value {
+			return a.Namespace()[3].Value < b.Namespace()[3].Value
+		}
+		return a.Namespace().String() < b.Namespace().String()
+	}})
+	return mts
+}
+
+// metricTypesBy sorts metric types by given less function, sort.SliceStable is not available in Go versions
+// plugin is built with
+type metricTypesBy struct {
+	mts  []plugin.MetricType
+	less func(a, b plugin.MetricType) bool
+}
+
+func (s metricTypesBy) Len() int           { return len(s.mts) }
+func (s metricTypesBy) Swap(i, j int)      { s.mts[i], s.mts[j] = s.mts[j], s.mts[i] }
+func (s metricTypesBy) Less(i, j int) bool { return s.less(s.mts[i], s.mts[j]) }
+
+// compositionSuffixes returns namespace elements of container fields based on json tags, fields tagged "-"
+// carry collection state and are not metrics
+func compositionSuffixes(container interface{}) [][]string {
+	namespaces := []string{}
+	ns.FromCompositionTags(container, "", &namespaces)
+
+	suffixes := make([][]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		suffix := strings.Split(strings.TrimPrefix(namespace, "/"), "/")
+		if suffix[len(suffix)-1] == "-" {
+			continue
+		}
+		suffixes = append(suffixes, suffix)
+	}
+	return suffixes
 }
 
-// CollectMetrics returns list of requested metric values
+// CollectMetrics returns list of requested metric values, passed to MetricTransform when it is set
 // It returns error in case retrieval was not successful
 func (c *collector) CollectMetrics(metricTypes []plugin.MetricType) ([]plugin.MetricType, error) {
-	// get admin tenant from configuration. admin tenant is needed for gathering volumes and snapshots metrics at once
-	item, err := config.GetConfigItem(metricTypes[0], "tenant")
+	layout, err := getNamespaceLayout(metricTypes[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// snap may call concurrently when ConcurrencyCount is raised, collections share state of collector
+	// so those are serialized
+	var metrics []plugin.MetricType
+	c.collectMutex.Lock()
+	if layout == flatLayout {
+		metrics, err = c.collectFlat(metricTypes)
+	} else {
+		metrics, err = c.collectNested(metricTypes)
+	}
+	c.collectMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// metrics are post-processed by embedding plugin after all collection and emission, right before returning
+	if c.MetricTransform != nil {
+		metrics = c.MetricTransform(metrics)
+	}
+	return metrics, nil
+}
+
+// collectNested collects metric types requested in nested layout, when domains of tenants are known and included
+// in namespaces, domain element is removed from requested namespaces and inserted into emitted ones
+func (c *collector) collectNested(metricTypes []plugin.MetricType) ([]plugin.MetricType, error) {
+	cfg := plugin.ConfigType{ConfigDataNode: metricTypes[0].Config()}
+	// domains are kept once listed, the same way as tenants
+	var domains map[string]string
+	if getConfigBool(cfg, "include_domain_namespace", false) {
+		if c.tenantDomains == nil {
+			var err error
+			if c.tenantDomains, err = getTenantDomains(cfg); err != nil {
+				return nil, err
+			}
+		}
+		domains = c.tenantDomains
+	}
+	if domains == nil {
+		collected, err := c.collect(metricTypes)
+		if err != nil {
+			return nil, err
+		}
+		return c.emitMetrics(collected), nil
+	}
+
+	if len(c.allTenants) == 0 {
+		tenants, err := getTenants(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.allTenants = tenants
+	}
+	cloudNs := getConfigString(cfg, "cloud_namespace", defaultCloudNamespace)
+	requested := make([]plugin.MetricType, len(metricTypes))
+	for i, metricType := range metricTypes {
+		namespace, err := removeDomain(metricType.Namespace(), cloudNs)
+		if err != nil {
+			return nil, err
+		}
+		requested[i] = metricType
+		requested[i].Namespace_ = namespace
+	}
+	byName := domainsByTenantName(c.allTenants, domains)
+
+	collected, err := c.collect(requested)
+	if err != nil {
+		return nil, err
+	}
+	collected.domains = byName
+	return c.emitMetrics(collected), nil
+}
+
+// collectFlat collects metric types requested in flat layout, those are resolved to nested metric types of all
+// tenants and emitted metrics are flattened back
+func (c *collector) collectFlat(metricTypes []plugin.MetricType) ([]plugin.MetricType, error) {
+	cfg := plugin.ConfigType{ConfigDataNode: metricTypes[0].Config()}
+	if len(c.allTenants) == 0 {
+		tenants, err := getTenants(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.allTenants = tenants
+	}
+
+	cloudNs := getConfigString(cfg, "cloud_namespace", defaultCloudNamespace)
+	catalog := buildMetricTypes(c.allTenants, cloudNs, cfg)
+	nested := nestMetricTypes(metricTypes, catalog, getConfigBool(cfg, "sanitize_namespace", false))
+	if len(nested) == 0 {
+		return []plugin.MetricType{}, nil
+	}
+
+	collected, err := c.collect(nested)
+	if err != nil {
+		return nil, err
+	}
+	return flattenMetrics(c.emitMetrics(collected)), nil
+}
+
+// CollectRaw collects volumes, snapshots and limits of all tenants and returns them by tenant name, without wrapping
+// them into metrics, so the collection can be embedded in other plugins. Tenants which family failed to be collected
+// in best-effort mode are left out of its map
+func (c *collector) CollectRaw(cfg plugin.ConfigType) (map[string]types.Volumes, map[string]types.Snapshots, map[string]types.Limits, error) {
+	c.collectMutex.Lock()
+	defer c.collectMutex.Unlock()
+	volumes, snapshots, limits := map[string]types.Volumes{}, map[string]types.Snapshots{}, map[string]types.Limits{}
+
+	if len(c.allTenants) == 0 {
+		tenants, err := getTenants(cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		c.allTenants = tenants
+	}
+	if len(c.allTenants) == 0 {
+		return volumes, snapshots, limits, nil
+	}
+
+	// raw collection is requested the same way as metrics, with one metric type per family of each tenant
+	requested := map[string]string{"volumes": "count", "snapshots": "count", "limits": "MaxTotalVolumes"}
+	metricTypes := make([]plugin.MetricType, 0, len(c.allTenants)*len(requested))
+	for _, tenantName := range c.allTenants {
+		for family, metric := range requested {
+			metricTypes = append(metricTypes, plugin.MetricType{
+				Namespace_: core.NewNamespace(vendor, fs, name, tenantName, family, metric),
+				Config_:    cfg.ConfigDataNode,
+			})
+		}
+	}
+
+	collected, err := c.collect(metricTypes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for tenantName, container := range collected.tenants {
+		if !collected.failed.has(tenantName, "volumes") {
+			volumes[tenantName] = container.V
+		}
+		if !collected.failed.has(tenantName, "snapshots") {
+			snapshots[tenantName] = container.S
+		}
+		if !collected.failed.has(tenantName, "limits") {
+			limits[tenantName] = container.L
+		}
+	}
+	return volumes, snapshots, limits, nil
+}
+
+// collection holds data gathered in single collection, metrics are emitted from it
+// metricTypes - requested metric types, sanitized namespaces are restored to original ones
+// version - Cinder version resolved by service of service_project
+// tenants - containers of requested tenants by tenant name
+// extraVolumes, snapshotMetadata - sums of extra volume fields and snapshot metadata counts by tenant ID
+// groups - volumes, snapshots and generic volume groups breakdowns by family and tenant ID
+// qosAssociations - number of associations by QoS spec name
+// typesByExtraSpec - volume type counts by extra spec key and value, nil when not collected
+// poolOvercommit - overcommit ratio by pool name
+// heartbeats - age in seconds of last heartbeat of Cinder services by host and binary, nil when not collected
+// snapshotHosts - snapshot counts by back-end host of their source volume, nil when not collected
+// truncated - breakdowns capped by max_cardinality, identified by breakdownKey
+// domains - domain IDs by tenant name inserted before tenant element of emitted namespaces, nil when not included
+// apiTimestamps - metrics are timestamped with time API call returning their data returned instead of emission time
+type collection struct {
+	metricTypes      []plugin.MetricType
+	version          string
+	failed           *failures
+	sanitize         bool
+	cloudNs          string
+	tenants          map[string]metricContainer
+	cloud            cloudContainer
+	extraVolumes     map[string]map[string]float64
+	snapshotMetadata map[string]map[string]map[string]uint
+	groups           map[string]map[string]types.Groups
+	qosAssociations  map[string]uint
+	typesByExtraSpec map[string]map[string]uint
+	poolOvercommit   map[string]float64
+	heartbeats       map[string]map[string]int
+	snapshotHosts    map[string]uint
+	truncated        map[string]bool
+	domains          map[string]string
+	apiTimestamps    bool
+}
+
+// collect gathers data needed by requested metric types, only families requested are collected
+func (c *collector) collect(metricTypes []plugin.MetricType) (*collection, error) {
+	projects := getProjects(metricTypes[0])
+	scope, err := getCollectionScope(metricTypes[0])
+	if err != nil {
+		return nil, err
+	}
+	timestampSource, err := getTimestampSource(metricTypes[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// errors of single family or tenant abort whole collection unless best-effort mode is configured
+	failed, err := newFailures(metricTypes[0])
 	if err != nil {
 		return nil, err
 	}
-	admin := item.(string)
+
+	// time spent in identity and block storage calls is measured separately per collection
+	// time API calls returned is recorded per collection as well
+	c.keystoneTimer, c.cinderTimer = &apiTimer{}, &apiTimer{}
+	c.apiClock = &apiClock{}
+	c.apiCalls.Reset()
+	atomic.StoreUint32(&c.catalogMissing, 0)
+
+	// endpoint or credentials changed since previous collection, state gathered with previous ones is dropped
+	if err := c.checkAuthChange(metricTypes[0]); err != nil {
+		return nil, err
+	}
+
+	// reachability of endpoints is probed before collection, so it does not depend on collection succeeding,
+	// collection is skipped when only reachability is requested
+	cloudNs := getConfigString(metricTypes[0], "cloud_namespace", defaultCloudNamespace)
+	probes := 0
+	for _, metricType := range metricTypes {
+		if isProbe(metricType.Namespace().Strings(), cloudNs) {
+			probes++
+		}
+	}
+	var probed types.Meta
+	if probes > 0 {
+		c.probeEndpoints(metricTypes[0], &probed)
+	}
+	if probes == len(metricTypes) {
+		return &collection{
+			metricTypes: metricTypes,
+			failed:      failed,
+			sanitize:    getConfigBool(metricTypes[0], "sanitize_namespace", false),
+			cloudNs:     cloudNs,
+			cloud:       cloudContainer{M: probed},
+		}, nil
+	}
+
+	// get admin tenant from configuration. admin tenant is needed for gathering volumes and snapshots metrics at once,
+	// project granting all tenants visibility may differ from identity project, system scoped token is used instead
+	// when configured. It is not needed when collecting from configured projects or each tenant separately.
+	// When tenant is not configured, it may be looked up as project where user holds configured role.
+	admin := systemScopeKey
+	if getConfigString(metricTypes[0], "system_scope", "") == "" && len(projects) == 0 && scope == allTenantsScope {
+		if admin, err = c.getAdminTenant(metricTypes[0]); err != nil {
+			return nil, err
+		}
+	}
 
 	// populate information about all available tenants
 	if len(c.allTenants) == 0 {
+		start := time.Now()
 		c.allTenants, err = getTenants(metricTypes[0])
+		c.keystoneTimer.since(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// in per tenant and domain scopes every tenant is collected with token scoped to it, the same way as
+	// configured projects
+	if (scope == perTenantScope || scope == domainScope) && len(projects) == 0 {
+		for _, tenantName := range c.allTenants {
+			projects = append(projects, tenantName)
+		}
+		sort.Strings(projects)
+	}
+
+	// requested namespaces are sanitized when sanitization is enabled, those are resolved to original ones
+	// and sanitized again when metrics are emitted
+	sanitize := getConfigBool(metricTypes[0], "sanitize_namespace", false)
+	if sanitize {
+		resolver, err := newSanitizer(c.allTenants, metricTypes[0])
 		if err != nil {
 			return nil, err
 		}
+		restored := make([]plugin.MetricType, len(metricTypes))
+		for i, metricType := range metricTypes {
+			restored[i] = metricType
+			restored[i].Namespace_ = resolver.restore(metricType.Namespace())
+		}
+		metricTypes = restored
 	}
 
 	// iterate over metric types to resolve needed collection calls
-	// for requested tenants
+	// for requested tenants, cloud-wide metrics are resolved separately
 	collectTenants := str.InitSet()
-	var collectLimits, collectVolumes, collectSnapshots bool
+	var collectLimits, collectVolumes, collectSnapshots, collectOrphaned, collectCloud, collectQuotaRollup, collectDefaultQuota, collectVolumeTypes, collectVolumeGroups, collectQoSSpecs, collectPools, collectVisibility, collectRuntime, collectReserved, collectGroupsQuota, collectHosts, collectDeleted, collectExtraSpecs, collectServices bool
+	onlyDeletingVolumes := true
 	for _, metricType := range metricTypes {
 		namespace := metricType.Namespace()
 		if len(namespace) < 6 {
@@ -118,61 +671,211 @@ func (c *collector) CollectMetrics(metricTypes []plugin.MetricType) ([]plugin.Me
 		}
 
 		tenant := namespace[3].Value
+		if tenant == cloudNs {
+			// cloud-wide rollups need given family of all tenants
+			switch namespace[4].Value {
+			case "volumes":
+				collectVolumes, collectCloud = true, true
+				onlyDeletingVolumes = false
+			case "snapshots":
+				collectSnapshots, collectCloud = true, true
+			case "tenants":
+				if namespace[5].Value == "over_quota" {
+					collectLimits, collectQuotaRollup = true, true
+				}
+			case "default_quota":
+				collectDefaultQuota = true
+			case "qos_specs":
+				collectQoSSpecs = true
+			case "volume_types":
+				if namespace[5].Value == "by_extraspec" {
+					collectExtraSpecs = true
+				}
+			case "pools":
+				collectPools = true
+			case "services":
+				collectServices = true
+			case "hosts":
+				// snapshots are counted by host of their source volume, listed before snapshots
+				collectVolumes, collectSnapshots, collectHosts = true, true, true
+				onlyDeletingVolumes = false
+			case "meta":
+				// visibility check needs volumes of all tenants listed with admin scope
+				if namespace[5].Value == "all_tenants_ok" {
+					collectVolumes, collectVisibility = true, true
+					onlyDeletingVolumes = false
+				}
+				// reading memory statistics stops the world, so runtime of plugin is measured only when requested
+				if namespace[5].Value == "goroutines" || namespace[5].Value == "heap_bytes" {
+					collectRuntime = true
+				}
+			}
+			continue
+		}
 		collectTenants.Add(tenant)
 
-		if str.Contains(namespace.Strings(), "limits") {
+		// each family is collected by separate API calls, only families requested are collected
+		switch namespace[4].Value {
+		case "limits":
 			collectLimits = true
-		} else if str.Contains(namespace.Strings(), "volumes") {
+			if strings.HasSuffix(namespace[5].Value, "_reserved") {
+				collectReserved = true
+			}
+			if strings.HasPrefix(namespace[5].Value, "groups") {
+				collectGroupsQuota = true
+			}
+		case "volumes":
 			collectVolumes = true
-		} else {
+			if namespace[len(namespace)-1].Value != "deleting" {
+				onlyDeletingVolumes = false
+			}
+			if namespace[len(namespace)-1].Value == "deleted" {
+				collectDeleted = true
+			}
+		case "snapshots":
 			collectSnapshots = true
+			if namespace[len(namespace)-1].Value == "deleted" {
+				collectDeleted = true
+			}
+			// orphaned snapshots and snapshots per volume are derived from volumes too
+			if last := namespace[len(namespace)-1].Value; last == "orphaned" || last == "max_snapshots_per_volume" {
+				collectOrphaned = true
+			}
+		case "volume_types":
+			collectVolumeTypes = true
+		case "groups":
+			collectVolumeGroups = true
 		}
 	}
 
+	// orphaned snapshots are found by correlating snapshots with all volumes listed before
+	if collectOrphaned {
+		collectVolumes = true
+		onlyDeletingVolumes = false
+	}
+	request := collectRequest{
+		volumes:             collectVolumes,
+		snapshots:           collectSnapshots,
+		orphaned:            collectOrphaned,
+		hosts:               collectHosts,
+		onlyDeletingVolumes: onlyDeletingVolumes,
+	}
+
+	// volumes and snapshots are keyed by tenant ID (project name in projects mode) and resolved to namespace
+	// element when metrics are emitted, so tenants sharing name are not mixed
 	allSnapshots := map[string]types.Snapshots{}
 	allVolumes := map[string]types.Volumes{}
+	cloud := cloudContainer{
+		T: types.Tenants{Count: uint(len(c.allTenants))},
+	}
 
-	// collect volumes and snapshots separately by authenticating to admin
-	{
-		if err := c.authenticate(metricTypes[0], admin); err != nil {
-			return nil, err
-		}
-		provider := c.providers[admin]
+	listOpts := types.ListOptions{
+		AllTenants:         getConfigBool(metricTypes[0], "all_tenants", true),
+		ManagedMetadataKey: getConfigString(metricTypes[0], "managed_volume_metadata_key", ""),
+		ExtraVolumeFields:  getExtraVolumeFields(metricTypes[0]),
 
-		var done sync.WaitGroup
-		errChn := make(chan error, 2)
+		SnapshotMetadataKeys: getConfigList(metricTypes[0], "group_by_snapshot_metadata"),
+		GroupVolumesBy:       getGroupFields(metricTypes[0], "volumes"),
+		SizeBuckets:          getSizeBuckets(metricTypes[0]),
+		GroupSnapshotsBy:     getGroupFields(metricTypes[0], "snapshots"),
 
-		// Collect volumes
-		if collectVolumes {
-			done.Add(1)
-			go func() {
-				defer done.Done()
-				volumes, err := c.service.GetVolumes(provider)
+		PageSize: getPageSize(metricTypes[0]),
 
-				if err != nil {
-					errChn <- err
-				}
-				for tenantId, volumeCount := range volumes {
-					tenantName := c.allTenants[tenantId]
-					allVolumes[tenantName] = volumeCount
-				}
-			}()
+		// deleted volumes and snapshots are listed by additional requests, only when enabled and requested
+		IncludeDeleted: collectDeleted && getConfigBool(metricTypes[0], "include_deleted", false),
+	}
+	// failed page of volumes or snapshots is retried the same way as tenants listing, pages needing retry are counted
+	var pagesRetried uint32
+	retry := getRetryPolicy(metricTypes[0])
+	listOpts.RetryPage = func(list func() error) error {
+		attempts := 0
+		return retry.do(func() error {
+			if attempts++; attempts == 2 {
+				atomic.AddUint32(&pagesRetried, 1)
+			}
+			return list()
+		})
+	}
+	allExtraVolumes := map[string]map[string]float64{}
+	allSnapshotMetadata := map[string]map[string]map[string]uint{}
+	allGroups := map[string]map[string]types.Groups{"volumes": {}, "snapshots": {}, "groups": {}}
+	var snapshotHosts map[string]uint
+
+	if len(projects) > 0 {
+		// collect volumes and snapshots of each configured project with token scoped to it,
+		// results are keyed by tenant ID which is project name for configured projects
+		// projects are listed in batches and with time budget the same way as tenants of all tenants listing
+		var mutex sync.Mutex
+		var done sync.WaitGroup
+		errChn := make(chan error, len(projects))
+		tenantIDs := tenantIDsByName(c.allTenants)
+		slots := getTenantSlots(metricTypes[0])
+		requestedProjects := make(map[string]bool, collectTenants.Size())
+		for _, tenant := range collectTenants.Elements() {
+			requestedProjects[tenant] = true
 		}
-		// Collect snapshots
-		if collectSnapshots {
+
+		for _, project := range projects {
+			requested := collectCloud || requestedProjects[project]
+			if !requested || !(collectVolumes || collectSnapshots) {
+				continue
+			}
+			// cloud-wide rollups are incomplete when any project fails
+			provider, service, err := c.authenticate(metricTypes[0], project)
+			if err != nil {
+				if err := failed.handle(err, request.families(), project, cloudNs); err != nil {
+					// projects listed already must not outlive collection
+					done.Wait()
+					return nil, err
+				}
+				continue
+			}
+
+			projectOpts := listOpts
+			projectOpts.AllTenants = false
+			projectOpts.ExtraVolumeValues = map[string]map[string]float64{}
+			projectOpts.SnapshotMetadataCounts = map[string]map[string]map[string]uint{}
+			projectOpts.VolumeGroups = map[string]types.Groups{}
+			projectOpts.SnapshotGroups = map[string]types.Groups{}
+			if collectOrphaned {
+				projectOpts.VolumeIDs = map[string]bool{}
+			}
+
+			release := slots.acquire(project)
 			done.Add(1)
-			go func() {
+			go func(p *gophercloud.ProviderClient, sv services.Service, t string) {
 				defer done.Done()
-				snapshots, err := c.service.GetSnapshots(provider)
-				if err != nil {
+				defer release()
+				volumes, snapshots, err := c.collectVolumesAndSnapshots(p, sv, projectOpts, request)
+				if err := failed.handle(err, request.families(), t, cloudNs); err != nil {
 					errChn <- err
+					return
 				}
 
-				for tenantId, snapshotCount := range snapshots {
-					tenantName := c.allTenants[tenantId]
-					allSnapshots[tenantName] = snapshotCount
+				// project scoped listing returns volumes and snapshots of that project only
+				id := tenantIDs[t]
+				mutex.Lock()
+				defer mutex.Unlock()
+				for _, volumeCount := range volumes {
+					allVolumes[id] = volumeCount
+				}
+				for _, snapshotCount := range snapshots {
+					allSnapshots[id] = snapshotCount
+				}
+				for _, extra := range projectOpts.ExtraVolumeValues {
+					allExtraVolumes[id] = extra
 				}
-			}()
+				for _, counts := range projectOpts.SnapshotMetadataCounts {
+					allSnapshotMetadata[id] = counts
+				}
+				for _, groups := range projectOpts.VolumeGroups {
+					allGroups["volumes"][id] = groups
+				}
+				for _, groups := range projectOpts.SnapshotGroups {
+					allGroups["snapshots"][id] = groups
+				}
+				cloud.addRollup(volumes, snapshots)
+			}(provider, service, project)
 		}
 
 		done.Wait()
@@ -181,31 +884,208 @@ func (c *collector) CollectMetrics(metricTypes []plugin.MetricType) ([]plugin.Me
 		if e := <-errChn; e != nil {
 			return nil, e
 		}
+	} else if collectVolumes || collectSnapshots {
+		// snapshots can be listed incrementally, using changes-since filter, with full listing repeated periodically,
+		// listing in tenant batches always lists snapshots fully
+		batchSize := getConfigInt(metricTypes[0], "tenant_batch_size", 0)
+		if !listOpts.AllTenants {
+			batchSize = 0
+		}
+		// Cinder does not filter volumes and snapshots by project name, tenants filtered by name are listed
+		// by project ID instead of listing all tenants
+		nameFiltered := listOpts.AllTenants && len(getConfigList(metricTypes[0], "tenant_name_filter")) > 0
+		if nameFiltered && batchSize <= 0 {
+			batchSize = defaultFilteredBatchSize
+		}
+		budget := time.Duration(getConfigInt(metricTypes[0], "tenant_time_budget", 0)) * time.Millisecond
+		if batchSize <= 0 && getConfigBool(metricTypes[0], "snapshots_changes_since", false) {
+			resync := time.Duration(getConfigInt(metricTypes[0], "snapshots_resync_interval", defaultResyncInterval)) * time.Second
+			if time.Since(c.snapshotIndex.Resynced) > resync {
+				c.snapshotIndex = types.NewSnapshotIndex()
+			}
+			listOpts.Snapshots = c.snapshotIndex
+		}
+		if collectOrphaned {
+			listOpts.VolumeIDs = map[string]bool{}
+		}
+		// host of volumes is visible with admin scope only, snapshots by host are not counted for scoped listing
+		if collectHosts && listOpts.AllTenants {
+			listOpts.VolumeHosts = map[string]string{}
+			listOpts.SnapshotHostCounts = map[string]uint{}
+		}
+		listOpts.ExtraVolumeValues = map[string]map[string]float64{}
+		listOpts.SnapshotMetadataCounts = map[string]map[string]map[string]uint{}
+		listOpts.VolumeGroups = map[string]types.Groups{}
+		listOpts.SnapshotGroups = map[string]types.Groups{}
+
+		// collect volumes and snapshots separately by authenticating to admin, failures concern all tenants
+		if provider, service, err := c.authenticate(metricTypes[0], admin); err != nil {
+			if err := failed.handle(err, request.families(), ""); err != nil {
+				return nil, err
+			}
+		} else {
+			var volumes map[string]types.Volumes
+			var snapshots map[string]types.Snapshots
+			if batchSize > 0 {
+				volumes, snapshots, err = c.collectTenantBatches(provider, service, listOpts, request, batchSize, budget, failed, cloudNs)
+			} else {
+				volumes, snapshots, err = c.collectVolumesAndSnapshots(provider, service, listOpts, request)
+			}
+			if err := failed.handle(err, request.families(), ""); err != nil {
+				return nil, err
+			}
+			allVolumes, allSnapshots, allExtraVolumes = volumes, snapshots, listOpts.ExtraVolumeValues
+			allSnapshotMetadata = listOpts.SnapshotMetadataCounts
+			snapshotHosts = listOpts.SnapshotHostCounts
+			allGroups["volumes"], allGroups["snapshots"] = listOpts.VolumeGroups, listOpts.SnapshotGroups
+			// all tenants visibility is considered working when volumes of enough distinct tenants are listed,
+			// admin account lacking it silently sees volumes of its own project only
+			if collectVisibility && volumes != nil {
+				allTenantsOK := 0
+				if len(volumes) >= getConfigInt(metricTypes[0], "all_tenants_min_tenants", defaultAllTenantsMinTenants) {
+					allTenantsOK = 1
+				}
+				cloud.M.AllTenantsOK = &allTenantsOK
+			}
+			// rollup includes tenants unknown by name too, unless tenants are filtered by name: Cinder ignoring
+			// project filter returns volumes and snapshots of other tenants, those are dropped
+			if nameFiltered {
+				volumes, snapshots = keepTenants(volumes, snapshots, c.allTenants)
+				allVolumes, allSnapshots = volumes, snapshots
+			}
+			cloud.addRollup(volumes, snapshots)
+		}
 	}
 
-	// Collect limits per each tenant only if not already collected (plugin lifetime scope)
+	// Collect limits per each tenant only if not already collected (plugin lifetime scope, or until positive limits_ttl
+	// expires), unless caching is disabled and limits are fetched on each collection
+	// tenants over quota rollup needs limits of all tenants
+	cacheLimits := getConfigBool(metricTypes[0], "cache_limits", true)
+	limitsTTL := time.Duration(getConfigInt(metricTypes[0], "limits_ttl", 0)) * time.Second
+	// refetched limits are requested conditionally with ETag of cached ones, when Cinder reports it
+	conditionalLimits := getConfigBool(metricTypes[0], "conditional_limits", true)
+	var fetchedLimits map[string]bool
+	volumeTypes := getQuotaVolumeTypes(metricTypes[0])
+	limitsTenants := collectTenants
+	if collectQuotaRollup {
+		limitsTenants = str.InitSet()
+		for _, tenant := range collectTenants.Elements() {
+			limitsTenants.Add(tenant)
+		}
+		for _, tenant := range c.allTenants {
+			limitsTenants.Add(tenant)
+		}
+	}
+	// quota usage of each tenant may be read with admin scoped token instead of authenticating to every tenant,
+	// configured projects are keyed by name, so those are always authenticated
+	adminLimits := getConfigBool(metricTypes[0], "admin_limits", false) && len(projects) == 0
+	tenantIDs := tenantIDsByName(c.allTenants)
 	{
+		var mutex sync.Mutex
 		var done sync.WaitGroup
-		errChn := make(chan error, collectTenants.Size())
+		errChn := make(chan error, limitsTenants.Size())
+		fetchedLimits = map[string]bool{}
 
-		for _, tenant := range collectTenants.Elements() {
-			_, found := c.allLimits[tenant]
-			if collectLimits && !found {
-				if err := c.authenticate(metricTypes[0], tenant); err != nil {
-					return nil, err
+		for _, tenant := range limitsTenants.Elements() {
+			// limits goroutines of previous tenants write cache concurrently
+			mutex.Lock()
+			cached, found := c.allLimits[tenant]
+			fetched := c.limitsFetched[tenant]
+			mutex.Unlock()
+			// limits cached without reserved usage or groups quota are fetched again once it is requested,
+			// unless it was requested already and Cinder did not report it
+			missesReserved := collectReserved && !cached.ReservedQueried
+			missesGroups := collectGroupsQuota && !cached.GroupsQueried
+			expired := limitsTTL > 0 && time.Since(fetched) >= limitsTTL
+			if collectLimits && (!found || !cacheLimits || expired || missesReserved || missesGroups) {
+				tenantID, known := tenantIDs[tenant]
+				mutex.Lock()
+				useAdmin := adminLimits && known && !c.adminLimitsFailed
+				mutex.Unlock()
+				var provider *gophercloud.ProviderClient
+				var service services.Service
+				var err error
+				if useAdmin {
+					if provider, service, err = c.authenticate(metricTypes[0], admin); err != nil {
+						useAdmin = false
+					}
+				}
+				if !useAdmin {
+					provider, service, err = c.authenticate(metricTypes[0], tenant)
+				}
+				if err != nil {
+					if err := failed.handle(err, []string{"limits"}, tenant); err != nil {
+						// limits of previous tenants are written to collector cache, those must not outlive collection
+						done.Wait()
+						return nil, err
+					}
+					continue
 				}
-
-				provider := c.providers[tenant]
 
 				done.Add(1)
-				go func(p *gophercloud.ProviderClient, t string) {
+				go func(p *gophercloud.ProviderClient, sv services.Service, t string) {
 					defer done.Done()
-					limits, err := c.service.GetLimits(p)
+					limitsOpts := types.LimitsOptions{VolumeTypes: volumeTypes, ByType: map[string]types.TypeLimits{}, Reserved: collectReserved, Groups: collectGroupsQuota}
+					if useAdmin {
+						limitsOpts.TenantID = tenantID
+					} else if conditionalLimits {
+						mutex.Lock()
+						limitsOpts.Conditional = &types.ConditionalGet{}
+						if _, found := c.allLimits[t]; found {
+							limitsOpts.Conditional.ETag = c.limitsETags[t]
+						}
+						mutex.Unlock()
+					}
+					start := time.Now()
+					limits, err := sv.GetLimits(p, limitsOpts)
+					c.cinderTimer.since(start)
+					if err != nil && useAdmin {
+						// admin can not read quota usage of other tenants (or API does not support it),
+						// limits are read with token scoped to tenant from now on; other failures are
+						// worked around with tenant scope in this collection only
+						if code, _ := openstackintel.StatusCode(err); code == http.StatusForbidden || code == http.StatusNotFound {
+							log.Printf("WARNING: limits of tenant %s could not be read with admin scope, authenticating to tenant instead: %v", t, err)
+							mutex.Lock()
+							c.adminLimitsFailed = true
+							mutex.Unlock()
+						} else {
+							log.Printf("WARNING: limits of tenant %s could not be read with admin scope, authenticating to tenant in this collection: %v", t, err)
+						}
+						if p, sv, err = c.authenticate(metricTypes[0], t); err == nil {
+							limitsOpts.TenantID = ""
+							start = time.Now()
+							limits, err = sv.GetLimits(p, limitsOpts)
+							c.cinderTimer.since(start)
+						}
+					}
 					if err != nil {
-						errChn <- err
+						if err := failed.handle(err, []string{"limits"}, t); err != nil {
+							errChn <- err
+						}
+						return
+					}
+					mutex.Lock()
+					defer mutex.Unlock()
+					if limitsOpts.Conditional != nil {
+						c.limitsETags[t] = limitsOpts.Conditional.ETag
+						// cached limits are current, quotas are unchanged
+						if cached, found := c.allLimits[t]; found && limitsOpts.Conditional.NotModified {
+							cached.Changed = 0
+							c.allLimits[t] = cached
+							c.limitsFetched[t] = time.Now()
+							fetchedLimits[t] = true
+							return
+						}
+					}
+					// quota change is reported when limits were fetched before, in the interval they are refetched
+					if previous, found := c.allLimits[t]; found && limitsChanged(previous, limits, c.allTypeLimits[t], limitsOpts.ByType) {
+						limits.Changed = 1
 					}
 					c.allLimits[t] = limits
-				}(provider, tenant)
+					c.allTypeLimits[t] = limitsOpts.ByType
+					c.limitsFetched[t] = time.Now()
+					fetchedLimits[t] = true
+				}(provider, service, tenant)
 			}
 		}
 
@@ -217,31 +1097,860 @@ func (c *collector) CollectMetrics(metricTypes []plugin.MetricType) ([]plugin.Me
 		}
 	}
 
-	metrics := []plugin.MetricType{}
-	for _, metricType := range metricTypes {
+	if collectQuotaRollup {
+		overQuota := uint(0)
+		for _, tenant := range c.allTenants {
+			if limits, found := c.allLimits[tenant]; found && limits.OverQuota() {
+				overQuota++
+			}
+		}
+		cloud.T.OverQuota = &overQuota
+	}
+
+	// volume types accessible by tenant are listed with tenant scoped calls
+	allVolumeTypes := map[string]types.VolumeTypes{}
+	if collectVolumeTypes {
+		allVolumeTypes, err = c.collectVolumeTypes(metricTypes[0], collectTenants.Elements(), failed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// default quotas are admin scoped and cached the same way as limits
+	if collectDefaultQuota && (c.defaultQuota == nil || !cacheLimits ||
+		(limitsTTL > 0 && time.Since(c.defaultQuotaFetched) >= limitsTTL)) {
+		if err := failed.handle(c.collectDefaultQuota(metricTypes[0], admin), []string{"default_quota"}, cloudNs); err != nil {
+			return nil, err
+		}
+	}
+	if c.defaultQuota != nil {
+		cloud.D = *c.defaultQuota
+	}
+
+	// QoS specs are admin scoped as well, those are fetched on each collection they are requested in
+	qosAssociations := map[string]uint{}
+	if collectQoSSpecs {
+		qosSpecs, err := c.collectQoSSpecs(metricTypes[0], admin, qosAssociations)
+		if err := failed.handle(err, []string{"qos_specs"}, cloudNs); err != nil {
+			return nil, err
+		}
+		cloud.Q = qosSpecs
+	}
+
+	// extra specs of volume types are admin scoped as well, those are cached for extra_specs_cache_ttl
+	var typesByExtraSpec map[string]map[string]uint
+	if collectExtraSpecs {
+		typesByExtraSpec, err = c.collectExtraSpecs(metricTypes[0], admin, getConfigList(metricTypes[0], "group_types_by_extra_spec"))
+		if err := failed.handle(err, []string{"volume_types"}, cloudNs); err != nil {
+			return nil, err
+		}
+	}
+
+	// pools are admin scoped too, capacities change continuously so those are fetched on each collection
+	poolOvercommit := map[string]float64{}
+	if collectPools {
+		pools, err := c.collectPools(metricTypes[0], admin, poolOvercommit)
+		if err := failed.handle(err, []string{"pools"}, cloudNs); err != nil {
+			return nil, err
+		}
+		cloud.P = pools
+	}
+
+	// heartbeats of Cinder services are admin scoped too, their age is measured when those are returned
+	var heartbeats map[string]map[string]int
+	if collectServices {
+		heartbeats, err = c.collectServices(metricTypes[0], admin)
+		if err := failed.handle(err, []string{"services"}, cloudNs); err != nil {
+			return nil, err
+		}
+	}
+
+	// generic volume groups of tenant are listed with tenant scoped calls, nothing is collected when
+	// Cinder does not support them
+	allVolumeGroups := map[string]types.VolumeGroups{}
+	if collectVolumeGroups {
+		var byStatus map[string]types.Groups
+		allVolumeGroups, byStatus, err = c.collectVolumeGroups(metricTypes[0], collectTenants.Elements(), failed)
+		if err != nil {
+			return nil, err
+		}
+		for tenant, groups := range byStatus {
+			allGroups["groups"][tenantIDs[tenant]] = groups
+		}
+	}
+
+	// version tag is resolved by service of configured project, admin one by default as it serves all-tenants
+	// listing, or first of configured projects when those are collected separately
+	serviceProject := admin
+	if len(projects) > 0 {
+		serviceProject = projects[0]
+	}
+	version := c.cinderVersion(metricTypes[0], getConfigString(metricTypes[0], "service_project", serviceProject))
+
+	cloud.M.KeystoneLatencyMs = c.keystoneTimer.milliseconds()
+	cloud.M.CinderLatencyMs = c.cinderTimer.milliseconds()
+	cloud.M.PagesRetried = uint(atomic.LoadUint32(&pagesRetried))
+	cloud.M.CatalogOK = 1
+	if atomic.LoadUint32(&c.catalogMissing) == 1 {
+		cloud.M.CatalogOK = 0
+	}
+	if tenantsVisible(metricTypes[0], c.allTenants) {
+		cloud.M.TenantsVisibleOK = 1
+	}
+	// partial results of best-effort mode are rejected when failures are widespread rather than limited to few tenants
+	cloud.M.FailedTenantsPercent = failed.failedPercent(collectTenants.Elements())
+	if threshold := getConfigInt(metricTypes[0], "failure_threshold_percent", 100); cloud.M.FailedTenantsPercent > float64(threshold) {
+		return nil, fmt.Errorf("Collection of %.1f%% of tenants failed, exceeding failure_threshold_percent %d", cloud.M.FailedTenantsPercent, threshold)
+	}
+	if collectRuntime {
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		cloud.M.Goroutines, cloud.M.HeapBytes = runtime.NumGoroutine(), memStats.HeapAlloc
+	}
+	cloud.M.APICalls = types.APICalls{
+		Volumes:      c.apiCalls.Get("volumes"),
+		Snapshots:    c.apiCalls.Get("snapshots"),
+		Limits:       c.apiCalls.Get("limits"),
+		DefaultQuota: c.apiCalls.Get("default_quota"),
+		VolumeTypes:  c.apiCalls.Get("volume_types"),
+		VolumeGroups: c.apiCalls.Get("groups"),
+		QoSSpecs:     c.apiCalls.Get("qos_specs"),
+		Pools:        c.apiCalls.Get("pools"),
+		Services:     c.apiCalls.Get("services"),
+	}
+	cloud.M.APIErrorRate = c.apiCalls.ErrorRate()
+	cloud.M.KeystoneReachable, cloud.M.CinderReachable = probed.KeystoneReachable, probed.CinderReachable
+
+	// breakdowns by value are optionally capped to protect metric store, values above cap are bucketed together
+	truncated := capBreakdowns(allSnapshotMetadata, allGroups, getCardinalityCaps(metricTypes[0]))
+
+	// Construct temporary struct per tenant to accommodate all gathered metrics,
+	// single container is shared by all metrics requested for given tenant
+	containers := make(map[string]metricContainer, collectTenants.Size())
+	for _, tenant := range collectTenants.Elements() {
+		limits := c.allLimits[tenant]
+		if !fetchedLimits[tenant] {
+			limits.FromCache = 1
+			limits.Changed = 0
+			if fetched, found := c.limitsFetched[tenant]; found {
+				limits.CacheAgeSeconds = int(time.Since(fetched).Seconds())
+			}
+		}
+		// tenants absent from listings have no volumes or snapshots, zero values are kept for them so every
+		// requested metric is emitted and series have no gaps
+		volumes, snapshots := allVolumes[tenantIDs[tenant]], allSnapshots[tenantIDs[tenant]]
+		// counts are tracked only when fully listed, listing of deleting volumes alone does not count all of them
+		if collectVolumes && !onlyDeletingVolumes && !failed.has(tenant, "volumes") {
+			volumes.DeltaAbs = c.countDelta("volumes", tenantIDs[tenant], volumes.Count)
+		}
+		if collectSnapshots && !failed.has(tenant, "snapshots") {
+			snapshots.DeltaAbs = c.countDelta("snapshots", tenantIDs[tenant], snapshots.Count)
+		}
+		containers[tenant] = metricContainer{
+			S: snapshots,
+			V: volumes,
+			L: limits,
+			T: allVolumeTypes[tenant],
+			G: allVolumeGroups[tenant],
+		}
+	}
+
+	c.keepSummary(cloud, allVolumes)
+
+	return &collection{
+		metricTypes:      metricTypes,
+		version:          version,
+		failed:           failed,
+		sanitize:         sanitize,
+		cloudNs:          cloudNs,
+		tenants:          containers,
+		cloud:            cloud,
+		extraVolumes:     allExtraVolumes,
+		snapshotMetadata: allSnapshotMetadata,
+		groups:           allGroups,
+		qosAssociations:  qosAssociations,
+		typesByExtraSpec: typesByExtraSpec,
+		poolOvercommit:   poolOvercommit,
+		heartbeats:       heartbeats,
+		snapshotHosts:    snapshotHosts,
+		truncated:        truncated,
+		apiTimestamps:    timestampSource == timestampAPI,
+	}, nil
+}
+
+// emitMetrics creates metrics requested in collection from gathered data
+func (c *collector) emitMetrics(collected *collection) []plugin.MetricType {
+	tenantIDs := tenantIDsByName(c.allTenants)
+
+	// optionally skip metrics which value has not changed since previous collection,
+	// first collection of given namespace is always emitted
+	emitOnChange := getConfigBool(collected.metricTypes[0], "emit_on_change_only", false)
+
+	// float values (averages, latencies, sums of extra fields) are optionally rounded to given number of decimal places,
+	// negative precision keeps full precision
+	precision := getConfigInt(collected.metricTypes[0], "float_precision", -1)
+
+	// requested namespaces not mapping to any metric are skipped unless value emitted for them is configured
+	var unknownValue float64
+	emitUnknown := false
+	if configured := getConfigString(collected.metricTypes[0], "unknown_namespace_value", ""); configured != "" {
+		var err error
+		if unknownValue, err = strconv.ParseFloat(configured, 64); err != nil {
+			log.Printf("WARNING: invalid unknown_namespace_value %q: %v", configured, err)
+		}
+		emitUnknown = err == nil
+	}
+
+	// metrics are tagged with static tags configured by user and Cinder version derived from version discovery,
+	// when known, plugin tags take precedence over static ones of the same name
+	tags := getStaticTags(collected.metricTypes[0])
+	if version := collected.version; version != "" {
+		tags["cinder_version"] = version
+	}
+
+	metrics := make([]plugin.MetricType, 0, len(collected.metricTypes))
+	emit := func(namespace core.Namespace, data interface{}) {
+		// each metric carries its own tags, so those modified by MetricTransform are not shared with other metrics
+		metricTags := make(map[string]string, len(tags)+1)
+		for key, value := range tags {
+			metricTags[key] = value
+		}
+		unit := metricUnit(namespace.Strings())
+		timestamp := time.Now()
+		if observed, found := c.observedAt(namespace.Strings()); collected.apiTimestamps && found {
+			timestamp = observed
+		}
+		// domain of tenant is inserted into namespace when domain element is included
+		if collected.domains != nil {
+			namespace = insertDomain(namespace, collected.domains, collected.cloudNs)
+		}
+		// sanitized namespace is emitted with original one preserved as tag
+		if collected.sanitize {
+			sanitized := sanitizeNamespace(namespace)
+			if original := namespace.String(); sanitized.String() != original {
+				if _, found := tags[originalNamespaceTag]; !found {
+					metricTags[originalNamespaceTag] = original
+				}
+			}
+			namespace = sanitized
+		}
+
+		if value, ok := data.(float64); ok && precision >= 0 {
+			data = roundFloat(value, precision)
+		}
+
+		if emitOnChange {
+			key := namespace.String()
+			if last, found := c.lastValues[key]; found && last == data {
+				return
+			}
+			c.lastValues[key] = data
+		}
+
+		metric := plugin.MetricType{
+			Timestamp_: timestamp,
+			Namespace_: namespace,
+			Data_:      data,
+			Tags_:      metricTags,
+			Unit_:      unit,
+		}
+		metrics = append(metrics, metric)
+	}
+	// explicitly requested dynamic value may be given in its sanitized form
+	requestedValue := func(keys []string, requested string) string {
+		if collected.sanitize {
+			return matchKey(keys, requested)
+		}
+		return requested
+	}
+
+	for _, metricType := range collected.metricTypes {
 		namespace := metricType.Namespace().Strings()
 		tenant := namespace[3]
-		// Construct temporary struct to accommodate all gathered metrics
-		metricContainer := struct {
-			S types.Snapshots `json:"snapshots"`
-			V types.Volumes   `json:"volumes"`
-			L types.Limits    `json:"limits"`
-		}{
-			allSnapshots[tenant],
-			allVolumes[tenant],
-			c.allLimits[tenant],
+		// metrics of families which failed to be collected in best-effort mode are omitted
+		if collected.failed.has(tenant, namespace[4]) {
+			continue
+		}
+
+		// breakdowns report whether their values were capped by max_cardinality
+		if namespace[len(namespace)-1] == truncatedElement && (len(namespace) == 7 || len(namespace) == 8) && strings.HasPrefix(namespace[5], "by_") {
+			flag := 0
+			if collected.truncated[breakdownKey(tenantIDs[tenant], namespace[4:len(namespace)-1]...)] {
+				flag = 1
+			}
+			emit(metricType.Namespace(), flag)
+			continue
+		}
+
+		// snapshots by metadata value are emitted for each value found when value element is dynamic
+		if len(namespace) == 9 && namespace[4] == "snapshots" && namespace[5] == "by_metadata" {
+			counts := collected.snapshotMetadata[tenantIDs[tenant]][namespace[6]]
+			values := sortedCountKeys(counts)
+			if namespace[7] != "*" {
+				values = []string{requestedValue(values, namespace[7])}
+			}
+			for _, value := range values {
+				ns := make(core.Namespace, len(namespace))
+				copy(ns, metricType.Namespace())
+				ns[7].Value = value
+				emit(ns, counts[value])
+			}
+			continue
+		}
+
+		// volumes and snapshots groups are emitted for each value found when value element is dynamic
+		if len(namespace) == 8 && str.Contains([]string{"volumes", "snapshots", "groups"}, namespace[4]) && strings.HasPrefix(namespace[5], "by_") {
+			groups := collected.groups[namespace[4]][tenantIDs[tenant]][strings.TrimPrefix(namespace[5], "by_")]
+			values := sortedGroupKeys(groups)
+			if namespace[6] != "*" {
+				values = []string{requestedValue(values, namespace[6])}
+			}
+			for _, value := range values {
+				ns := make(core.Namespace, len(namespace))
+				copy(ns, metricType.Namespace())
+				ns[6].Value = value
+				emit(ns, getValueByNamespace(groups[value], namespace[7:]))
+			}
+			continue
+		}
+
+		// volume types by extra spec value are emitted for each value found when value element is dynamic
+		if tenant == collected.cloudNs && len(namespace) == 9 && namespace[4] == "volume_types" && namespace[5] == "by_extraspec" {
+			counts := collected.typesByExtraSpec[namespace[6]]
+			values := sortedCountKeys(counts)
+			if namespace[7] != "*" {
+				values = []string{requestedValue(values, namespace[7])}
+			}
+			for _, value := range values {
+				ns := make(core.Namespace, len(namespace))
+				copy(ns, metricType.Namespace())
+				ns[7].Value = value
+				emit(ns, counts[value])
+			}
+			continue
+		}
+
+		// associations of QoS specs are emitted for each spec found when spec element is dynamic
+		if tenant == collected.cloudNs && len(namespace) == 7 && namespace[4] == "qos_specs" && namespace[6] == "associations" {
+			specs := sortedCountKeys(collected.qosAssociations)
+			if namespace[5] != "*" {
+				specs = []string{requestedValue(specs, namespace[5])}
+			}
+			for _, spec := range specs {
+				associations, found := collected.qosAssociations[spec]
+				if !found {
+					continue
+				}
+				ns := make(core.Namespace, len(namespace))
+				copy(ns, metricType.Namespace())
+				ns[5].Value = spec
+				emit(ns, associations)
+			}
+			continue
+		}
+
+		// snapshot count is emitted for each back-end host of volumes when host element is dynamic, those are not
+		// known when volumes or snapshots of any tenant failed
+		if tenant == collected.cloudNs && len(namespace) == 7 && namespace[4] == "hosts" && namespace[6] == "snapshot_count" {
+			if collected.failed.has(tenant, "volumes") || collected.failed.has(tenant, "snapshots") {
+				continue
+			}
+			hosts := sortedCountKeys(collected.snapshotHosts)
+			if namespace[5] != "*" {
+				hosts = []string{requestedValue(hosts, namespace[5])}
+			}
+			for _, host := range hosts {
+				count, found := collected.snapshotHosts[host]
+				if !found {
+					continue
+				}
+				ns := make(core.Namespace, len(namespace))
+				copy(ns, metricType.Namespace())
+				ns[5].Value = host
+				emit(ns, count)
+			}
+			continue
+		}
+
+		// overcommit ratio is emitted for each pool reporting capacities when pool element is dynamic
+		if tenant == collected.cloudNs && len(namespace) == 7 && namespace[4] == "pools" && namespace[6] == "overcommit_ratio" {
+			pools := sortedRatioKeys(collected.poolOvercommit)
+			if namespace[5] != "*" {
+				pools = []string{requestedValue(pools, namespace[5])}
+			}
+			for _, pool := range pools {
+				ratio, found := collected.poolOvercommit[pool]
+				if !found {
+					continue
+				}
+				ns := make(core.Namespace, len(namespace))
+				copy(ns, metricType.Namespace())
+				ns[5].Value = pool
+				emit(ns, ratio)
+			}
+			continue
+		}
+
+		// age of heartbeat is emitted for each binary on each host of Cinder services when those elements are dynamic
+		if tenant == collected.cloudNs && len(namespace) == 8 && namespace[4] == "services" && namespace[7] == "heartbeat_age_seconds" {
+			hosts := sortedHeartbeatHosts(collected.heartbeats)
+			if namespace[5] != "*" {
+				hosts = []string{requestedValue(hosts, namespace[5])}
+			}
+			for _, host := range hosts {
+				ages := collected.heartbeats[host]
+				binaries := sortedAgeKeys(ages)
+				if namespace[6] != "*" {
+					binaries = []string{requestedValue(binaries, namespace[6])}
+				}
+				for _, binary := range binaries {
+					age, found := ages[binary]
+					if !found {
+						continue
+					}
+					ns := make(core.Namespace, len(namespace))
+					copy(ns, metricType.Namespace())
+					ns[5].Value, ns[6].Value = host, binary
+					emit(ns, age)
+				}
+			}
+			continue
 		}
 
 		// Extract values by namespace from temporary struct and create metrics
-		metric := plugin.MetricType{
-			Timestamp_: time.Now(),
-			Namespace_: metricType.Namespace(),
-			Data_:      ns.GetValueByNamespace(metricContainer, namespace[4:]),
+		var data interface{}
+		if tenant == collected.cloudNs {
+			data = getValueByNamespace(collected.cloud, namespace[4:])
+		} else if len(namespace) == 7 && namespace[4] == "volumes" && namespace[5] == "extra" {
+			data = collected.extraVolumes[tenantIDs[tenant]][namespace[6]]
+		} else if len(namespace) == 8 && namespace[4] == "limits" && namespace[5] == "by_type" {
+			// types absent from quota usage are omitted
+			if typeLimits, found := c.allTypeLimits[tenant][namespace[6]]; found {
+				data = getValueByNamespace(typeLimits, namespace[7:])
+			}
+		} else {
+			data = getValueByNamespace(collected.tenants[tenant], namespace[4:])
 		}
-		metrics = append(metrics, metric)
+		// metrics without value, like quotas not reported by given cloud, are omitted, namespaces not mapping to any
+		// metric are reported and emitted with configured value, if any
+		if data == nil {
+			if !knownNamespace(namespace, collected.cloudNs) {
+				log.Printf("WARNING: namespace %s does not map to any metric", metricType.Namespace().String())
+				if emitUnknown {
+					emit(metricType.Namespace(), unknownValue)
+				}
+			}
+			continue
+		}
+		// snapshots per volume and orphaned snapshots can not be found without volumes of tenant
+		if len(namespace) == 6 && namespace[4] == "snapshots" && (namespace[5] == "max_snapshots_per_volume" || namespace[5] == "orphaned") {
+			if data == -1 || collected.failed.has(tenant, "volumes") {
+				continue
+			}
+		}
+		emit(metricType.Namespace(), data)
 	}
 
-	return metrics, nil
+	return metrics
+}
+
+// collectRequest describes which volumes and snapshots metrics are requested
+type collectRequest struct {
+	volumes, snapshots, orphaned, hosts, onlyDeletingVolumes bool
+}
+
+// families returns names of requested families
+func (r collectRequest) families() []string {
+	families := []string{}
+	if r.volumes {
+		families = append(families, "volumes")
+	}
+	if r.snapshots {
+		families = append(families, "snapshots")
+	}
+	return families
+}
+
+// collectVolumesAndSnapshots lists volumes and snapshots visible to provider concurrently,
+// results are keyed by tenant ID. Errors are reported as familyErrors, results of families
+// collected successfully are returned anyway
+func (c *collector) collectVolumesAndSnapshots(provider *gophercloud.ProviderClient, service services.Service, listOpts types.ListOptions, request collectRequest) (map[string]types.Volumes, map[string]types.Snapshots, error) {
+	var allVolumes map[string]types.Volumes
+	var allSnapshots map[string]types.Snapshots
+
+	var done, volumesDone sync.WaitGroup
+	errChn := make(chan familyError, 2)
+
+	// Collect volumes
+	if request.volumes {
+		done.Add(1)
+		volumesDone.Add(1)
+		go func() {
+			defer done.Done()
+			defer volumesDone.Done()
+			// list only volumes being deleted when no other volumes metric is requested
+			volumeOpts := listOpts
+			if request.onlyDeletingVolumes {
+				volumeOpts.VolumeStatus = "deleting"
+			}
+			start := time.Now()
+			volumes, err := service.GetVolumes(provider, volumeOpts)
+			c.cinderTimer.since(start)
+			if err != nil {
+				errChn <- familyError{family: "volumes", err: err}
+				return
+			}
+			c.apiClock.record("volumes")
+			allVolumes = volumes
+		}()
+	}
+	// Collect snapshots
+	if request.snapshots {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			if request.orphaned || request.hosts {
+				volumesDone.Wait()
+			}
+			start := time.Now()
+			snapshots, err := service.GetSnapshots(provider, listOpts)
+			c.cinderTimer.since(start)
+			if err != nil {
+				errChn <- familyError{family: "snapshots", err: err}
+				return
+			}
+			c.apiClock.record("snapshots")
+			allSnapshots = snapshots
+		}()
+	}
+
+	done.Wait()
+	close(errChn)
+
+	errs := familyErrors{}
+	for e := range errChn {
+		errs = append(errs, e)
+	}
+	if len(errs) > 0 {
+		return allVolumes, allSnapshots, errs
+	}
+	return allVolumes, allSnapshots, nil
+}
+
+// collectTenantBatches lists volumes and snapshots of known tenants filtered by project ID instead of single listing
+// of all tenants, at most batchSize tenants are listed at once, next tenant as soon as any listing finishes, so number
+// of volumes held in responses at once is bounded; failure of tenant listing concerns that tenant and cloud-wide rollups only.
+// Tenant listing longer than positive budget gives up its slot to next tenant and continues aside (see tenantSlots), so
+// single huge tenant does not delay listing of others.
+func (c *collector) collectTenantBatches(provider *gophercloud.ProviderClient, service services.Service, listOpts types.ListOptions, request collectRequest, batchSize int, budget time.Duration, failed *failures, cloudNs string) (map[string]types.Volumes, map[string]types.Snapshots, error) {
+	allVolumes := map[string]types.Volumes{}
+	allSnapshots := map[string]types.Snapshots{}
+
+	tenantIDs := make([]string, 0, len(c.allTenants))
+	for tenantID := range c.allTenants {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	sort.Strings(tenantIDs)
+
+	var mutex sync.Mutex
+	var done sync.WaitGroup
+	var aborted uint32
+	errChn := make(chan error, len(tenantIDs))
+	slots := newTenantSlots(batchSize, budget)
+
+	for _, tenantID := range tenantIDs {
+		release := slots.acquire(c.allTenants[tenantID])
+		if atomic.LoadUint32(&aborted) == 1 {
+			release()
+			break
+		}
+
+		tenantOpts := listOpts
+		tenantOpts.ProjectID = tenantID
+		tenantOpts.ExtraVolumeValues = map[string]map[string]float64{}
+		tenantOpts.SnapshotMetadataCounts = map[string]map[string]map[string]uint{}
+		tenantOpts.VolumeGroups = map[string]types.Groups{}
+		tenantOpts.SnapshotGroups = map[string]types.Groups{}
+		if listOpts.VolumeIDs != nil {
+			tenantOpts.VolumeIDs = map[string]bool{}
+		}
+		if listOpts.VolumeHosts != nil {
+			tenantOpts.VolumeHosts = map[string]string{}
+			tenantOpts.SnapshotHostCounts = map[string]uint{}
+		}
+
+		done.Add(1)
+		go func(t string) {
+			defer done.Done()
+			defer release()
+
+			volumes, snapshots, err := c.collectVolumesAndSnapshots(provider, service, tenantOpts, request)
+			if err := failed.handle(err, request.families(), c.allTenants[t], cloudNs); err != nil {
+				atomic.StoreUint32(&aborted, 1)
+				errChn <- err
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			for tenantID, volumeCount := range volumes {
+				allVolumes[tenantID] = volumeCount
+			}
+			for tenantID, snapshotCount := range snapshots {
+				allSnapshots[tenantID] = snapshotCount
+			}
+			for tenantID, extra := range tenantOpts.ExtraVolumeValues {
+				listOpts.ExtraVolumeValues[tenantID] = extra
+			}
+			for tenantID, counts := range tenantOpts.SnapshotMetadataCounts {
+				listOpts.SnapshotMetadataCounts[tenantID] = counts
+			}
+			for tenantID, groups := range tenantOpts.VolumeGroups {
+				listOpts.VolumeGroups[tenantID] = groups
+			}
+			for tenantID, groups := range tenantOpts.SnapshotGroups {
+				listOpts.SnapshotGroups[tenantID] = groups
+			}
+			for host, count := range tenantOpts.SnapshotHostCounts {
+				listOpts.SnapshotHostCounts[host] += count
+			}
+		}(tenantID)
+	}
+
+	done.Wait()
+	close(errChn)
+
+	if e := <-errChn; e != nil {
+		return nil, nil, e
+	}
+	return allVolumes, allSnapshots, nil
+}
+
+// collectVolumeTypes counts volume types accessible by each of tenants concurrently, in batches of tenant_batch_size,
+// results are keyed by tenant name
+func (c *collector) collectVolumeTypes(cfg interface{}, tenants []string, failed *failures) (map[string]types.VolumeTypes, error) {
+	var mutex sync.Mutex
+	var done sync.WaitGroup
+	errChn := make(chan error, len(tenants))
+	allVolumeTypes := map[string]types.VolumeTypes{}
+	slots := getTenantSlots(cfg)
+
+	for _, tenant := range tenants {
+		provider, service, err := c.authenticate(cfg, tenant)
+		if err != nil {
+			if err := failed.handle(err, []string{"volume_types"}, tenant); err != nil {
+				done.Wait()
+				return nil, err
+			}
+			continue
+		}
+
+		release := slots.acquire(tenant)
+		done.Add(1)
+		go func(p *gophercloud.ProviderClient, sv services.Service, t string) {
+			defer done.Done()
+			defer release()
+			start := time.Now()
+			volumeTypes, err := sv.GetVolumeTypes(p)
+			c.cinderTimer.since(start)
+			if err != nil {
+				if err := failed.handle(err, []string{"volume_types"}, t); err != nil {
+					errChn <- err
+				}
+				return
+			}
+			c.apiClock.record("volume_types")
+			mutex.Lock()
+			defer mutex.Unlock()
+			allVolumeTypes[t] = volumeTypes
+		}(provider, service, tenant)
+	}
+
+	done.Wait()
+	close(errChn)
+
+	if e := <-errChn; e != nil {
+		return nil, e
+	}
+	return allVolumeTypes, nil
+}
+
+// collectVolumeGroups counts generic volume groups of each of tenants concurrently, in batches of tenant_batch_size,
+// results and groups by status are keyed by tenant name
+func (c *collector) collectVolumeGroups(cfg interface{}, tenants []string, failed *failures) (map[string]types.VolumeGroups, map[string]types.Groups, error) {
+	var mutex sync.Mutex
+	var done sync.WaitGroup
+	errChn := make(chan error, len(tenants))
+	allVolumeGroups := map[string]types.VolumeGroups{}
+	allByStatus := map[string]types.Groups{}
+	slots := getTenantSlots(cfg)
+
+	for _, tenant := range tenants {
+		provider, service, err := c.authenticate(cfg, tenant)
+		if err != nil {
+			if err := failed.handle(err, []string{"groups"}, tenant); err != nil {
+				done.Wait()
+				return nil, nil, err
+			}
+			continue
+		}
+
+		release := slots.acquire(tenant)
+		done.Add(1)
+		go func(p *gophercloud.ProviderClient, sv services.Service, t string) {
+			defer done.Done()
+			defer release()
+			opts := types.VolumeGroupsOptions{ByStatus: types.Groups{}}
+			start := time.Now()
+			volumeGroups, err := sv.GetVolumeGroups(p, opts)
+			c.cinderTimer.since(start)
+			if err != nil {
+				if err := failed.handle(err, []string{"groups"}, t); err != nil {
+					errChn <- err
+				}
+				return
+			}
+			c.apiClock.record("groups")
+			mutex.Lock()
+			defer mutex.Unlock()
+			allVolumeGroups[t] = volumeGroups
+			allByStatus[t] = opts.ByStatus
+		}(provider, service, tenant)
+	}
+
+	done.Wait()
+	close(errChn)
+
+	if e := <-errChn; e != nil {
+		return nil, nil, e
+	}
+	return allVolumeGroups, allByStatus, nil
+}
+
+// collectDefaultQuota fetches default quotas by authenticating to admin
+func (c *collector) collectDefaultQuota(cfg interface{}, admin string) error {
+	provider, service, err := c.authenticate(cfg, admin)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	quota, err := service.GetDefaultQuotas(provider)
+	c.cinderTimer.since(start)
+	if err != nil {
+		return err
+	}
+	c.defaultQuota, c.defaultQuotaFetched = &quota, time.Now()
+	return nil
+}
+
+// observedAt returns time data of metric with given namespace was returned by API, cached limits and default quotas
+// carry time they were fetched; false for metrics not backed by block storage call (eg. tenants, meta)
+func (c *collector) observedAt(namespace []string) (time.Time, bool) {
+	switch namespace[4] {
+	case "limits":
+		fetched, found := c.limitsFetched[namespace[3]]
+		return fetched, found
+	case "default_quota":
+		return c.defaultQuotaFetched, !c.defaultQuotaFetched.IsZero()
+	}
+	return c.apiClock.at(namespace[4])
+}
+
+// countDelta returns absolute change of count of given family of tenant since previous collection and keeps count
+// for next collection, change is 0 in first collection of tenant
+func (c *collector) countDelta(family, tenantID string, count uint) uint {
+	key := family + "/" + tenantID
+	previous, found := c.lastCounts[key]
+	c.lastCounts[key] = count
+	if !found {
+		return 0
+	}
+	if count < previous {
+		return previous - count
+	}
+	return count - previous
+}
+
+// limitsChanged checks if quotas of tenant, including quotas of volume types and groups known in both, differ from
+// previous ones
+func limitsChanged(previous, current types.Limits, previousTypes, currentTypes map[string]types.TypeLimits) bool {
+	if !previous.QuotasEqual(current) {
+		return true
+	}
+	if previous.Groups != nil && current.Groups != nil && *previous.Groups != *current.Groups {
+		return true
+	}
+	for volumeType, currentType := range currentTypes {
+		if previousType, found := previousTypes[volumeType]; found && previousType.Gigabytes != currentType.Gigabytes {
+			return true
+		}
+	}
+	return false
+}
+
+// collectQoSSpecs counts QoS specs and their associations by authenticating to admin
+func (c *collector) collectQoSSpecs(cfg interface{}, admin string, associations map[string]uint) (types.QoSSpecs, error) {
+	provider, service, err := c.authenticate(cfg, admin)
+	if err != nil {
+		return types.QoSSpecs{}, err
+	}
+
+	start := time.Now()
+	qosSpecs, err := service.GetQoSSpecs(provider, types.QoSSpecsOptions{Associations: associations})
+	c.cinderTimer.since(start)
+	if err == nil {
+		c.apiClock.record("qos_specs")
+	}
+	return qosSpecs, err
+}
+
+// collectPools counts back-end storage pools and their overcommit ratios by authenticating to admin
+func (c *collector) collectPools(cfg interface{}, admin string, overcommit map[string]float64) (types.Pools, error) {
+	provider, service, err := c.authenticate(cfg, admin)
+	if err != nil {
+		return types.Pools{}, err
+	}
+
+	start := time.Now()
+	pools, err := service.GetPools(provider, types.PoolsOptions{OvercommitRatio: overcommit})
+	c.cinderTimer.since(start)
+	if err == nil {
+		c.apiClock.record("pools")
+	}
+	return pools, err
+}
+
+// collectServices reads heartbeats of Cinder services by authenticating to admin, and returns their age in seconds
+// by host and binary
+func (c *collector) collectServices(cfg interface{}, admin string) (map[string]map[string]int, error) {
+	provider, service, err := c.authenticate(cfg, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	heartbeats, err := service.GetServices(provider)
+	c.cinderTimer.since(start)
+	if err != nil {
+		return nil, err
+	}
+	c.apiClock.record("services")
+
+	now := time.Now()
+	ages := map[string]map[string]int{}
+	for _, heartbeat := range heartbeats {
+		if ages[heartbeat.Host] == nil {
+			ages[heartbeat.Host] = map[string]int{}
+		}
+		// clock of service host may be slightly ahead, heartbeat from the future is just fresh
+		age := int(now.Sub(heartbeat.UpdatedAt).Seconds())
+		if age < 0 {
+			age = 0
+		}
+		ages[heartbeat.Host][heartbeat.Binary] = age
+	}
+	return ages, nil
 }
 
 // GetConfigPolicy returns config policy
@@ -253,87 +1962,822 @@ func (c *collector) GetConfigPolicy() (*cpolicy.ConfigPolicy, error) {
 
 // Commenting exported items is very important
 func Meta() *plugin.PluginMeta {
-	return plugin.NewPluginMeta(
-		name,
-		version,
+	meta := plugin.NewPluginMeta(
+		Name,
+		Version,
 		plgtype,
 		[]string{plugin.SnapGOBContentType},
 		[]string{plugin.SnapGOBContentType},
-		plugin.RoutingStrategy(plugin.StickyRouting),
+		plugin.RoutingStrategy(RoutingStrategy),
 	)
+	if ConcurrencyCount > 0 {
+		meta.ConcurrencyCount = ConcurrencyCount
+	}
+	return meta
+}
+
+// metricContainer gathers all metrics of single tenant, its json tags define metric namespaces
+type metricContainer struct {
+	S types.Snapshots    `json:"snapshots"`
+	V types.Volumes      `json:"volumes"`
+	L types.Limits       `json:"limits"`
+	T types.VolumeTypes  `json:"volume_types"`
+	G types.VolumeGroups `json:"groups"`
+}
+
+// cloudContainer gathers cloud-wide metrics, its json tags define metric namespaces
+type cloudContainer struct {
+	T types.Tenants        `json:"tenants"`
+	V types.CloudVolumes   `json:"volumes"`
+	S types.CloudSnapshots `json:"snapshots"`
+	D types.DefaultQuota   `json:"default_quota"`
+	Q types.QoSSpecs       `json:"qos_specs"`
+	P types.Pools          `json:"pools"`
+	M types.Meta           `json:"meta"`
+}
+
+// addRollup adds volumes and snapshots of tenants to cloud-wide rollups, rollup of family stays nil
+// when family was not collected
+func (c *cloudContainer) addRollup(volumes map[string]types.Volumes, snapshots map[string]types.Snapshots) {
+	if volumes != nil {
+		if c.V.Total == nil {
+			c.V.Total, c.V.GigabytesTotal = new(uint), new(int)
+		}
+		for _, v := range volumes {
+			*c.V.Total += v.Count
+			*c.V.GigabytesTotal += v.Bytes / (1024 * 1024 * 1024)
+		}
+	}
+	if snapshots != nil {
+		if c.S.Total == nil {
+			c.S.Total = new(uint)
+		}
+		for _, s := range snapshots {
+			*c.S.Total += s.Count
+		}
+	}
 }
 
 type collector struct {
-	allTenants map[string]string
-	service    services.Service
-	common     openstackintel.Commoner
-	allLimits  map[string]types.Limits
-	providers  map[string]*gophercloud.ProviderClient
-}
-
-func (c *collector) authenticate(cfg interface{}, tenant string) error {
-	if _, found := c.providers[tenant]; !found {
-		domain_name := ""
-		domain_id := ""
-		// get credentials and endpoint from configuration
-		items, err := config.GetConfigItems(cfg, "endpoint", "user", "password")
+	// MetricTransform post-processes metrics returned by CollectMetrics (eg. renames namespaces, drops metrics or adds
+	// derived ones), plugins embedding the collector may set it; nil returns metrics as they were emitted
+	MetricTransform func([]plugin.MetricType) []plugin.MetricType
+
+	allTenants          map[string]string
+	tenantDomains       map[string]string
+	common              openstackintel.Commoner
+	allLimits           map[string]types.Limits
+	allTypeLimits       map[string]map[string]types.TypeLimits
+	limitsFetched       map[string]time.Time
+	limitsETags         map[string]string
+	defaultQuota        *types.DefaultQuota
+	defaultQuotaFetched time.Time
+	providers           map[string]*gophercloud.ProviderClient
+	services            map[string]services.Service
+	authMutex           sync.Mutex
+	authCalls           map[string]*authCall
+	snapshotIndex       *types.SnapshotIndex
+	lastValues          map[string]interface{}
+	lastCounts          map[string]uint
+	keystoneTimer       *apiTimer
+	cinderTimer         *apiTimer
+	apiCalls            *services.CallCounter
+	authKey             string
+	adminTenant         string
+	adminRole           string
+
+	// extraSpecs are extra specs by volume type ID, read completely again when extraSpecsFetched is older than TTL
+	extraSpecs        map[string]map[string]string
+	extraSpecsFetched time.Time
+
+	// apiClock records when API calls of current collection returned, metrics may be timestamped with it
+	apiClock *apiClock
+
+	// summary is overview of last finished collection, it may be read concurrently with collection
+	summary      types.CloudSummary
+	summaryMutex sync.Mutex
+
+	// adminLimitsFailed is set when limits of tenant could not be read with admin scope, limits are read with
+	// token scoped to each tenant since then
+	adminLimitsFailed bool
+
+	// collectMutex serializes collections and metric types listing, those share tenants, caches, timers and
+	// previous values of collector
+	collectMutex sync.Mutex
+
+	// catalogMissing is set when Cinder is missing in service catalog of token authenticated in current collection
+	catalogMissing uint32
+}
+
+// LastSummary returns overview of cloud computed in last finished collection, its Collected time is zero when no
+// collection finished yet
+func (c *collector) LastSummary() types.CloudSummary {
+	c.summaryMutex.Lock()
+	defer c.summaryMutex.Unlock()
+	return c.summary
+}
+
+// keepSummary computes overview of cloud from cloud-wide rollups and volumes of tenants, so it is available
+// to LastSummary
+func (c *collector) keepSummary(cloud cloudContainer, volumes map[string]types.Volumes) {
+	summary := types.CloudSummary{Tenants: cloud.T.Count, Collected: time.Now()}
+	if cloud.V.Total != nil {
+		summary.Volumes, summary.Gigabytes = *cloud.V.Total, *cloud.V.GigabytesTotal
+	}
+	if cloud.S.Total != nil {
+		summary.Snapshots = *cloud.S.Total
+	}
+	for _, v := range volumes {
+		summary.Errors += v.Errors
+	}
+
+	c.summaryMutex.Lock()
+	defer c.summaryMutex.Unlock()
+	c.summary = summary
+}
+
+// InvalidateAuth drops authenticated providers, so next collection authenticates again with current configuration
+func (c *collector) InvalidateAuth() {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+	c.providers = map[string]*gophercloud.ProviderClient{}
+	c.services = map[string]services.Service{}
+	c.authCalls = map[string]*authCall{}
+}
+
+// checkAuthChange invalidates authentication together with tenants and cached limits when endpoint or credentials
+// differ from those used in previous collection
+func (c *collector) checkAuthChange(cfg interface{}) error {
+	opts, err := getAuthOpts(cfg)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{opts.Endpoint, opts.User, opts.Password, opts.UserDomainName, opts.UserDomainID, opts.ProjectDomainName, opts.ProjectDomainID, opts.SystemScope, opts.DomainScopeName, opts.DomainScopeID, strconv.FormatBool(opts.DisableReauth), opts.CinderURL}, "\x00")))
+	key := hex.EncodeToString(sum[:])
+	if c.authKey != "" && c.authKey != key {
+		log.Printf("Endpoint or credentials changed, authenticating again")
+		c.InvalidateAuth()
+		c.allTenants = map[string]string{}
+		c.tenantDomains = nil
+		c.allLimits = map[string]types.Limits{}
+		c.allTypeLimits = map[string]map[string]types.TypeLimits{}
+		c.limitsFetched = map[string]time.Time{}
+		c.limitsETags = map[string]string{}
+		c.defaultQuota, c.defaultQuotaFetched = nil, time.Time{}
+		c.snapshotIndex = types.NewSnapshotIndex()
+		c.extraSpecs, c.extraSpecsFetched = nil, time.Time{}
+		c.lastCounts = map[string]uint{}
+		c.adminTenant, c.adminRole = "", ""
+		c.adminLimitsFailed = false
+	}
+	c.authKey = key
+	return nil
+}
+
+// getPageSize returns number of volumes and snapshots listed per page capped at maximal page size of cloud, larger
+// page would be truncated by Cinder and taken for last one. Maximal page size is used when page size is not set,
+// as single request would be truncated the same way
+func getPageSize(cfg interface{}) int {
+	pageSize := getConfigInt(cfg, "page_size", 0)
+	maxPageSize := getConfigInt(cfg, "max_page_size", defaultMaxPageSize)
+	if pageSize <= 0 {
+		return maxPageSize
+	}
+	if maxPageSize > 0 && pageSize > maxPageSize {
+		log.Printf("Page size %d exceeds maximal page size of cloud, using %d", pageSize, maxPageSize)
+		return maxPageSize
+	}
+	return pageSize
+}
+
+// getAdminTenant returns tenant used for listing volumes and snapshots of all tenants: all_tenants_project
+// or tenant when configured, otherwise project where user holds admin_role, looked up once and reused
+func (c *collector) getAdminTenant(cfg interface{}) (string, error) {
+	item, err := config.GetConfigItem(cfg, "tenant")
+	if err == nil && item.(string) != "" {
+		return getConfigString(cfg, "all_tenants_project", item.(string)), nil
+	}
+	role := getConfigString(cfg, "admin_role", "")
+	if role == "" {
+		if err == nil {
+			err = fmt.Errorf("Neither tenant nor admin_role is configured")
+		}
+		return "", err
+	}
+
+	if c.adminTenant == "" || c.adminRole != role {
+		opts, err := getAuthOpts(cfg)
 		if err != nil {
-			return err
+			return "", err
 		}
+		start := time.Now()
+		tenant, err := openstackintel.Common{}.GetRoleProject(opts, role)
+		c.keystoneTimer.since(start)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("Using project %s, where user %s holds role %s, as admin tenant", tenant, opts.User, role)
+		c.adminTenant, c.adminRole = tenant, role
+	}
+	return getConfigString(cfg, "all_tenants_project", c.adminTenant), nil
+}
+
+// authCall represents authentication to single tenant shared by all callers asking for it while it is in flight,
+// it is in flight until done is closed
+type authCall struct {
+	done     chan struct{}
+	provider *gophercloud.ProviderClient
+	service  services.Service
+	err      error
+}
+
+// authenticate returns provider and service scoped to given tenant, authenticating only when those are not known yet
+// Collections authenticate concurrently, so each call uses returned service instead of one shared by collector.
+// Different tenants are authenticated in parallel, callers asking for tenant being authenticated wait for its result
+func (c *collector) authenticate(cfg interface{}, tenant string) (*gophercloud.ProviderClient, services.Service, error) {
+	c.authMutex.Lock()
+	if provider, found := c.providers[tenant]; found {
+		service := c.services[tenant]
+		c.authMutex.Unlock()
+		return provider, service, nil
+	}
+	if call, found := c.authCalls[tenant]; found {
+		c.authMutex.Unlock()
+		<-call.done
+		return call.provider, call.service, call.err
+	}
+	call := &authCall{done: make(chan struct{})}
+	c.authCalls[tenant] = call
+	c.authMutex.Unlock()
+
+	call.provider, call.service, call.err = c.authenticateTenant(cfg, tenant)
+
+	// failed authentication is not kept, provider authenticated before authentication was invalidated is dropped
+	c.authMutex.Lock()
+	if c.authCalls[tenant] == call {
+		delete(c.authCalls, tenant)
+		if call.err == nil {
+			c.providers[tenant] = call.provider
+			c.services[tenant] = call.service
+		}
+	}
+	c.authMutex.Unlock()
+	close(call.done)
+
+	return call.provider, call.service, call.err
+}
 
-		endpoint := items["endpoint"].(string)
-		user := items["user"].(string)
-		password := items["password"].(string)
-		dom_name, _ := config.GetConfigItem(cfg, "domain_name")
-		dom_id, _ := config.GetConfigItem(cfg, "domain_id")
-		if dom_name != nil {
-			domain_name = dom_name.(string)
+// authenticateTenant authenticates provider and dispatches service scoped to given tenant
+func (c *collector) authenticateTenant(cfg interface{}, tenant string) (*gophercloud.ProviderClient, services.Service, error) {
+	opts, err := getAuthOpts(cfg)
+	if err != nil {
+		return nil, services.Service{}, err
+	}
+	// system scoped provider is kept under dedicated key, others are scoped to given tenant
+	if tenant != systemScopeKey {
+		opts.Tenant = tenant
+		opts.SystemScope = ""
+	}
+	// names of projects sharing name across domains are not known by Keystone, those are scoped by ID
+	if strings.Contains(tenant, openstackintel.DomainSeparator) {
+		if id, found := tenantIDsByName(c.allTenants)[tenant]; found {
+			opts.Tenant, opts.TenantID = "", id
 		}
-		if dom_id != nil {
-			domain_id = dom_id.(string)
+	}
+	if opts.CinderURL, err = expandCinderURL(opts.CinderURL, c.allTenants, tenant); err != nil {
+		return nil, services.Service{}, err
+	}
+
+	start := time.Now()
+	provider, err := openstackintel.Authenticate(opts)
+	c.keystoneTimer.since(start)
+	if err != nil {
+		return nil, services.Service{}, err
+	}
+	// dispatch API version based on priority, versions are listed from block storage endpoint
+	start = time.Now()
+	service, err := services.Dispatch(provider)
+	c.cinderTimer.since(start)
+	if err != nil {
+		// Cinder missing in catalog is reported by meta/catalog_ok too
+		if _, ok := err.(*openstackintel.CatalogError); ok {
+			atomic.StoreUint32(&c.catalogMissing, 1)
 		}
+		return nil, services.Service{}, err
+	}
+	service.CountCalls(c.apiCalls)
 
-		provider, err := openstackintel.Authenticate(endpoint, user, password, tenant, domain_name, domain_id)
-		if err != nil {
-			return err
+	return provider, service, nil
+}
+
+// cinderVersion returns Cinder version derived from version discovery by service scoped to given project,
+// empty version is returned when project cannot be authenticated, as version tag is optional
+func (c *collector) cinderVersion(cfg interface{}, project string) string {
+	_, service, err := c.authenticate(cfg, project)
+	if err != nil {
+		log.Printf("Cannot resolve Cinder version with project %s: %v", project, err)
+		return ""
+	}
+	return service.Version()
+}
+
+// transports holds HTTP transports by their timeouts, so connections are reused across tenants
+var transports = struct {
+	sync.Mutex
+	byOpts map[openstackintel.TransportOpts]*http.Transport
+}{byOpts: map[openstackintel.TransportOpts]*http.Transport{}}
+
+// getTransport returns shared HTTP transport with timeouts (in seconds) read from configuration
+func getTransport(cfg interface{}) *http.Transport {
+	opts := openstackintel.TransportOpts{
+		Dial:           time.Duration(getConfigInt(cfg, "dial_timeout", defaultDialTimeout)) * time.Second,
+		TLSHandshake:   time.Duration(getConfigInt(cfg, "tls_handshake_timeout", defaultTLSHandshakeTimeout)) * time.Second,
+		ResponseHeader: time.Duration(getConfigInt(cfg, "response_header_timeout", defaultResponseHeaderTimeout)) * time.Second,
+		IdleConn:       time.Duration(getConfigInt(cfg, "idle_conn_timeout", defaultIdleConnTimeout)) * time.Second,
+	}
+
+	transports.Lock()
+	defer transports.Unlock()
+	transport, found := transports.byOpts[opts]
+	if !found {
+		transport = openstackintel.NewTransport(opts)
+		transports.byOpts[opts] = transport
+	}
+	return transport
+}
+
+// getAuthOpts reads Keystone endpoint and credentials from configuration
+func getAuthOpts(cfg interface{}) (openstackintel.AuthOpts, error) {
+	// get credentials and endpoint from configuration
+	items, err := config.GetConfigItems(cfg, "endpoint", "user", "password")
+	if err != nil {
+		return openstackintel.AuthOpts{}, err
+	}
+
+	userDomainName, userDomainID := getDomain(cfg, "user_")
+	projectDomainName, projectDomainID := getDomain(cfg, "project_")
+
+	// in domain scope projects are enumerated with domain scoped token and looked up in that domain,
+	// unless domain of projects is configured
+	scope, err := getCollectionScope(cfg)
+	if err != nil {
+		return openstackintel.AuthOpts{}, err
+	}
+	scopeDomainName, scopeDomainID := "", ""
+	if scope == domainScope {
+		scopeDomainName, scopeDomainID = getDomain(cfg, "scope_")
+		if getConfigString(cfg, "project_domain_name", "") == "" && getConfigString(cfg, "project_domain_id", "") == "" {
+			projectDomainName, projectDomainID = scopeDomainName, scopeDomainID
 		}
-		// set provider and dispatch API version based on priority
-		c.providers[tenant] = provider
-		c.service = services.Dispatch(provider)
+	}
 
-		// set Commoner interface
-		c.common = openstackintel.Common{}
+	cinderURL := getConfigString(cfg, "cinder_url", "")
+	if err := validateCinderURL(cinderURL); err != nil {
+		return openstackintel.AuthOpts{}, err
 	}
 
+	return openstackintel.AuthOpts{
+		Transport:         getTransport(cfg),
+		Endpoint:          items["endpoint"].(string),
+		User:              items["user"].(string),
+		Password:          items["password"].(string),
+		UserDomainName:    userDomainName,
+		UserDomainID:      userDomainID,
+		ProjectDomainName: projectDomainName,
+		ProjectDomainID:   projectDomainID,
+		SystemScope:       getConfigString(cfg, "system_scope", ""),
+		DomainScopeName:   scopeDomainName,
+		DomainScopeID:     scopeDomainID,
+		DisableReauth:     !getConfigBool(cfg, "allow_reauth", true),
+		CinderURL:         cinderURL,
+	}, nil
+}
+
+// validateCinderURL checks that configured Cinder endpoint is absolute HTTP(S) URL, empty URL is valid
+// as it means endpoint is looked up in service catalog
+func validateCinderURL(cinderURL string) error {
+	if cinderURL == "" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(cinderURL, projectIDPlaceholder, "project", -1))
+	if err != nil {
+		return fmt.Errorf("Invalid cinder_url %q: %v", cinderURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid cinder_url %q: absolute http or https URL expected", cinderURL)
+	}
 	return nil
 }
 
+// expandCinderURL substitutes ID of given tenant for project ID placeholder of configured Cinder endpoint
+func expandCinderURL(cinderURL string, tenants map[string]string, tenant string) (string, error) {
+	if !strings.Contains(cinderURL, projectIDPlaceholder) {
+		return cinderURL, nil
+	}
+	id, found := tenantIDsByName(tenants)[tenant]
+	if !found {
+		return "", fmt.Errorf("ID of project %s is not known, it cannot be substituted in cinder_url", tenant)
+	}
+	return strings.Replace(cinderURL, projectIDPlaceholder, id, -1), nil
+}
+
+// getDomain returns name and ID of domain configured with given prefix (user_ or project_), falling back
+// to domain_name and domain_id shared by user and project when neither of prefixed ones is set
+func getDomain(cfg interface{}, prefix string) (string, string) {
+	name, id := getConfigString(cfg, prefix+"domain_name", ""), getConfigString(cfg, prefix+"domain_id", "")
+	if name == "" && id == "" {
+		return getConfigString(cfg, "domain_name", ""), getConfigString(cfg, "domain_id", "")
+	}
+	return name, id
+}
+
+// checkTenantsVisibility warns when fewer tenants than expected are visible, which usually means account used
+// for listing lacks role needed to enumerate all projects and metrics are collected only for a subset of them.
+// It reports whether enough tenants are visible, failed check is reported by meta/tenants_visible_ok metric
+func checkTenantsVisibility(cfg interface{}, tenants map[string]string) bool {
+	if !tenantsVisible(cfg, tenants) {
+		log.Printf("WARNING: only %d tenants visible while at least %d expected, check roles of user %q",
+			len(tenants), getConfigInt(cfg, "expected_min_tenants", 0), getConfigString(cfg, "user", ""))
+		return false
+	}
+	return true
+}
+
+// tenantsVisible reports whether at least expected_min_tenants tenants are visible
+func tenantsVisible(cfg interface{}, tenants map[string]string) bool {
+	return len(tenants) >= getConfigInt(cfg, "expected_min_tenants", 0)
+}
+
+// getExtraVolumeFields returns volume payload fields by metric names, configured as comma separated list
+// of name=field pairs (ex. "migrations=os-vol-mig-status-attr:count")
+func getExtraVolumeFields(cfg interface{}) map[string]string {
+	fields := map[string]string{}
+	for _, pair := range strings.Split(getConfigString(cfg, "extra_volume_fields", ""), ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		metric, field := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if metric != "" && field != "" {
+			fields[metric] = field
+		}
+	}
+	return fields
+}
+
+// getStaticTags returns tags attached to all emitted metrics, configured as comma separated list of key=value
+// pairs (ex. "datacenter=dc1,environment=prod")
+func getStaticTags(cfg interface{}) map[string]string {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(getConfigString(cfg, "static_tags", ""), ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if key != "" {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// tenantIDsByName returns tenant IDs keyed by tenant names, names are unique as returned by GetTenants
+func tenantIDsByName(tenants map[string]string) map[string]string {
+	ids := make(map[string]string, len(tenants))
+	for id, tenantName := range tenants {
+		ids[tenantName] = id
+	}
+	return ids
+}
+
+// sortedKeys returns keys of map in ascending order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedCountKeys returns keys of counts in ascending order
+func sortedCountKeys(m map[string]uint) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedRatioKeys returns keys of ratios in ascending order
+func sortedRatioKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedAgeKeys returns keys of ages in ascending order
+func sortedAgeKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedHeartbeatHosts returns hosts of services heartbeats in ascending order
+func sortedHeartbeatHosts(m map[string]map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedGroupKeys returns values of groups in ascending order
+func sortedGroupKeys(m map[string]types.Group) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getGroupFields returns fields which volumes or snapshots (given by family) are grouped by, configured
+// as comma-separated list in group_<family>_by, fields which family can not be grouped by are skipped
+func getGroupFields(cfg interface{}, family string) []string {
+	allowed := types.VolumeGroupFields
+	if family == "snapshots" {
+		allowed = types.SnapshotGroupFields
+	}
+
+	fields := []string{}
+	for _, field := range getConfigList(cfg, "group_"+family+"_by") {
+		if !str.Contains(allowed, field) {
+			log.Printf("WARNING: %s can not be grouped by %q, supported fields: %s", family, field, strings.Join(allowed, ", "))
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// getSizeBuckets returns ascending upper bounds in GB of volume size buckets, configured as comma-separated list,
+// invalid and non-positive bounds are skipped
+func getSizeBuckets(cfg interface{}) []int {
+	bounds := []int{}
+	for _, element := range strings.Split(getConfigString(cfg, "volume_size_buckets", defaultSizeBuckets), ",") {
+		element = strings.TrimSpace(element)
+		bound, err := strconv.Atoi(element)
+		if err != nil || bound <= 0 {
+			log.Printf("WARNING: skipping invalid volume size bucket bound %q", element)
+			continue
+		}
+		if !intsContain(bounds, bound) {
+			bounds = append(bounds, bound)
+		}
+	}
+	sort.Ints(bounds)
+	return bounds
+}
+
+// intsContain checks if value is among given ones
+func intsContain(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getConfigList returns elements of comma-separated configuration item, empty when not configured
+func getConfigList(cfg interface{}, name string) []string {
+	elements := []string{}
+	for _, element := range strings.Split(getConfigString(cfg, name, ""), ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// getQuotaVolumeTypes returns volume types which quotas are collected, configured as comma-separated list
+func getQuotaVolumeTypes(cfg interface{}) []string {
+	return getConfigList(cfg, "quota_volume_types")
+}
+
+// getProjects returns list of projects configured for project scoped collection, empty when not configured
+func getProjects(cfg interface{}) []string {
+	return getConfigList(cfg, "projects")
+}
+
+// getCollectionScope returns scope which volumes and snapshots are collected with, all tenants scope by default,
+// domain scope requires domain to be configured
+func getCollectionScope(cfg interface{}) (string, error) {
+	scope := getConfigString(cfg, "collection_scope", allTenantsScope)
+	switch scope {
+	case allTenantsScope, perTenantScope:
+	case domainScope:
+		if name, id := getDomain(cfg, "scope_"); name == "" && id == "" {
+			return "", fmt.Errorf("Collection scope %q requires scope_domain_name or scope_domain_id", domainScope)
+		}
+	default:
+		return "", fmt.Errorf("Unsupported collection scope %q, use %q, %q or %q", scope, allTenantsScope, perTenantScope, domainScope)
+	}
+	return scope, nil
+}
+
 func getTenants(cfg interface{}) (map[string]string, error) {
-	items, err := config.GetConfigItems(cfg, "endpoint", "user", "password")
-	domain_name := ""
-	domain_id := ""
+	// configured projects are used as they are, so listing tenants (which may require admin) is not needed
+	if projects := getProjects(cfg); len(projects) > 0 {
+		tenants := map[string]string{}
+		for _, project := range projects {
+			tenants[project] = project
+		}
+		return tenants, nil
+	}
+
+	opts, err := getAuthOpts(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint := items["endpoint"].(string)
-	user := items["user"].(string)
-	password := items["password"].(string)
-	dom_name, _ := config.GetConfigItem(cfg, "domain_name")
-	dom_id, _ := config.GetConfigItem(cfg, "domain_id")
-	if dom_name != nil {
-		domain_name = dom_name.(string)
+	// optionally limit tenants to projects carrying all given tags
+	tags := getTenantTags(cfg)
+
+	// retrieve list of all available tenants for provided endpoint, user and password,
+	// retrying on failure as whole collection depends on it. Listing is shared by concurrent callers
+	// and reused for a short time, as snap may ask for metric types repeatedly
+	cmn := openstackintel.Common{}
+	key := tenantsCacheKey("tenants", opts, tags)
+	ttl := time.Duration(getConfigInt(cfg, "tenants_cache_ttl", defaultTenantsCacheTTL)) * time.Second
+	allTenants, err := cachedTenants(key, ttl, func() (map[string]string, error) {
+		var tenants map[string]string
+		err := getRetryPolicy(cfg).do(func() error {
+			var e error
+			tenants, e = cmn.GetTenants(opts, tags)
+			return e
+		})
+		return tenants, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// optionally limit tenants to projects which name matches any of given patterns, catalog and collection
+	// both rely on returned tenants so they stay consistent
+	return filterTenantNames(allTenants, getConfigList(cfg, "tenant_name_filter"))
+}
+
+// getTenantTags returns tags configured by tenant_tag_filter, which listed projects have to carry
+func getTenantTags(cfg interface{}) []string {
+	tags := []string{}
+	if tagFilter := getConfigString(cfg, "tenant_tag_filter", ""); tagFilter != "" {
+		for _, tag := range strings.Split(tagFilter, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// tenantsCacheKey returns key of given listing (tenants or domains) in tenants cache, listings differing in any
+// option affecting which projects are visible are cached separately
+func tenantsCacheKey(listing string, opts openstackintel.AuthOpts, tags []string) string {
+	return strings.Join([]string{listing, opts.Endpoint, opts.User, opts.UserDomainName, opts.UserDomainID, opts.ProjectDomainName, opts.ProjectDomainID, opts.SystemScope, opts.DomainScopeName, opts.DomainScopeID, strings.Join(tags, ",")}, "|")
+}
+
+// getTenantDomains returns domain ID of listed tenants by tenant ID when include_domain_namespace is enabled,
+// nil when it is not, when projects are configured or flat layout is used, or when tenants listing is not domain aware.
+// Domains are listed with the same retries and sharing as tenants
+func getTenantDomains(cfg interface{}) (map[string]string, error) {
+	if !getConfigBool(cfg, "include_domain_namespace", false) || len(getProjects(cfg)) > 0 {
+		return nil, nil
 	}
-	if dom_id != nil {
-		domain_id = dom_id.(string)
+	if layout, err := getNamespaceLayout(cfg); err != nil || layout != nestedLayout {
+		return nil, err
 	}
 
-	// retrieve list of all available tenants for provided endpoint, user and password
-	cmn := openstackintel.Common{}
-	allTenants, err := cmn.GetTenants(endpoint, user, password, domain_name, domain_id)
+	opts, err := getAuthOpts(cfg)
 	if err != nil {
 		return nil, err
 	}
+	tags := getTenantTags(cfg)
 
-	return allTenants, nil
+	cmn := openstackintel.Common{}
+	key := tenantsCacheKey("domains", opts, tags)
+	ttl := time.Duration(getConfigInt(cfg, "tenants_cache_ttl", defaultTenantsCacheTTL)) * time.Second
+	return cachedTenants(key, ttl, func() (map[string]string, error) {
+		var domains map[string]string
+		err := getRetryPolicy(cfg).do(func() error {
+			var e error
+			domains, e = cmn.GetTenantDomains(opts, tags)
+			return e
+		})
+		return domains, err
+	})
+}
+
+// keepTenants returns volumes and snapshots (by tenant ID) of given tenants only, nil ones are kept nil
+func keepTenants(volumes map[string]types.Volumes, snapshots map[string]types.Snapshots, tenants map[string]string) (map[string]types.Volumes, map[string]types.Snapshots) {
+	var keptVolumes map[string]types.Volumes
+	if volumes != nil {
+		keptVolumes = map[string]types.Volumes{}
+		for tenantID, v := range volumes {
+			if _, found := tenants[tenantID]; found {
+				keptVolumes[tenantID] = v
+			}
+		}
+	}
+	var keptSnapshots map[string]types.Snapshots
+	if snapshots != nil {
+		keptSnapshots = map[string]types.Snapshots{}
+		for tenantID, s := range snapshots {
+			if _, found := tenants[tenantID]; found {
+				keptSnapshots[tenantID] = s
+			}
+		}
+	}
+	return keptVolumes, keptSnapshots
+}
+
+// filterTenantNames returns tenants which name matches any of shell patterns (ex. "prod-*"), all tenants are returned
+// when no pattern is given. Given tenants are not modified as they may be shared by cache
+func filterTenantNames(tenants map[string]string, patterns []string) (map[string]string, error) {
+	if len(patterns) == 0 {
+		return tenants, nil
+	}
+	filtered := map[string]string{}
+	for id, name := range tenants {
+		for _, pattern := range patterns {
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid tenant name filter %q: %v", pattern, err)
+			}
+			if matched {
+				filtered[id] = name
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// roundFloat rounds value half away from zero to given number of decimal places, math.Round is not available
+// in Go versions plugin is built with
+func roundFloat(value float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	if value < 0 {
+		return -math.Floor(-value*scale+0.5) / scale
+	}
+	return math.Floor(value*scale+0.5) / scale
+}
+
+// getConfigString returns value of string configuration item or fallback when item is not set
+func getConfigString(cfg interface{}, name string, fallback string) string {
+	item, err := config.GetConfigItem(cfg, name)
+	if err != nil {
+		return fallback
+	}
+	value, ok := item.(string)
+	if !ok {
+		return fallback
+	}
+	return value
+}
+
+// getConfigBool returns value of boolean configuration item or fallback when item is not set
+func getConfigBool(cfg interface{}, name string, fallback bool) bool {
+	item, err := config.GetConfigItem(cfg, name)
+	if err != nil {
+		return fallback
+	}
+	value, ok := item.(bool)
+	if !ok {
+		return fallback
+	}
+	return value
+}
+
+// getConfigInt returns value of integer configuration item or fallback when item is not set
+func getConfigInt(cfg interface{}, name string, fallback int) int {
+	item, err := config.GetConfigItem(cfg, name)
+	if err != nil {
+		return fallback
+	}
+	value, ok := item.(int)
+	if !ok {
+		return fallback
+	}
+	return value
 }
@@ -17,12 +17,19 @@ limitations under the License.
 package collector
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/rackspace/gophercloud"
 	th "github.com/rackspace/gophercloud/testhelper"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/suite"
@@ -32,22 +39,49 @@ import (
 	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/ctypes"
 
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
 	"github.com/intelsdi-x/snap-plugin-utilities/str"
 )
 
 type CollectorSuite struct {
 	suite.Suite
-	Token                                    string
-	V1, V2                                   string
-	LimitsV2                                 string
-	Tenant1Name, Tenant2Name                 string
-	Tenant1ID, Tenant2ID                     string
-	MaxTotalVolumeGigabytes, MaxTotalVolumes int
-	Vol1, Vol2                               string
-	Vol1Size, Vol2Size                       int
-	VolMeta                                  string
-	SnapShotSize                             int
-	server                                   *httptest.Server
+	Token                                     string
+	V1, V2                                    string
+	LimitsV2                                  string
+	Tenant1Name, Tenant2Name                  string
+	Tenant1ID, Tenant2ID                      string
+	MaxTotalVolumeGigabytes, MaxTotalVolumes  int
+	Vol1, Vol2                                string
+	Vol1TenantID                              string
+	Vol1Size, Vol2Size                        int
+	VolMeta                                   string
+	SnapShotSize                              int
+	LimitsCalls, VolumesCalls, SnapshotsCalls int
+	VolumesAllTenants                         string
+	VolumesProjects                           []string
+	SlowProject                               string
+	SnapshotVolumeID                          string
+	FailingProject                            string
+	LimitsETag                                string
+	LimitsNotModified                         int
+	VolumesLimit                              string
+	CatalogVolumeType                         string
+	SnapshotsFail                             bool
+	VolumesFail                               bool
+	QuotaSetsForbidden                        bool
+	QuotaSetsFail                             bool
+	QuotaUsageBare                            bool
+	QuotaSetsCalls                            int
+	DefaultQuotaCalls                         int
+	ExtraSpecsCalls                           int
+	TrackInFlight                             bool
+	InFlight, MaxInFlight                     int
+	inFlightMutex                             sync.Mutex
+	PrefixedRootCalls                         int
+	StalledTenant                             string
+	UnauthorizedTenant                        string
+	StalledTokens                             chan struct{}
+	server                                    *httptest.Server
 }
 
 func (s *CollectorSuite) SetupSuite() {
@@ -61,6 +95,8 @@ func (s *CollectorSuite) SetupSuite() {
 	s.V1 = "v1/v1ffff"
 	s.V2 = "v2/v2ffff"
 	s.Token = "2ed210f132564f21b178afb197ee99e3"
+	s.CatalogVolumeType = "volumev2"
+	s.SnapshotVolumeID = "495a1698-ca2f-4e84-8d34-fa544c65ae3d"
 	registerIdentityToken(s, router)
 	s.Tenant1Name = "admin"
 	s.Tenant2Name = "demo"
@@ -72,11 +108,16 @@ func (s *CollectorSuite) SetupSuite() {
 	registerCinderLimits(s)
 	s.Vol1 = "vol1id_123"
 	s.Vol2 = "vol2id_321"
+	s.Vol1TenantID = s.Tenant1ID
 	s.Vol1Size = 11
 	s.Vol2Size = 22
 	registerCinderVolumes(s)
 	s.SnapShotSize = 5
 	registerCinderSnapshots(s)
+	registerCinderVolumeGroups(s)
+	registerCinderQoSSpecs(s)
+	registerCinderPools(s)
+	registerCinderServices(s)
 }
 
 func (s *CollectorSuite) TearDownSuite() {
@@ -103,11 +144,14 @@ func (s *CollectorSuite) TestGetMetricTypes() {
 
 				}
 
-				So(len(mts), ShouldEqual, 12)
+				So(len(mts), ShouldEqual, 125)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/snapshots/count"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/snapshots/bytes"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/count"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/bytes"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/inuse_gb"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/available_gb"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/inconsistent_attachment"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/limits/MaxTotalVolumeGigabytes"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/limits/MaxTotalVolumes"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/admin/volumes/count"), ShouldBeTrue)
@@ -116,61 +160,3185 @@ func (s *CollectorSuite) TestGetMetricTypes() {
 				So(str.Contains(metricNames, "/intel/openstack/cinder/admin/limits/MaxTotalVolumes"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/admin/snapshots/count"), ShouldBeTrue)
 				So(str.Contains(metricNames, "/intel/openstack/cinder/admin/snapshots/bytes"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/_cloud/tenants/count"), ShouldBeTrue)
+			})
+
+			Convey("and metric types are ordered by tenant name and namespace", func() {
+				namespaces := make([]string, 0, len(mts))
+				for _, m := range mts {
+					namespaces = append(namespaces, m.Namespace().String())
+				}
+				So(namespaces[0], ShouldStartWith, "/intel/openstack/cinder/_cloud/")
+				So(namespaces[len(namespaces)-1], ShouldStartWith, "/intel/openstack/cinder/demo/")
+				for i := 1; i < len(mts); i++ {
+					if mts[i-1].Namespace()[3].Value == mts[i].Namespace()[3].Value {
+						So(namespaces[i-1] < namespaces[i], ShouldBeTrue)
+					}
+				}
+
+				again, err := collector.GetMetricTypes(cfg)
+				So(err, ShouldBeNil)
+				So(again, ShouldHaveLength, len(mts))
+				for i := range again {
+					So(again[i].Namespace().String(), ShouldEqual, namespaces[i])
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetrics() {
+
+	Convey("Given set of metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m1 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumeGigabytes"),
+			Config_:    cfg.ConfigDataNode}
+		m2 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+		m3 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "bytes"),
+			Config_:    cfg.ConfigDataNode}
+		m4 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "tenants", "count"),
+			Config_:    cfg.ConfigDataNode}
+		m5 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"),
+			Config_:    cfg.ConfigDataNode}
+		m6 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "gigabytes_total"),
+			Config_:    cfg.ConfigDataNode}
+		m7 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "keystone_latency_ms"),
+			Config_:    cfg.ConfigDataNode}
+		m8 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "cinder_latency_ms"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When ColelctMetrics() is called", func() {
+			collector := New()
+
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m1, m2, m3, m4, m5, m6, m7, m8})
+
+			Convey("Then no error should be reported", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("and proper metric types are returned", func() {
+				metricNames := map[string]interface{}{}
+				for _, m := range mts {
+					ns := m.Namespace().String()
+					metricNames[ns] = m.Data()
+					fmt.Println(ns, "=", m.Data())
+				}
+
+				So(len(mts), ShouldEqual, 8)
+				for _, m := range mts {
+					So(m.Tags()["cinder_version"], ShouldEqual, "3.59")
+				}
+
+				val, ok := metricNames["/intel/openstack/cinder/demo/limits/MaxTotalVolumeGigabytes"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldEqual, s.MaxTotalVolumeGigabytes)
+
+				val, ok = metricNames["/intel/openstack/cinder/demo/volumes/count"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldEqual, 1)
+
+				val, ok = metricNames["/intel/openstack/cinder/demo/snapshots/bytes"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldEqual, s.SnapShotSize*1024*1024*1024)
+
+				val, ok = metricNames["/intel/openstack/cinder/_cloud/tenants/count"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldEqual, 2)
+
+				val, ok = metricNames["/intel/openstack/cinder/_cloud/volumes/total"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldEqual, 2)
+
+				val, ok = metricNames["/intel/openstack/cinder/_cloud/volumes/gigabytes_total"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldEqual, s.Vol1Size+s.Vol2Size)
+
+				val, ok = metricNames["/intel/openstack/cinder/_cloud/meta/keystone_latency_ms"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldBeGreaterThan, 0)
+
+				val, ok = metricNames["/intel/openstack/cinder/_cloud/meta/cinder_latency_ms"]
+				So(ok, ShouldBeTrue)
+				So(val, ShouldBeGreaterThan, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsOnChangeOnly() {
+
+	Convey("Given set of metric types and emit_on_change_only enabled", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("emit_on_change_only", ctypes.ConfigValueBool{Value: true})
+		m1 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+		m2 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "bytes"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called twice", func() {
+			collector := New()
+
+			first, err1 := collector.CollectMetrics([]plugin.MetricType{m1, m2})
+			second, err2 := collector.CollectMetrics([]plugin.MetricType{m1, m2})
+
+			Convey("Then no error should be reported", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+			})
+
+			Convey("and all metrics are emitted in first interval only", func() {
+				So(len(first), ShouldEqual, 2)
+				So(len(second), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsLimitsCache() {
+
+	Convey("Given limits metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			Config_:    cfg.ConfigDataNode}
+		fromCache := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "from_cache"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called twice with default configuration", func() {
+			collector := New()
+			calls := s.LimitsCalls
+			first, err1 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+			second, err2 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+
+			Convey("Then limits are fetched once", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-calls, ShouldEqual, 1)
+			})
+
+			Convey("and limits served from cache are marked", func() {
+				So(len(first), ShouldEqual, 2)
+				So(len(second), ShouldEqual, 2)
+				So(first[1].Data(), ShouldEqual, 0)
+				So(second[1].Data(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When CollectMetrics() is called after limits were cached", func() {
+			cacheAge := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "cache_age_seconds"),
+				Config_:    cfg.ConfigDataNode}
+			collector := New()
+			first, err1 := collector.CollectMetrics([]plugin.MetricType{cacheAge})
+			collector.limitsFetched["demo"] = time.Now().Add(-90 * time.Second)
+			second, err2 := collector.CollectMetrics([]plugin.MetricType{cacheAge})
+
+			Convey("Then age of cached limits is reported", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(first, ShouldHaveLength, 1)
+				So(second, ShouldHaveLength, 1)
+				So(first[0].Data(), ShouldEqual, 0)
+				So(second[0].Data(), ShouldEqual, 90)
+				So(second[0].Unit(), ShouldEqual, "s")
+			})
+		})
+
+		Convey("When CollectMetrics() is called after cached limits expired", func() {
+			cfg.AddItem("limits_ttl", ctypes.ConfigValueInt{Value: 60})
+			cacheAge := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "cache_age_seconds"),
+				Config_:    cfg.ConfigDataNode}
+			collector := New()
+			_, err1 := collector.CollectMetrics([]plugin.MetricType{cacheAge})
+			collector.limitsFetched["demo"] = time.Now().Add(-30 * time.Second)
+			cached, err2 := collector.CollectMetrics([]plugin.MetricType{cacheAge})
+			collector.limitsFetched["demo"] = time.Now().Add(-90 * time.Second)
+			refreshed, err3 := collector.CollectMetrics([]plugin.MetricType{cacheAge})
+			again, err4 := collector.CollectMetrics([]plugin.MetricType{cacheAge})
+
+			Convey("Then age is reset by refresh", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(err3, ShouldBeNil)
+				So(err4, ShouldBeNil)
+				So(cached[0].Data(), ShouldEqual, 30)
+				So(refreshed[0].Data(), ShouldEqual, 0)
+				So(again[0].Data(), ShouldBeLessThan, 60)
+			})
+		})
+
+		Convey("When CollectMetrics() is called twice with cache_limits disabled", func() {
+			cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: false})
+			collector := New()
+			calls := s.LimitsCalls
+			_, err1 := collector.CollectMetrics([]plugin.MetricType{m})
+			_, err2 := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then limits are fetched on each collection", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When CollectMetrics() is called twice with cache_limits disabled and ETag reported by Cinder", func() {
+			cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: false})
+			m.Config_, fromCache.Config_ = cfg.ConfigDataNode, cfg.ConfigDataNode
+			s.LimitsETag = `"limits-1"`
+			defer func() { s.LimitsETag = "" }()
+			collector := New()
+			notModified := s.LimitsNotModified
+			first, err1 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+			second, err2 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+
+			Convey("Then limits are refetched conditionally and unchanged limits are kept", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsNotModified-notModified, ShouldEqual, 1)
+				So(first, ShouldHaveLength, 2)
+				So(second, ShouldHaveLength, 2)
+				So(second[0].Data(), ShouldEqual, first[0].Data())
+				So(second[1].Data(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When CollectMetrics() is called after cached limits expired and ETag reported by Cinder", func() {
+			cfg.AddItem("limits_ttl", ctypes.ConfigValueInt{Value: 60})
+			m.Config_, fromCache.Config_ = cfg.ConfigDataNode, cfg.ConfigDataNode
+			s.LimitsETag = `"limits-1"`
+			defer func() { s.LimitsETag = "" }()
+			collector := New()
+			notModified := s.LimitsNotModified
+			first, err1 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+			collector.limitsFetched["demo"] = time.Now().Add(-90 * time.Second)
+			second, err2 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+			third, err3 := collector.CollectMetrics([]plugin.MetricType{m, fromCache})
+
+			Convey("Then expired limits are refreshed conditionally and kept for another TTL", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(err3, ShouldBeNil)
+				So(s.LimitsNotModified-notModified, ShouldEqual, 1)
+				So(second[0].Data(), ShouldEqual, first[0].Data())
+				So(second[1].Data(), ShouldEqual, 0)
+				So(third[1].Data(), ShouldEqual, 1)
+				So(time.Since(collector.limitsFetched["demo"]), ShouldBeLessThan, time.Minute)
+			})
+		})
+
+		Convey("When CollectMetrics() is called twice with conditional_limits disabled", func() {
+			cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: false})
+			cfg.AddItem("conditional_limits", ctypes.ConfigValueBool{Value: false})
+			m.Config_ = cfg.ConfigDataNode
+			s.LimitsETag = `"limits-1"`
+			defer func() { s.LimitsETag = "" }()
+			collector := New()
+			notModified := s.LimitsNotModified
+			_, err1 := collector.CollectMetrics([]plugin.MetricType{m})
+			_, err2 := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then limits are fetched unconditionally", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsNotModified-notModified, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsTenantsOverQuota() {
+
+	Convey("Given tenants over quota metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: false})
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", defaultCloudNamespace, "tenants", "over_quota"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+			metricNames := []string{}
+			for _, mt := range mts {
+				metricNames = append(metricNames, mt.Namespace().String())
+			}
+
+			Convey("Then metric is listed under tenants element of cloud namespace", func() {
+				So(err, ShouldBeNil)
+				So(metricNames, ShouldContain, "/intel/openstack/cinder/_cloud/tenants/over_quota")
+				So(metricNames, ShouldNotContain, "/intel/openstack/cinder/_cloud/tenants_over_quota")
+			})
+		})
+
+		Convey("When no tenant reached its quota", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then zero is returned", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Data(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When all tenants reached volumes quota", func() {
+			maxTotalVolumes := s.MaxTotalVolumes
+			s.MaxTotalVolumes = 2
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+			s.MaxTotalVolumes = maxTotalVolumes
+
+			Convey("Then all tenants are counted", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Data(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When all tenants have unlimited volumes quota", func() {
+			maxTotalVolumes := s.MaxTotalVolumes
+			s.MaxTotalVolumes = -1
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+			s.MaxTotalVolumes = maxTotalVolumes
+
+			Convey("Then no tenant is counted", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Data(), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsDuplicatedTenantNames() {
+
+	Convey("Given tenants sharing name across domains", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m1 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo@default", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+		m2 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo@d2", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			collector.allTenants = map[string]string{s.Tenant1ID: "demo@d2", s.Tenant2ID: "demo@default"}
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m1, m2})
+
+			Convey("Then volumes are attributed to each tenant by ID", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 2)
+				for _, m := range mts {
+					So(m.Data(), ShouldEqual, 1)
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestDomainNamespace() {
+
+	Convey("Given config including domain in namespace", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("include_domain_namespace", ctypes.ConfigValueBool{Value: true})
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "default", "admin", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "unknown", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "tenants", "count"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called with domains of tenants known", func() {
+			collector := New()
+			collector.tenantDomains = map[string]string{"admin_id123": "default"}
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then tenant metrics are emitted under domain of tenant and cloud-wide ones without it", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/default/admin/volumes/count": uint(1),
+					"/intel/openstack/cinder/unknown/demo/volumes/count":  uint(1),
+					"/intel/openstack/cinder/_cloud/tenants/count":        uint(2),
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called with namespace lacking domain", func() {
+			collector := New()
+			collector.tenantDomains = map[string]string{"admin_id123": "default"}
+			_, err := collector.CollectMetrics([]plugin.MetricType{
+				{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "admin", "volumes", "count"), Config_: cfg.ConfigDataNode},
+			})
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "including domain")
+			})
+		})
+
+		Convey("When GetMetricTypes() is called with tenants listed from Keystone v2", func() {
+			collector := New()
+			catalog, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then domains are not known and namespaces are left without domain", func() {
+				So(err, ShouldBeNil)
+				So(collector.tenantDomains, ShouldBeNil)
+				So(len(catalog), ShouldEqual, 125)
+			})
+		})
+	})
+
+	Convey("Given nested namespaces of catalog", s.T(), func() {
+		domains := domainsByTenantName(map[string]string{"admin_id123": "admin", "demo_id123": "demo"}, map[string]string{"demo_id123": "d2"})
+		tenant := core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count")
+		cloud := core.NewNamespace("intel", "openstack", "cinder", "_cloud", "tenants", "count")
+
+		Convey("Then domain is inserted before tenant and removed again", func() {
+			So(domains, ShouldResemble, map[string]string{"admin": unknownDomain, "demo": "d2"})
+			inserted := insertDomain(tenant, domains, "_cloud")
+			So(inserted.String(), ShouldEqual, "/intel/openstack/cinder/d2/demo/volumes/count")
+			removed, err := removeDomain(inserted, "_cloud")
+			So(err, ShouldBeNil)
+			So(removed.String(), ShouldEqual, tenant.String())
+		})
+
+		Convey("Then cloud-wide namespaces are left unchanged", func() {
+			So(insertDomain(cloud, domains, "_cloud").String(), ShouldEqual, cloud.String())
+			removed, err := removeDomain(cloud, "_cloud")
+			So(err, ShouldBeNil)
+			So(removed.String(), ShouldEqual, cloud.String())
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsCollectionMode() {
+
+	Convey("Given volumes and snapshots metric types and snapshots failing to be listed", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		volumes := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+		snapshots := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "count"),
+			Config_:    cfg.ConfigDataNode}
+		s.SnapshotsFail = true
+		defer func() { s.SnapshotsFail = false }()
+
+		Convey("When CollectMetrics() is called with default configuration", func() {
+			collector := New()
+			_, err := collector.CollectMetrics([]plugin.MetricType{volumes, snapshots})
+
+			Convey("Then whole collection fails", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When CollectMetrics() is called in best-effort mode", func() {
+			cfg.AddItem("collection_mode", ctypes.ConfigValueStr{Value: "besteffort"})
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{volumes, snapshots})
+
+			Convey("Then only volumes metrics are returned", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, volumes.Namespace().String())
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestFailureThreshold() {
+
+	Convey("Given volumes of one of two tenants failing to be listed in best-effort mode", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("collection_mode", ctypes.ConfigValueStr{Value: "besteffort"})
+		cfg.AddItem("tenant_batch_size", ctypes.ConfigValueInt{Value: 1})
+		s.FailingProject = s.Tenant1ID
+		defer func() { s.FailingProject = "" }()
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "failed_tenants_percent"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "api_error_rate"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called with default configuration", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then partial results are returned with fraction of failed tenants", func() {
+				So(err, ShouldBeNil)
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				// failed volumes requests count among all requests made to Cinder
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_error_rate"], ShouldBeGreaterThan, 0)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_error_rate"], ShouldBeLessThan, 1)
+				delete(metricNames, "/intel/openstack/cinder/_cloud/meta/api_error_rate")
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/demo/volumes/count":                 uint(1),
+					"/intel/openstack/cinder/_cloud/meta/failed_tenants_percent": 50.0,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called with failure threshold exceeded", func() {
+			cfg.AddItem("failure_threshold_percent", ctypes.ConfigValueInt{Value: 40})
+			for i := range mts {
+				mts[i].Config_ = cfg.ConfigDataNode
+			}
+			collector := New()
+			_, err := collector.CollectMetrics(mts)
+
+			Convey("Then collection fails", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "failure_threshold_percent")
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestFailedTenantWaitsForOthers() {
+
+	Convey("Given limits of two tenants, one of them slow and other failing to authenticate", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{
+			{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "admin", "limits", "MaxTotalVolumes"), Config_: cfg.ConfigDataNode},
+			{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"), Config_: cfg.ConfigDataNode},
+		}
+		s.UnauthorizedTenant = "demo"
+		s.TrackInFlight, s.MaxInFlight = true, 0
+		defer func() { s.UnauthorizedTenant, s.TrackInFlight = "", false }()
+
+		Convey("When CollectMetrics() is called with default configuration", func() {
+			collector := New()
+			_, err := collector.CollectMetrics(mts)
+			s.inFlightMutex.Lock()
+			inFlight := s.InFlight
+			s.inFlightMutex.Unlock()
+
+			Convey("Then collection fails only once limits of slow tenant are not being fetched anymore", func() {
+				So(err, ShouldNotBeNil)
+				So(inFlight, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsLimitsByType() {
+
+	Convey("Given quotas of volume types are configured", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("quota_volume_types", ctypes.ConfigValueStr{Value: "ssd, hdd"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then quotas of each volume type are available", func() {
+				So(err, ShouldBeNil)
+				metricNames := []string{}
+				for _, m := range mts {
+					metricNames = append(metricNames, m.Namespace().String())
+				}
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/limits/by_type/ssd/gigabytes"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/limits/by_type/hdd/gigabytes_used"), ShouldBeTrue)
+			})
+		})
+
+		Convey("When CollectMetrics() is called", func() {
+			ssd := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "by_type", "ssd", "gigabytes_used"),
+				Config_:    cfg.ConfigDataNode}
+			hdd := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "by_type", "hdd", "gigabytes_used"),
+				Config_:    cfg.ConfigDataNode}
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{ssd, hdd})
+
+			Convey("Then types absent from quota usage are omitted", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, ssd.Namespace().String())
+				So(mts[0].Data(), ShouldEqual, 80)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsDefaultQuota() {
+
+	Convey("Given default quota metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		volumes := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", defaultCloudNamespace, "default_quota", "volumes"),
+			Config_:    cfg.ConfigDataNode}
+		gigabytes := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", defaultCloudNamespace, "default_quota", "gigabytes"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{volumes, gigabytes})
+
+			Convey("Then default quotas are returned", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 2)
+				So(mts[0].Data(), ShouldEqual, 10)
+				So(mts[1].Data(), ShouldEqual, 1000)
+			})
+		})
+
+		Convey("When CollectMetrics() is called after cached default quota expired", func() {
+			cfg.AddItem("limits_ttl", ctypes.ConfigValueInt{Value: 60})
+			collector := New()
+			calls := s.DefaultQuotaCalls
+			_, err1 := collector.CollectMetrics([]plugin.MetricType{volumes})
+			_, err2 := collector.CollectMetrics([]plugin.MetricType{volumes})
+			cached := s.DefaultQuotaCalls - calls
+			collector.defaultQuotaFetched = time.Now().Add(-90 * time.Second)
+			mts, err3 := collector.CollectMetrics([]plugin.MetricType{volumes})
+
+			Convey("Then default quota is fetched again", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(err3, ShouldBeNil)
+				So(cached, ShouldEqual, 1)
+				So(s.DefaultQuotaCalls-calls, ShouldEqual, 2)
+				So(mts[0].Data(), ShouldEqual, 10)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsVolumeTypes() {
+
+	Convey("Given accessible volume types metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volume_types", "accessible_count"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			volumesCalls, limitsCalls := s.VolumesCalls, s.LimitsCalls
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then number of volume types accessible by tenant is returned", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Data(), ShouldEqual, 2)
+				So(s.VolumesCalls-volumesCalls, ShouldEqual, 0)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for all tenants with tenant batch size", func() {
+			cfg.AddItem("tenant_batch_size", ctypes.ConfigValueInt{Value: 1})
+			mts := []plugin.MetricType{}
+			for _, tenant := range []string{"admin", "demo"} {
+				mts = append(mts, plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", tenant, "volume_types", "accessible_count"),
+					Config_:    cfg.ConfigDataNode})
+			}
+			s.TrackInFlight, s.MaxInFlight = true, 0
+			defer func() { s.TrackInFlight = false }()
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then volume types of one tenant are listed at once", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				So(s.MaxInFlight, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsSnapshotsByMetadata() {
+
+	Convey("Given snapshots grouped by metadata key", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("group_by_snapshot_metadata", ctypes.ConfigValueStr{Value: "backup"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then metric types with dynamic metadata value are returned", func() {
+				So(err, ShouldBeNil)
+				dynamic := 0
+				for _, m := range mts {
+					if ok, _ := m.Namespace().IsDynamic(); ok && m.Namespace().Strings()[5] == "by_metadata" {
+						So(m.Namespace().Strings()[6], ShouldEqual, "backup")
+						dynamic++
+					}
+				}
+				So(dynamic, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for any metadata value", func() {
+			m := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "by_metadata", "backup").
+					AddDynamicElement("value", "snapshot metadata value").
+					AddStaticElement("count"),
+				Config_: cfg.ConfigDataNode}
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then snapshots without metadata key are counted as unset", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/demo/snapshots/by_metadata/backup/unset/count")
+				So(mts[0].Data(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsGroups() {
+
+	Convey("Given volumes and snapshots grouped by several fields", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("group_volumes_by", ctypes.ConfigValueStr{Value: "status,volume_type,bootable,unknown"})
+		cfg.AddItem("group_snapshots_by", ctypes.ConfigValueStr{Value: "status"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then metric types with dynamic value are returned for supported fields only", func() {
+				So(err, ShouldBeNil)
+				groups := map[string]int{}
+				for _, m := range mts {
+					if ok, _ := m.Namespace().IsDynamic(); ok && strings.HasPrefix(m.Namespace().Strings()[5], "by_") {
+						groups[m.Namespace().Strings()[4]+"/"+m.Namespace().Strings()[5]]++
+					}
+				}
+				// count and gigabytes for each of 2 tenants
+				So(groups, ShouldResemble, map[string]int{
+					"volumes/by_status":      4,
+					"volumes/by_volume_type": 4,
+					"volumes/by_bootable":    4,
+					"snapshots/by_status":    4,
+					"groups/by_status":       2,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called for multiple groups at once", func() {
+			dynamic := func(family, field, suffix string) plugin.MetricType {
+				return plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", family, "by_"+field).
+						AddDynamicElement("value", field+" value").
+						AddStaticElement(suffix),
+					Config_: cfg.ConfigDataNode}
+			}
+			bootable := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "by_bootable", "true", "count"),
+				Config_:    cfg.ConfigDataNode}
+			collector := New()
+			volumesCalls, snapshotsCalls := s.VolumesCalls, s.SnapshotsCalls
+			mts, err := collector.CollectMetrics([]plugin.MetricType{
+				dynamic("volumes", "status", "count"),
+				dynamic("volumes", "volume_type", "gigabytes"),
+				bootable,
+				dynamic("snapshots", "status", "gigabytes"),
+			})
+
+			Convey("Then each family is listed once and groups are emitted for values found", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesCalls-volumesCalls, ShouldEqual, 1)
+				So(s.SnapshotsCalls-snapshotsCalls, ShouldEqual, 1)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range mts {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/demo/volumes/by_status/available/count":       uint(1),
+					"/intel/openstack/cinder/demo/volumes/by_volume_type/unset/gigabytes":  s.Vol2Size,
+					"/intel/openstack/cinder/demo/volumes/by_bootable/true/count":          uint(1),
+					"/intel/openstack/cinder/demo/snapshots/by_status/available/gigabytes": s.SnapShotSize,
+				})
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestSanitizeNamespace() {
+
+	Convey("Given cloud namespace not safe for Prometheus and sanitization enabled", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("cloud_namespace", ctypes.ConfigValueStr{Value: "my-cloud"})
+		cfg.AddItem("sanitize_namespace", ctypes.ConfigValueBool{Value: true})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then sanitized namespaces are returned", func() {
+				So(err, ShouldBeNil)
+				metricNames := []string{}
+				for _, m := range mts {
+					metricNames = append(metricNames, m.Namespace().String())
+				}
+				So(str.Contains(metricNames, "/intel/openstack/cinder/my_cloud/tenants/count"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/my-cloud/tenants/count"), ShouldBeFalse)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for sanitized namespace", func() {
+			m := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "my_cloud", "tenants", "count"),
+				Config_:    cfg.ConfigDataNode}
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then metric is emitted with sanitized namespace and original one as tag", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/my_cloud/tenants/count")
+				So(mts[0].Data(), ShouldEqual, 2)
+				So(mts[0].Tags()["original_namespace"], ShouldEqual, "/intel/openstack/cinder/my-cloud/tenants/count")
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestGetAdminTenant() {
+
+	Convey("Given admin tenant and role configuration", s.T(), func() {
+		collector := New()
+
+		Convey("When tenant is set explicitly together with admin role", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+			cfg.AddItem("admin_role", ctypes.ConfigValueStr{Value: "reader"})
+			admin, err := collector.getAdminTenant(cfg)
+
+			Convey("Then configured tenant is used without looking up role assignments", func() {
+				So(err, ShouldBeNil)
+				So(admin, ShouldEqual, "admin")
+			})
+		})
+
+		Convey("When neither tenant nor admin role is set", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "")
+			_, err := collector.getAdminTenant(cfg)
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestAllTenantsOK() {
+
+	Convey("Given all tenants visibility metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "all_tenants_ok"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When volumes of several tenants are listed with admin scope", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then visibility is reported as working", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Data(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When more distinct tenants are required than listed", func() {
+			cfg.AddItem("all_tenants_min_tenants", ctypes.ConfigValueInt{Value: 3})
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then visibility is reported as not working", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Data(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When volumes are collected from configured projects", func() {
+			cfg.AddItem("projects", ctypes.ConfigValueStr{Value: "admin,demo"})
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then metric is omitted", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestAuthenticateConcurrently() {
+
+	Convey("Given config with enpoint, user and password defined", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+
+		Convey("When tenants are authenticated concurrently", func() {
+			collector := New()
+			tenants := []string{"admin", "demo", "admin", "demo"}
+			providers := make([]*gophercloud.ProviderClient, len(tenants))
+			errs := make([]error, len(tenants))
+			var done sync.WaitGroup
+			for i, tenant := range tenants {
+				done.Add(1)
+				go func(i int, tenant string) {
+					defer done.Done()
+					providers[i], _, errs[i] = collector.authenticate(cfg, tenant)
+				}(i, tenant)
+			}
+			done.Wait()
+
+			Convey("Then each tenant is authenticated once and gets its own provider", func() {
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+				So(collector.providers, ShouldHaveLength, 2)
+				So(collector.services, ShouldHaveLength, 2)
+				So(providers[0] == providers[2], ShouldBeTrue)
+				So(providers[1] == providers[3], ShouldBeTrue)
+				So(providers[0] == providers[1], ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestAuthenticateInParallel() {
+
+	Convey("Given authentication to admin tenant stalled by Keystone", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		release := make(chan struct{})
+		s.StalledTenant, s.StalledTokens = "admin", release
+		defer func() { s.StalledTenant, s.StalledTokens = "", nil }()
+
+		Convey("When demo tenant is authenticated meanwhile", func() {
+			collector := New()
+			adminErr := make(chan error, 1)
+			go func() {
+				_, _, err := collector.authenticate(cfg, "admin")
+				adminErr <- err
+			}()
+			for inFlight := 0; inFlight == 0; time.Sleep(time.Millisecond) {
+				collector.authMutex.Lock()
+				inFlight = len(collector.authCalls)
+				collector.authMutex.Unlock()
+			}
+			demoErr := make(chan error, 1)
+			go func() {
+				_, _, err := collector.authenticate(cfg, "demo")
+				demoErr <- err
+			}()
+			var err error
+			blocked := false
+			select {
+			case err = <-demoErr:
+			case <-time.After(5 * time.Second):
+				blocked = true
+			}
+			close(release)
+
+			Convey("Then it is not blocked by stalled admin authentication", func() {
+				So(blocked, ShouldBeFalse)
+				So(err, ShouldBeNil)
+				So(<-adminErr, ShouldBeNil)
+				So(collector.providers, ShouldHaveLength, 2)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsConcurrently() {
+
+	Convey("Given volumes, limits and tenants metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{
+			{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"), Config_: cfg.ConfigDataNode},
+			{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"), Config_: cfg.ConfigDataNode},
+			{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "tenants", "count"), Config_: cfg.ConfigDataNode},
+		}
+
+		Convey("When CollectMetrics() is called concurrently on one collector", func() {
+			collector := New()
+			results := make([][]plugin.MetricType, 2)
+			errs := make([]error, 2)
+			var done sync.WaitGroup
+			for i := range results {
+				done.Add(1)
+				go func(i int) {
+					defer done.Done()
+					results[i], errs[i] = collector.CollectMetrics(mts)
+				}(i)
+			}
+			done.Wait()
+
+			Convey("Then both collections succeed with all metrics", func() {
+				for i := range results {
+					So(errs[i], ShouldBeNil)
+					So(results[i], ShouldHaveLength, len(mts))
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestTenantsVisibility() {
+
+	Convey("Given config expecting minimal number of visible tenants", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		visible := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "tenants_visible_ok"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When fewer tenants than expected are visible", func() {
+			cfg.AddItem("expected_min_tenants", ctypes.ConfigValueInt{Value: 3})
+			collector := New()
+			mts, err1 := collector.GetMetricTypes(cfg)
+			metrics, err2 := collector.CollectMetrics([]plugin.MetricType{visible})
+
+			Convey("Then failed visibility check is reported by meta metric", func() {
+				So(err1, ShouldBeNil)
+				So(mts, ShouldNotBeEmpty)
+				So(checkTenantsVisibility(cfg, collector.allTenants), ShouldBeFalse)
+				So(err2, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Data(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When expected tenants are visible", func() {
+			cfg.AddItem("expected_min_tenants", ctypes.ConfigValueInt{Value: 2})
+			collector := New()
+			mts, err1 := collector.GetMetricTypes(cfg)
+			metrics, err2 := collector.CollectMetrics([]plugin.MetricType{visible})
+
+			Convey("Then visibility check passes", func() {
+				So(err1, ShouldBeNil)
+				So(mts, ShouldNotBeEmpty)
+				So(checkTenantsVisibility(cfg, collector.allTenants), ShouldBeTrue)
+				So(err2, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Data(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestServiceProject() {
+
+	Convey("Given limits metric type of demo tenant", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When Cinder version is resolved by admin project", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then admin scoped service is authenticated for it", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 1)
+				So(mts[0].Tags()["cinder_version"], ShouldEqual, "3.59")
+				So(collector.services, ShouldContainKey, "admin")
+			})
+		})
+
+		Convey("When Cinder version is resolved by demo project", func() {
+			cfg.AddItem("service_project", ctypes.ConfigValueStr{Value: "demo"})
+			m.Config_ = cfg.ConfigDataNode
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then service scoped to demo project is used", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 1)
+				So(mts[0].Tags()["cinder_version"], ShouldEqual, "3.59")
+				So(collector.services, ShouldHaveLength, 1)
+				So(collector.services, ShouldContainKey, "demo")
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestInvalidateAuth() {
+
+	Convey("Given limits metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When InvalidateAuth() is called after collection", func() {
+			collector := New()
+			_, err := collector.CollectMetrics([]plugin.MetricType{m})
+			So(err, ShouldBeNil)
+			So(len(collector.providers), ShouldBeGreaterThan, 0)
+			collector.InvalidateAuth()
+
+			Convey("Then authenticated providers are dropped", func() {
+				So(len(collector.providers), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When credentials change between collections", func() {
+			collector := New()
+			_, err1 := collector.CollectMetrics([]plugin.MetricType{m})
+			rotated := setupCfg(s.server.URL, "me", "rotated", "admin")
+			m.Config_ = rotated.ConfigDataNode
+			calls := s.LimitsCalls
+			_, err2 := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then cached limits are dropped and fetched again", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-calls, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestAPICalls() {
+
+	Convey("Given volumes, limits and API calls metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "api_calls", "volumes"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "api_calls", "snapshots"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "api_calls", "limits"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "api_error_rate"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called twice", func() {
+			collector := New()
+			limitsCalls, volumesCalls := s.LimitsCalls, s.VolumesCalls
+			first, err1 := collector.CollectMetrics(mts)
+			firstLimits, firstVolumes := s.LimitsCalls-limitsCalls, s.VolumesCalls-volumesCalls
+			volumesCalls = s.VolumesCalls
+			second, err2 := collector.CollectMetrics(mts)
+			secondVolumes := s.VolumesCalls - volumesCalls
+
+			values := func(mts []plugin.MetricType) map[string]interface{} {
+				metricNames := map[string]interface{}{}
+				for _, m := range mts {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				return metricNames
+			}
+
+			Convey("Then requests made in each collection are counted per family", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(firstVolumes, ShouldBeGreaterThan, 0)
+				So(firstLimits, ShouldBeGreaterThan, 0)
+
+				metricNames := values(first)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_calls/volumes"], ShouldEqual, firstVolumes)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_calls/snapshots"], ShouldEqual, 0)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_calls/limits"], ShouldEqual, firstLimits)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_error_rate"], ShouldEqual, 0.0)
+
+				// limits are served from cache in second collection
+				metricNames = values(second)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_calls/volumes"], ShouldEqual, secondVolumes)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_calls/limits"], ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestVolumeGroups() {
+
+	Convey("Given volume groups metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "quota"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "quota_in_use"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "by_status", "*", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "api_calls", "groups"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then groups are counted, grouped by status and their quota is returned", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames["/intel/openstack/cinder/demo/groups/count"], ShouldEqual, 3)
+				So(metricNames["/intel/openstack/cinder/demo/groups/quota"], ShouldEqual, 10)
+				So(metricNames["/intel/openstack/cinder/demo/groups/quota_in_use"], ShouldEqual, 3)
+				So(metricNames["/intel/openstack/cinder/demo/groups/by_status/available/count"], ShouldEqual, 2)
+				So(metricNames["/intel/openstack/cinder/demo/groups/by_status/error/count"], ShouldEqual, 1)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/api_calls/groups"], ShouldBeGreaterThan, 0)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for all tenants with tenant batch size", func() {
+			cfg.AddItem("tenant_batch_size", ctypes.ConfigValueInt{Value: 1})
+			mts := []plugin.MetricType{}
+			for _, tenant := range []string{"admin", "demo"} {
+				mts = append(mts, plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", tenant, "groups", "count"),
+					Config_:    cfg.ConfigDataNode})
+			}
+			s.TrackInFlight, s.MaxInFlight = true, 0
+			defer func() { s.TrackInFlight = false }()
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then groups of one tenant are listed at once", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				So(s.MaxInFlight, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestFloatPrecision() {
+
+	Convey("Given float metric types and float precision configured", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("float_precision", ctypes.ConfigValueInt{Value: 0})
+		mts := []plugin.MetricType{
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "cinder_latency_ms"),
+				Config_:    cfg.ConfigDataNode,
+			},
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "avg_size_gb"),
+				Config_:    cfg.ConfigDataNode,
+			},
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then float values are rounded to configured number of decimal places", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				for _, m := range metrics {
+					value, ok := m.Data().(float64)
+					So(ok, ShouldBeTrue)
+					So(value, ShouldEqual, roundFloat(value, 0))
+				}
+			})
+		})
+	})
+}
+
+func TestRoundFloat(t *testing.T) {
+	Convey("Given float value", t, func() {
+		Convey("When it is rounded", func() {
+			Convey("Then it has at most given number of decimal places", func() {
+				So(roundFloat(1.23456, 2), ShouldEqual, 1.23)
+				So(roundFloat(1.235, 1), ShouldEqual, 1.2)
+				So(roundFloat(2.5, 0), ShouldEqual, 3)
+				So(roundFloat(-2.5, 0), ShouldEqual, -3)
+			})
+		})
+	})
+}
+
+func TestMeta(t *testing.T) {
+	Convey("Given default plugin loading options", t, func() {
+		Convey("When Meta() is called", func() {
+			meta := Meta()
+			Convey("Then sticky routing with snap default concurrency is reported", func() {
+				So(meta.RoutingStrategy, ShouldEqual, plugin.StickyRouting)
+				So(meta.ConcurrencyCount, ShouldEqual, plugin.NewPluginMeta(Name, Version, plgtype, nil, nil).ConcurrencyCount)
+			})
+		})
+	})
+
+	Convey("Given overridden plugin loading options", t, func() {
+		defer func(r plugin.RoutingStrategyType, c int) { RoutingStrategy, ConcurrencyCount = r, c }(RoutingStrategy, ConcurrencyCount)
+		RoutingStrategy = plugin.ConfigRouting
+		ConcurrencyCount = 12
+
+		Convey("When Meta() is called", func() {
+			meta := Meta()
+			Convey("Then overridden options are reported", func() {
+				So(meta.RoutingStrategy, ShouldEqual, plugin.ConfigRouting)
+				So(meta.ConcurrencyCount, ShouldEqual, 12)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestTenantNameFilter() {
+
+	Convey("Given config with tenant name filter", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("tenant_name_filter", ctypes.ConfigValueStr{Value: "dem*, prod-*"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then only metrics of matching tenants are advertised", func() {
+				So(err, ShouldBeNil)
+				tenants := map[string]bool{}
+				for _, m := range mts {
+					tenants[m.Namespace()[3].Value] = true
+				}
+				So(tenants["demo"], ShouldBeTrue)
+				So(tenants["admin"], ShouldBeFalse)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for matching tenant", func() {
+			collector := New()
+			mts := []plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+					Config_:    cfg.ConfigDataNode,
+				},
+			}
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then its metrics are collected", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Data(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for cloud-wide rollups", func() {
+			s.VolumesProjects = nil
+			collector := New()
+			mts := []plugin.MetricType{
+				{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"), Config_: cfg.ConfigDataNode},
+				{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "tenants", "count"), Config_: cfg.ConfigDataNode},
+			}
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then only matching tenant is listed by project ID and rolled up", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesProjects, ShouldResemble, []string{s.Tenant2ID})
+				values := map[string]interface{}{}
+				for _, m := range metrics {
+					values[m.Namespace()[5].Value] = m.Data()
+				}
+				So(values["total"], ShouldEqual, 1)
+				So(values["count"], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When Cinder returns volumes and snapshots of tenants not matching filter", func() {
+			volumes := map[string]types.Volumes{s.Tenant1ID: {Count: 1}, s.Tenant2ID: {Count: 2}}
+			snapshots := map[string]types.Snapshots{s.Tenant1ID: {Count: 3}}
+			keptVolumes, keptSnapshots := keepTenants(volumes, snapshots, map[string]string{s.Tenant2ID: s.Tenant2Name})
+			_, nilSnapshots := keepTenants(volumes, nil, map[string]string{s.Tenant2ID: s.Tenant2Name})
+
+			Convey("Then those are dropped from rollups", func() {
+				So(keptVolumes, ShouldResemble, map[string]types.Volumes{s.Tenant2ID: {Count: 2}})
+				So(keptSnapshots, ShouldBeEmpty)
+				So(nilSnapshots, ShouldBeNil)
+			})
+		})
+
+		Convey("When tenants have UUID-style IDs", func() {
+			tenants := map[string]string{
+				"3f2a9c1e8b7d4e6fa0c5d9b2e1f4a7c3": "demo",
+				"c4e5f6a7b8c94d0e9f1a2b3c4d5e6f70": "prod-web",
+				"dem0a1b2c3d4e5f6a7b8c9d0e1f2a3b4": "admin",
+			}
+			filtered, err := filterTenantNames(tenants, []string{"dem*", "prod-*"})
+
+			Convey("Then tenants are matched by name, not by ID", func() {
+				So(err, ShouldBeNil)
+				So(filtered, ShouldResemble, map[string]string{
+					"3f2a9c1e8b7d4e6fa0c5d9b2e1f4a7c3": "demo",
+					"c4e5f6a7b8c94d0e9f1a2b3c4d5e6f70": "prod-web",
+				})
+			})
+		})
+
+		Convey("When filter is not valid pattern", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+			cfg.AddItem("tenant_name_filter", ctypes.ConfigValueStr{Value: "prod-["})
+			collector := New()
+			_, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestQoSSpecs() {
+
+	Convey("Given QoS specs metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "qos_specs", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "qos_specs", "*", "associations"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then QoS specs are counted with associations of each spec", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/_cloud/qos_specs/count":               uint(2),
+					"/intel/openstack/cinder/_cloud/qos_specs/gold/associations":   uint(2),
+					"/intel/openstack/cinder/_cloud/qos_specs/silver/associations": uint(0),
+				})
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestLastSummary() {
+
+	Convey("Given cloud-wide rollup metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "snapshots", "total"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When no collection finished yet", func() {
+			collector := New()
+
+			Convey("Then empty summary is returned", func() {
+				So(collector.LastSummary(), ShouldResemble, types.CloudSummary{})
+			})
+		})
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			started := time.Now()
+			_, err := collector.CollectMetrics(mts)
+			summary := collector.LastSummary()
+
+			Convey("Then summary of collection is kept", func() {
+				So(err, ShouldBeNil)
+				So(summary.Tenants, ShouldEqual, 2)
+				So(summary.Volumes, ShouldEqual, 2)
+				So(summary.Snapshots, ShouldEqual, 1)
+				So(summary.Gigabytes, ShouldEqual, s.Vol1Size+s.Vol2Size)
+				So(summary.Errors, ShouldEqual, 0)
+				So(summary.Collected, ShouldHappenOnOrAfter, started)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestTimestampSource() {
+
+	Convey("Given limits, volumes and meta metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "cinder_latency_ms"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+		timestamps := func(mts []plugin.MetricType) map[string]time.Time {
+			metricTimes := map[string]time.Time{}
+			for _, m := range mts {
+				metricTimes[m.Namespace().String()] = m.Timestamp()
+			}
+			return metricTimes
+		}
+
+		Convey("When CollectMetrics() is called again with timestamp_source api", func() {
+			cfg.AddItem("timestamp_source", ctypes.ConfigValueStr{Value: "api"})
+			collector := New()
+			fetched := time.Now()
+			_, err1 := collector.CollectMetrics(mts)
+			time.Sleep(10 * time.Millisecond)
+			started := time.Now()
+			metrics, err2 := collector.CollectMetrics(mts)
+			emitted := timestamps(metrics)
+
+			Convey("Then cached limits carry time they were fetched and other metrics time of current collection", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(emitted["/intel/openstack/cinder/demo/limits/MaxTotalVolumes"], ShouldHappenBetween, fetched, started)
+				So(emitted["/intel/openstack/cinder/demo/volumes/count"], ShouldHappenOnOrAfter, started)
+				So(emitted["/intel/openstack/cinder/demo/volumes/count"], ShouldHappenOnOrBefore, emitted["/intel/openstack/cinder/_cloud/meta/cinder_latency_ms"])
+			})
+		})
+
+		Convey("When CollectMetrics() is called with default timestamp source", func() {
+			collector := New()
+			_, err := collector.CollectMetrics(mts)
+			started := time.Now()
+			metrics, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then all metrics carry emission time", func() {
+				So(err, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				for _, timestamp := range timestamps(metrics) {
+					So(timestamp, ShouldHappenOnOrAfter, started)
+				}
+			})
+		})
+
+		Convey("When CollectMetrics() is called with unsupported timestamp source", func() {
+			cfg.AddItem("timestamp_source", ctypes.ConfigValueStr{Value: "cinder"})
+			_, err := New().CollectMetrics(mts)
+
+			Convey("Then error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestExtraSpecs() {
+
+	Convey("Given volume types by extra spec metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("group_types_by_extra_spec", ctypes.ConfigValueStr{Value: "volume_backend_name"})
+		mts := []plugin.MetricType{
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volume_types", "by_extraspec", "volume_backend_name", "*", "count"),
+				Config_:    cfg.ConfigDataNode,
+			},
+		}
+
+		Convey("When metric types are listed", func() {
+			collector := New()
+			catalog, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then extra spec value is dynamic element of configured key", func() {
+				So(err, ShouldBeNil)
+				namespaces := []string{}
+				for _, m := range catalog {
+					namespaces = append(namespaces, m.Namespace().String())
+				}
+				So(namespaces, ShouldContain, "/intel/openstack/cinder/_cloud/volume_types/by_extraspec/volume_backend_name/*/count")
+			})
+		})
+
+		Convey("When CollectMetrics() is called twice", func() {
+			collector := New()
+			calls := s.ExtraSpecsCalls
+			metrics, err1 := collector.CollectMetrics(mts)
+			fetched := s.ExtraSpecsCalls - calls
+			_, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then volume types are counted by extra spec value and extra specs are read once", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/_cloud/volume_types/by_extraspec/volume_backend_name/lvm/count":   uint(1),
+					"/intel/openstack/cinder/_cloud/volume_types/by_extraspec/volume_backend_name/unset/count": uint(1),
+				})
+				So(fetched, ShouldEqual, 2)
+				So(s.ExtraSpecsCalls-calls, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestUnknownNamespace() {
+
+	Convey("Given metric type which namespace does not map to any metric", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+				Config_:    cfg.ConfigDataNode,
+			},
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "countt"),
+				Config_:    cfg.ConfigDataNode,
+			},
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then it is skipped instead of emitting nil value", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/demo/volumes/count")
+			})
+		})
+
+		Convey("When value for unknown namespaces is configured", func() {
+			cfg.AddItem("unknown_namespace_value", ctypes.ConfigValueStr{Value: "-1"})
+			for i := range mts {
+				mts[i].Config_ = cfg.ConfigDataNode
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then configured value is emitted", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				So(metrics[1].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/demo/volumes/countt")
+				So(metrics[1].Data(), ShouldEqual, -1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestTenantWithoutResources() {
+
+	Convey("Given tenant without any volumes and snapshots", s.T(), func() {
+		s.Vol1TenantID = s.Tenant2ID
+		defer func() { s.Vol1TenantID = s.Tenant1ID }()
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+
+		Convey("When all volumes and snapshots metrics of that tenant are collected", func() {
+			collector := New()
+			catalog, err := collector.GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			mts := []plugin.MetricType{}
+			for _, mt := range catalog {
+				namespace := mt.Namespace().Strings()
+				if dynamic, _ := mt.Namespace().IsDynamic(); !dynamic && namespace[3] == "admin" && (namespace[4] == "volumes" || namespace[4] == "snapshots") {
+					mts = append(mts, mt)
+				}
+			}
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then every metric is emitted with zero value", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldNotBeEmpty)
+				So(metrics, ShouldHaveLength, len(mts))
+				for _, m := range metrics {
+					So(m.Data(), ShouldBeZeroValue)
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestSnapshotsMaxPerVolume() {
+
+	Convey("Given snapshots per volume metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "max_snapshots_per_volume"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			volumesCalls := s.VolumesCalls
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then volumes are listed to correlate snapshots with existing volumes", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesCalls-volumesCalls, ShouldEqual, 1)
+				So(mts, ShouldHaveLength, 1)
+				// only snapshot of demo tenant belongs to volume which no longer exists
+				So(mts[0].Data(), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestSnapshotsOrphanedVolumesFailed() {
+
+	Convey("Given orphaned snapshots metric type and volumes failing to be listed in best-effort mode", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("collection_mode", ctypes.ConfigValueStr{Value: "besteffort"})
+		orphaned := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "orphaned"),
+			Config_:    cfg.ConfigDataNode}
+		snapshots := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "count"),
+			Config_:    cfg.ConfigDataNode}
+		s.VolumesFail = true
+		defer func() { s.VolumesFail = false }()
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{orphaned, snapshots})
+
+			Convey("Then orphaned snapshots are omitted instead of counting every snapshot", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, snapshots.Namespace().String())
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestStaticTags() {
+
+	Convey("Given config with static tags", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("static_tags", ctypes.ConfigValueStr{Value: "datacenter=dc1, environment = prod,invalid,cinder_version=0"})
+		mts := []plugin.MetricType{
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+				Config_:    cfg.ConfigDataNode,
+			},
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"),
+				Config_:    cfg.ConfigDataNode,
+			},
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then static tags are attached to every metric next to plugin tags", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				for _, m := range metrics {
+					So(m.Tags(), ShouldResemble, map[string]string{"datacenter": "dc1", "environment": "prod", "cinder_version": "3.59"})
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestTenantBatchSize() {
+
+	Convey("Given config with tenant batch size", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("tenant_batch_size", ctypes.ConfigValueInt{Value: 1})
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			s.VolumesProjects = nil
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then volumes are listed per tenant and aggregated as with single all tenants listing", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesProjects, ShouldResemble, []string{s.Tenant1ID, s.Tenant2ID})
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldHaveLength, 4)
+				for namespace, value := range map[string]int{
+					"/intel/openstack/cinder/admin/volumes/count":  1,
+					"/intel/openstack/cinder/demo/volumes/count":   1,
+					"/intel/openstack/cinder/demo/snapshots/count": 1,
+					"/intel/openstack/cinder/_cloud/volumes/total": 2,
+				} {
+					So(metricNames[namespace], ShouldEqual, value)
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestTenantTimeBudget() {
+
+	Convey("Given config with tenant batch size and slow listing of first tenant", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("tenant_batch_size", ctypes.ConfigValueInt{Value: 1})
+		s.SlowProject = s.Tenant1ID
+		defer func() { s.SlowProject = "" }()
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called without time budget", func() {
+			collector := New()
+			s.VolumesProjects = nil
+			_, err := collector.CollectMetrics(mts)
+
+			Convey("Then next tenant waits for slow tenant", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesProjects, ShouldResemble, []string{s.Tenant1ID, s.Tenant2ID})
+			})
+		})
+
+		Convey("When CollectMetrics() is called with time budget", func() {
+			cfg.AddItem("tenant_time_budget", ctypes.ConfigValueInt{Value: 20})
+			for i := range mts {
+				mts[i].Config_ = cfg.ConfigDataNode
+			}
+			collector := New()
+			s.VolumesProjects = nil
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then next tenant is listed meanwhile and metrics of both tenants are collected", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesProjects, ShouldResemble, []string{s.Tenant2ID, s.Tenant1ID})
+				So(metrics, ShouldHaveLength, 2)
+				for _, m := range metrics {
+					So(m.Data(), ShouldEqual, 1)
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestMaxPageSize() {
+
+	Convey("Given config with page size exceeding maximal page size of cloud", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("page_size", ctypes.ConfigValueInt{Value: 5000})
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then volumes are listed with page size capped at default maximum", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(s.VolumesLimit, ShouldEqual, "1000")
+			})
+		})
+
+		Convey("When CollectMetrics() is called with maximal page size configured", func() {
+			cfg.AddItem("max_page_size", ctypes.ConfigValueInt{Value: 10000})
+			m.Config_ = cfg.ConfigDataNode
+			collector := New()
+			_, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then volumes are listed with configured page size", func() {
+				So(err, ShouldBeNil)
+				So(s.VolumesLimit, ShouldEqual, "5000")
+			})
+		})
+	})
+
+	Convey("Given config with more volumes in cloud than maximal page size and page size not set", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("max_page_size", ctypes.ConfigValueInt{Value: 1})
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			s.VolumesCalls = 0
+			collector := New()
+			metrics, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then volumes are listed page by page with maximal page size and all of them are counted", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Data(), ShouldEqual, 2)
+				So(s.VolumesLimit, ShouldEqual, "1")
+				So(s.VolumesCalls, ShouldEqual, 3)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCinderURL() {
+
+	Convey("Given config with Cinder endpoint", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+
+		Convey("When endpoint is malformed", func() {
+			for _, cinderURL := range []string{"cinder:8776/v2", "ftp://cinder/v2", "http://%zz/v2"} {
+				cfg.AddItem("cinder_url", ctypes.ConfigValueStr{Value: cinderURL})
+				_, err := getAuthOpts(plugin.MetricType{Config_: cfg.ConfigDataNode})
+
+				Convey("Then error is reported for "+cinderURL, func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "Invalid cinder_url")
+				})
+			}
+		})
+
+		Convey("When endpoint contains project ID placeholder", func() {
+			cfg.AddItem("cinder_url", ctypes.ConfigValueStr{Value: "https://cinder:8776/v2/%(project_id)s"})
+			opts, err := getAuthOpts(plugin.MetricType{Config_: cfg.ConfigDataNode})
+			So(err, ShouldBeNil)
+			tenants := map[string]string{s.Tenant1ID: s.Tenant1Name}
+
+			Convey("Then ID of scoped tenant is substituted", func() {
+				cinderURL, err := expandCinderURL(opts.CinderURL, tenants, s.Tenant1Name)
+				So(err, ShouldBeNil)
+				So(cinderURL, ShouldEqual, "https://cinder:8776/v2/"+s.Tenant1ID)
+			})
+
+			Convey("and error is reported for tenant of unknown ID", func() {
+				_, err := expandCinderURL(opts.CinderURL, tenants, "unknown")
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestLimitsReserved() {
+
+	Convey("Given reserved limits metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "volumes_reserved"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "gigabytes_reserved"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called after limits were cached without reserved usage", func() {
+			collector := New()
+			_, err := collector.CollectMetrics([]plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "volumes_used"),
+					Config_:    cfg.ConfigDataNode},
+			})
+			So(err, ShouldBeNil)
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then limits are fetched again and only reserved usage reported by Cinder is emitted", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/demo/limits/volumes_reserved")
+				So(metrics[0].Data(), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When CollectMetrics() is called twice while Cinder does not report reserved usage", func() {
+			s.QuotaUsageBare = true
+			defer func() { s.QuotaUsageBare = false }()
+			collector := New()
+			limitsCalls := s.LimitsCalls
+			first, err1 := collector.CollectMetrics(mts)
+			second, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then limits are fetched once and reserved usage is omitted", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 1)
+				So(first, ShouldBeEmpty)
+				So(second, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestLimitsGroups() {
+
+	Convey("Given generic volume groups limits metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "groups"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "groups_used"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "groups_remaining"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called after limits were cached without groups quota", func() {
+			collector := New()
+			_, err := collector.CollectMetrics([]plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "volumes_used"),
+					Config_:    cfg.ConfigDataNode},
+			})
+			So(err, ShouldBeNil)
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then limits are fetched again and unlimited groups quota is emitted", func() {
+				So(err, ShouldBeNil)
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/demo/limits/groups":           -1,
+					"/intel/openstack/cinder/demo/limits/groups_used":      4,
+					"/intel/openstack/cinder/demo/limits/groups_remaining": -1,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called twice while Cinder does not report groups quota", func() {
+			s.QuotaUsageBare = true
+			defer func() { s.QuotaUsageBare = false }()
+			collector := New()
+			limitsCalls := s.LimitsCalls
+			first, err1 := collector.CollectMetrics(mts)
+			second, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then limits are fetched once and groups quota is omitted", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 1)
+				So(first, ShouldBeEmpty)
+				So(second, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestAdminLimits() {
+
+	Convey("Given limits metric types of all tenants", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("admin_limits", ctypes.ConfigValueBool{Value: true})
+		cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: false})
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "limits", "MaxTotalVolumes"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "volumes_used"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+		values := func(mts []plugin.MetricType) map[string]interface{} {
+			metricNames := map[string]interface{}{}
+			for _, m := range mts {
+				metricNames[m.Namespace().String()] = m.Data()
+			}
+			return metricNames
+		}
+
+		Convey("When CollectMetrics() is called with admin_limits enabled", func() {
+			collector := New()
+			limitsCalls := s.LimitsCalls
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then limits of each tenant are read from its quota usage with admin scope", func() {
+				So(err, ShouldBeNil)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 0)
+				So(values(metrics), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/admin/limits/MaxTotalVolumes": 20,
+					"/intel/openstack/cinder/demo/limits/MaxTotalVolumes":  30,
+					"/intel/openstack/cinder/demo/limits/volumes_used":     15,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called while admin can not read quota usage of other tenants", func() {
+			s.QuotaSetsForbidden = true
+			defer func() { s.QuotaSetsForbidden = false }()
+			collector := New()
+			limitsCalls := s.LimitsCalls
+			first, err1 := collector.CollectMetrics(mts)
+			quotaSetsCalls := s.QuotaSetsCalls
+			_, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then limits are read with tenant scope and admin scope is not tried again", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 4)
+				So(s.QuotaSetsCalls-quotaSetsCalls, ShouldEqual, 0)
+				So(values(first), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/admin/limits/MaxTotalVolumes": s.MaxTotalVolumes,
+					"/intel/openstack/cinder/demo/limits/MaxTotalVolumes":  s.MaxTotalVolumes,
+					"/intel/openstack/cinder/demo/limits/volumes_used":     2,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called while quota usage can not be read due to server error", func() {
+			s.QuotaSetsFail = true
+			collector := New()
+			limitsCalls := s.LimitsCalls
+			first, err1 := collector.CollectMetrics(mts)
+			s.QuotaSetsFail = false
+			quotaSetsCalls := s.QuotaSetsCalls
+			second, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then limits are read with tenant scope in that collection only", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 2)
+				So(s.QuotaSetsCalls-quotaSetsCalls, ShouldEqual, 2)
+				So(values(first)["/intel/openstack/cinder/demo/limits/volumes_used"], ShouldEqual, 2)
+				So(values(second)["/intel/openstack/cinder/demo/limits/volumes_used"], ShouldEqual, 15)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestEndpointProbe() {
+
+	Convey("Given reachability metric types", s.T(), func() {
+		probes := []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "keystone_reachable"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "cinder_reachable"),
+		}
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		values := func(mts []plugin.MetricType) map[string]interface{} {
+			metricNames := map[string]interface{}{}
+			for _, m := range mts {
+				metricNames[m.Namespace().String()] = m.Data()
+			}
+			return metricNames
+		}
+
+		Convey("When CollectMetrics() is called together with volumes", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+			mts := []plugin.MetricType{}
+			for _, ns := range append(probes, core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count")) {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then both endpoints are reported reachable", func() {
+				So(err, ShouldBeNil)
+				metricNames := values(metrics)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/keystone_reachable"], ShouldEqual, 1)
+				So(metricNames["/intel/openstack/cinder/_cloud/meta/cinder_reachable"], ShouldEqual, 1)
+				So(metricNames["/intel/openstack/cinder/demo/volumes/count"], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for reachability only with Cinder unreachable", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+			cfg.AddItem("cinder_url", ctypes.ConfigValueStr{Value: closed.URL + "/v2/v2ffff"})
+			mts := []plugin.MetricType{}
+			for _, ns := range probes {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			volumesCalls := s.VolumesCalls
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then Cinder is reported unreachable without collection", func() {
+				So(err, ShouldBeNil)
+				So(values(metrics), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/_cloud/meta/keystone_reachable": 1,
+					"/intel/openstack/cinder/_cloud/meta/cinder_reachable":   0,
+				})
+				So(s.VolumesCalls, ShouldEqual, volumesCalls)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for reachability only with Cinder served under path prefix", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+			cfg.AddItem("cinder_url", ctypes.ConfigValueStr{Value: th.Endpoint() + "volume/v2/v2ffff"})
+			mts := []plugin.MetricType{}
+			for _, ns := range probes {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			calls := s.PrefixedRootCalls
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then version root under the prefix is probed", func() {
+				So(err, ShouldBeNil)
+				So(s.PrefixedRootCalls-calls, ShouldEqual, 1)
+				So(values(metrics)["/intel/openstack/cinder/_cloud/meta/cinder_reachable"], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for reachability only with Cinder root not found", func() {
+			cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+			cfg.AddItem("cinder_url", ctypes.ConfigValueStr{Value: s.server.URL + "/missing/v2/v2ffff"})
+			mts := []plugin.MetricType{}
+			for _, ns := range probes {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then Cinder is reported unreachable", func() {
+				So(err, ShouldBeNil)
+				So(values(metrics)["/intel/openstack/cinder/_cloud/meta/cinder_reachable"], ShouldEqual, 0)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for reachability only with Keystone unreachable", func() {
+			cfg := setupCfg(closed.URL, "me", "secret", "admin")
+			cfg.AddItem("cinder_url", ctypes.ConfigValueStr{Value: th.Endpoint() + "v2/v2ffff"})
+			mts := []plugin.MetricType{}
+			for _, ns := range probes {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then Keystone is reported unreachable instead of collection failing", func() {
+				So(err, ShouldBeNil)
+				So(values(metrics), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/_cloud/meta/keystone_reachable": 0,
+					"/intel/openstack/cinder/_cloud/meta/cinder_reachable":   1,
+				})
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCountDelta() {
+
+	Convey("Given count change metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "volumes", "delta_abs"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "delta_abs"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "delta_abs"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+		values := func(mts []plugin.MetricType) map[string]interface{} {
+			metricNames := map[string]interface{}{}
+			for _, m := range mts {
+				metricNames[m.Namespace().String()] = m.Data()
+			}
+			return metricNames
+		}
+
+		Convey("When CollectMetrics() is called again after volume moved to another tenant", func() {
+			collector := New()
+			first, err1 := collector.CollectMetrics(mts)
+			s.Vol1TenantID = s.Tenant2ID
+			defer func() { s.Vol1TenantID = s.Tenant1ID }()
+			second, err2 := collector.CollectMetrics(mts)
+
+			Convey("Then no change is reported in first collection and absolute changes in second one", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(values(first), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/admin/volumes/delta_abs":  uint(0),
+					"/intel/openstack/cinder/demo/volumes/delta_abs":   uint(0),
+					"/intel/openstack/cinder/demo/snapshots/delta_abs": uint(0),
+				})
+				So(values(second), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/admin/volumes/delta_abs":  uint(1),
+					"/intel/openstack/cinder/demo/volumes/delta_abs":   uint(1),
+					"/intel/openstack/cinder/demo/snapshots/delta_abs": uint(0),
+				})
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestIncludeDeleted() {
+
+	Convey("Given deleted volumes and snapshots metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "deleted"),
+			core.NewNamespace("intel", "openstack", "cinder", "admin", "snapshots", "deleted"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+		values := func(mts []plugin.MetricType) map[string]interface{} {
+			metricNames := map[string]interface{}{}
+			for _, m := range mts {
+				metricNames[m.Namespace().String()] = m.Data()
+			}
+			return metricNames
+		}
+
+		Convey("When CollectMetrics() is called with default configuration", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then deleted resources are not listed and counted as 0", func() {
+				So(err, ShouldBeNil)
+				So(values(metrics), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/admin/volumes/count":     uint(1),
+					"/intel/openstack/cinder/demo/volumes/deleted":    uint(0),
+					"/intel/openstack/cinder/admin/snapshots/deleted": uint(0),
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called with include_deleted enabled", func() {
+			cfg.AddItem("include_deleted", ctypes.ConfigValueBool{Value: true})
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then deleted resources are counted separately", func() {
+				So(err, ShouldBeNil)
+				So(values(metrics), ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/admin/volumes/count":     uint(1),
+					"/intel/openstack/cinder/demo/volumes/deleted":    uint(1),
+					"/intel/openstack/cinder/admin/snapshots/deleted": uint(1),
+				})
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestMetricTransform() {
+
+	Convey("Given volumes and snapshots metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "count"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called with metric transformation set", func() {
+			collector := New()
+			var transformed int
+			collector.MetricTransform = func(metrics []plugin.MetricType) []plugin.MetricType {
+				transformed = len(metrics)
+				kept := []plugin.MetricType{}
+				for _, m := range metrics {
+					if m.Namespace()[4].Value == "volumes" {
+						m.Namespace_ = core.NewNamespace("custom", "demo", "volumes")
+						kept = append(kept, m)
+					}
+				}
+				return kept
+			}
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then transformed metrics are returned", func() {
+				So(err, ShouldBeNil)
+				So(transformed, ShouldEqual, 2)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/custom/demo/volumes")
+				So(metrics[0].Data(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When metric transformation modifies tags of single metric", func() {
+			collector := New()
+			collector.MetricTransform = func(metrics []plugin.MetricType) []plugin.MetricType {
+				metrics[0].Tags_["transformed"] = "true"
+				return metrics
+			}
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then tags of other metrics are not modified", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				So(metrics[0].Tags(), ShouldContainKey, "transformed")
+				So(metrics[1].Tags(), ShouldNotContainKey, "transformed")
+			})
+		})
+
+		Convey("When CollectMetrics() fails with metric transformation set", func() {
+			collector := New()
+			called := false
+			collector.MetricTransform = func(metrics []plugin.MetricType) []plugin.MetricType {
+				called = true
+				return metrics
+			}
+			cfg.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "unsupported"})
+			for i := range mts {
+				mts[i].Config_ = cfg.ConfigDataNode
+			}
+			_, err := collector.CollectMetrics(mts)
+
+			Convey("Then transformation is not applied", func() {
+				So(err, ShouldNotBeNil)
+				So(called, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestRuntimeMetrics() {
+
+	Convey("Given plugin runtime metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "goroutines"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "heap_bytes"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then number of goroutines and heap size of plugin are emitted", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 2)
+				for _, m := range metrics {
+					switch m.Namespace().Strings()[5] {
+					case "goroutines":
+						So(m.Data(), ShouldBeGreaterThan, 0)
+					case "heap_bytes":
+						So(m.Data(), ShouldBeGreaterThan, uint64(0))
+					}
+				}
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestPools() {
+
+	Convey("Given pools metric types", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "pools", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "pools", "*", "overcommit_ratio"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then overcommit ratio is emitted only for pools reporting provisioned and total capacity", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/_cloud/pools/count":                           uint(3),
+					"/intel/openstack/cinder/_cloud/pools/node1@lvm#thin/overcommit_ratio": 2.5,
+				})
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestServices() {
+
+	Convey("Given services heartbeat metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{
+			{Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "services", "*", "*", "heartbeat_age_seconds"), Config_: cfg.ConfigDataNode},
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then age of last heartbeat is emitted for each service reporting its state", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/_cloud/services/controller/cinder-scheduler/heartbeat_age_seconds": 0,
+					"/intel/openstack/cinder/_cloud/services/node1@lvm/cinder-volume/heartbeat_age_seconds":     600,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called for given host", func() {
+			mts[0].Namespace_[5].Value = "node1@lvm"
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then only services of that host are emitted", func() {
+				So(err, ShouldBeNil)
+				So(len(metrics), ShouldEqual, 1)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/_cloud/services/node1@lvm/cinder-volume/heartbeat_age_seconds")
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestSnapshotsByHost() {
+
+	Convey("Given snapshots by host metric type", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "hosts", "*", "snapshot_count"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			metrics, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then host of volumes without snapshots is reported with 0", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/_cloud/hosts/rbd:volumes#DEFAULT/snapshot_count")
+				So(metrics[0].Data(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When CollectMetrics() is called for snapshot of listed volume", func() {
+			s.SnapshotVolumeID = s.Vol1
+			defer func() { s.SnapshotVolumeID = "495a1698-ca2f-4e84-8d34-fa544c65ae3d" }()
+			collector := New()
+			metrics, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then snapshot is counted by host of its volume", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Data(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When CollectMetrics() is called without all tenants visibility", func() {
+			cfg.AddItem("all_tenants", ctypes.ConfigValueBool{Value: false})
+			m.Config_ = cfg.ConfigDataNode
+			collector := New()
+			metrics, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then snapshots by host are not reported", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestMaxCardinality() {
+
+	Convey("Given breakdowns capped by max cardinality", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("max_cardinality", ctypes.ConfigValueInt{Value: 1})
+		cfg.AddItem("group_volumes_by", ctypes.ConfigValueStr{Value: "status"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then truncation flags of breakdowns are advertised", func() {
+				So(err, ShouldBeNil)
+				metricNames := []string{}
+				for _, m := range mts {
+					metricNames = append(metricNames, m.Namespace().String())
+				}
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/groups/by_status/_truncated"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/by_status/_truncated"), ShouldBeTrue)
+			})
+		})
+
+		Convey("When CollectMetrics() is called", func() {
+			mts := []plugin.MetricType{}
+			for _, ns := range []core.Namespace{
+				core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "by_status", "*", "count"),
+				core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "by_status", "_truncated"),
+				core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "by_status", "_truncated"),
+			} {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then values above cap are bucketed together and truncation is flagged", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/demo/groups/by_status/available/count": uint(2),
+					"/intel/openstack/cinder/demo/groups/by_status/_other/count":    uint(1),
+					"/intel/openstack/cinder/demo/groups/by_status/_truncated":      1,
+					"/intel/openstack/cinder/demo/volumes/by_status/_truncated":     0,
+				})
+			})
+		})
+
+		Convey("When CollectMetrics() is called with cap of groups by status above max cardinality", func() {
+			cfg.AddItem("max_cardinality_groups_by_status", ctypes.ConfigValueInt{Value: 2})
+			mts := []plugin.MetricType{}
+			for _, ns := range []core.Namespace{
+				core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "by_status", "*", "count"),
+				core.NewNamespace("intel", "openstack", "cinder", "demo", "groups", "by_status", "_truncated"),
+			} {
+				mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then groups by status are capped by their own cap", func() {
+				So(err, ShouldBeNil)
+
+				metricNames := map[string]interface{}{}
+				for _, m := range metrics {
+					metricNames[m.Namespace().String()] = m.Data()
+				}
+				So(metricNames, ShouldResemble, map[string]interface{}{
+					"/intel/openstack/cinder/demo/groups/by_status/available/count": uint(2),
+					"/intel/openstack/cinder/demo/groups/by_status/error/count":     uint(1),
+					"/intel/openstack/cinder/demo/groups/by_status/_truncated":      0,
+				})
+			})
+		})
+	})
+
+	Convey("Given only volumes by status capped", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("max_cardinality_volumes_by_status", ctypes.ConfigValueInt{Value: 1})
+		cfg.AddItem("group_volumes_by", ctypes.ConfigValueStr{Value: "status"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then truncation flag is advertised for that breakdown only", func() {
+				So(err, ShouldBeNil)
+				metricNames := []string{}
+				for _, m := range mts {
+					metricNames = append(metricNames, m.Namespace().String())
+				}
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/volumes/by_status/_truncated"), ShouldBeTrue)
+				So(str.Contains(metricNames, "/intel/openstack/cinder/demo/groups/by_status/_truncated"), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestLimitsChanged() {
+
+	Convey("Given limits changed metric type with limits refetched on each collection", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: false})
+		mts := []plugin.MetricType{
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "changed"),
+				Config_:    cfg.ConfigDataNode,
+			},
+		}
+
+		Convey("When quota is modified between collections", func() {
+			collector := New()
+			first, err1 := collector.CollectMetrics(mts)
+			s.MaxTotalVolumes = 20
+			second, err2 := collector.CollectMetrics(mts)
+			third, err3 := collector.CollectMetrics(mts)
+			s.MaxTotalVolumes = 10
+
+			Convey("Then change is reported only in collection which noticed it", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(err3, ShouldBeNil)
+				So(first[0].Data(), ShouldEqual, 0)
+				So(second[0].Data(), ShouldEqual, 1)
+				So(third[0].Data(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When quota is modified and cached limits expire", func() {
+			cfg.AddItem("cache_limits", ctypes.ConfigValueBool{Value: true})
+			cfg.AddItem("limits_ttl", ctypes.ConfigValueInt{Value: 60})
+			for i := range mts {
+				mts[i].Config_ = cfg.ConfigDataNode
+			}
+			collector := New()
+			first, err1 := collector.CollectMetrics(mts)
+			s.MaxTotalVolumes = 20
+			second, err2 := collector.CollectMetrics(mts)
+			limitsCalls := s.LimitsCalls
+			collector.limitsFetched["demo"] = time.Now().Add(-90 * time.Second)
+			third, err3 := collector.CollectMetrics(mts)
+			s.MaxTotalVolumes = 10
+
+			Convey("Then change is reported in collection which refreshed limits", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(err3, ShouldBeNil)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 1)
+				So(first[0].Data(), ShouldEqual, 0)
+				So(second[0].Data(), ShouldEqual, 0)
+				So(third[0].Data(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectRaw() {
+
+	Convey("Given config with enpoint, user and password defined", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+
+		Convey("When CollectRaw() is called", func() {
+			collector := New()
+			volumes, snapshots, limits, err := collector.CollectRaw(cfg)
+
+			Convey("Then volumes, snapshots and limits of all tenants are returned by tenant name", func() {
+				So(err, ShouldBeNil)
+				So(volumes, ShouldHaveLength, 2)
+				So(snapshots, ShouldHaveLength, 2)
+				So(limits, ShouldHaveLength, 2)
+				So(volumes["demo"].Count, ShouldEqual, 1)
+				So(volumes["demo"].Bytes, ShouldEqual, s.Vol2Size*1024*1024*1024)
+				So(snapshots["demo"].Count, ShouldEqual, 1)
+				So(limits["demo"].MaxTotalVolumes, ShouldEqual, s.MaxTotalVolumes)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestFlatNamespaceLayout() {
+
+	Convey("Given config with flat namespace layout", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("namespace_layout", ctypes.ConfigValueStr{Value: "flat"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then tenants are not part of namespaces", func() {
+				So(err, ShouldBeNil)
+				namespaces := map[string]bool{}
+				for _, mt := range mts {
+					namespaces[mt.Namespace().String()] = true
+				}
+				So(namespaces, ShouldContainKey, "/intel/openstack/cinder/volumes/count")
+				So(namespaces, ShouldContainKey, "/intel/openstack/cinder/groups/by_status/count")
+				So(namespaces, ShouldNotContainKey, "/intel/openstack/cinder/demo/volumes/count")
+				So(len(namespaces), ShouldEqual, len(mts))
+			})
+		})
+
+		Convey("When flat metric is collected", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", "volumes", "count"),
+					Config_:    cfg.ConfigDataNode,
+				},
+			})
+
+			Convey("Then metric of each tenant is emitted with tenant tag", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 2)
+				tenants := map[string]interface{}{}
+				for _, mt := range mts {
+					So(mt.Namespace().String(), ShouldEqual, "/intel/openstack/cinder/volumes/count")
+					tenants[mt.Tags()[tenantTag]] = mt.Data()
+				}
+				So(tenants, ShouldContainKey, "admin")
+				So(tenants["demo"], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When flat metric of dynamic breakdown is collected", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "openstack", "cinder", "groups", "by_status", "count"),
+					Config_:    cfg.ConfigDataNode,
+				},
+			})
+
+			Convey("Then breakdown values are emitted as tags", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldNotBeEmpty)
+				for _, mt := range mts {
+					So(mt.Namespace().String(), ShouldEqual, "/intel/openstack/cinder/groups/by_status/count")
+					So(mt.Tags(), ShouldContainKey, tenantTag)
+					So(mt.Tags(), ShouldContainKey, "status")
+				}
+			})
+		})
+	})
+
+	Convey("Given config with unsupported namespace layout", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("namespace_layout", ctypes.ConfigValueStr{Value: "deep"})
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			_, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then error is returned", func() {
+				So(err, ShouldNotBeNil)
 			})
 		})
 	})
 }
 
-func (s *CollectorSuite) TestCollectMetrics() {
+func (s *CollectorSuite) TestCollectMetricsSingleFamily() {
 
-	Convey("Given set of metric types", s.T(), func() {
+	Convey("Given metric types of single family", s.T(), func() {
 		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
-		m1 := plugin.MetricType{
-			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumeGigabytes"),
+		limits := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
 			Config_:    cfg.ConfigDataNode}
-		m2 := plugin.MetricType{
+		volumes := plugin.MetricType{
 			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
 			Config_:    cfg.ConfigDataNode}
-		m3 := plugin.MetricType{
-			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "snapshots", "bytes"),
-			Config_:    cfg.ConfigDataNode}
 
-		Convey("When ColelctMetrics() is called", func() {
+		Convey("When only limits are collected", func() {
 			collector := New()
+			limitsCalls, volumesCalls, snapshotsCalls := s.LimitsCalls, s.VolumesCalls, s.SnapshotsCalls
+			mts, err := collector.CollectMetrics([]plugin.MetricType{limits})
+
+			Convey("Then volumes and snapshots are not listed", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 1)
+				So(s.VolumesCalls-volumesCalls, ShouldEqual, 0)
+				So(s.SnapshotsCalls-snapshotsCalls, ShouldEqual, 0)
+			})
+		})
 
-			mts, err := collector.CollectMetrics([]plugin.MetricType{m1, m2, m3})
+		Convey("When only volumes are collected", func() {
+			collector := New()
+			limitsCalls, volumesCalls, snapshotsCalls := s.LimitsCalls, s.VolumesCalls, s.SnapshotsCalls
+			mts, err := collector.CollectMetrics([]plugin.MetricType{volumes})
 
-			Convey("Then no error should be reported", func() {
+			Convey("Then limits and snapshots are not requested", func() {
 				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 1)
+				So(s.LimitsCalls-limitsCalls, ShouldEqual, 0)
+				So(s.VolumesCalls-volumesCalls, ShouldEqual, 1)
+				So(s.SnapshotsCalls-snapshotsCalls, ShouldEqual, 0)
 			})
+		})
+	})
+}
 
-			Convey("and proper metric types are returned", func() {
-				metricNames := map[string]interface{}{}
+func (s *CollectorSuite) TestCollectMetricsProjects() {
+
+	Convey("Given set of metric types and list of projects configured", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "")
+		cfg.AddItem("projects", ctypes.ConfigValueStr{Value: "demo"})
+		m1 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			Config_:    cfg.ConfigDataNode}
+		m2 := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "limits", "MaxTotalVolumes"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When GetMetricTypes() is called", func() {
+			collector := New()
+			mts, err := collector.GetMetricTypes(cfg)
+
+			Convey("Then metric types of configured projects only are returned", func() {
+				So(err, ShouldBeNil)
 				for _, m := range mts {
-					ns := m.Namespace().String()
-					metricNames[ns] = m.Data()
-					fmt.Println(ns, "=", m.Data())
+					So(m.Namespace()[3].Value, ShouldBeIn, "demo", defaultCloudNamespace)
 				}
+			})
+		})
 
-				So(len(mts), ShouldEqual, 3)
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m1, m2})
 
-				val, ok := metricNames["/intel/openstack/cinder/demo/limits/MaxTotalVolumeGigabytes"]
-				So(ok, ShouldBeTrue)
-				So(val, ShouldEqual, s.MaxTotalVolumeGigabytes)
+			Convey("Then project volumes are listed without all_tenants filter", func() {
+				So(err, ShouldBeNil)
+				So(len(mts), ShouldEqual, 2)
+				So(s.VolumesAllTenants, ShouldEqual, "")
+			})
+		})
+	})
+}
 
-				val, ok = metricNames["/intel/openstack/cinder/demo/volumes/count"]
-				So(ok, ShouldBeTrue)
-				So(val, ShouldEqual, 1)
+func (s *CollectorSuite) TestVolumesBySizeBucket() {
 
-				val, ok = metricNames["/intel/openstack/cinder/demo/snapshots/bytes"]
-				So(ok, ShouldBeTrue)
-				So(val, ShouldEqual, s.SnapShotSize*1024*1024*1024)
+	Convey("Given volumes grouped by size bucket", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("group_volumes_by", ctypes.ConfigValueStr{Value: "size_bucket"})
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "by_size_bucket").
+				AddDynamicElement("value", "size_bucket value").
+				AddStaticElement("count"),
+			Config_: cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called with default buckets", func() {
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then volumes are counted in bucket of their size", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/demo/volumes/by_size_bucket/10-100/count")
+				So(mts[0].Data(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When CollectMetrics() is called with configured buckets", func() {
+			cfg.AddItem("volume_size_buckets", ctypes.ConfigValueStr{Value: "20, 5, invalid"})
+			m.Config_ = cfg.ConfigDataNode
+			collector := New()
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then valid bounds are used in ascending order", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/demo/volumes/by_size_bucket/20+/count")
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCatalogWithoutCinder() {
+
+	Convey("Given service catalog without Cinder service", s.T(), func() {
+		s.CatalogVolumeType = "volumev2_disabled"
+		defer func() { s.CatalogVolumeType = "volumev2" }()
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		mts := []plugin.MetricType{}
+		for _, ns := range []core.Namespace{
+			core.NewNamespace("intel", "openstack", "cinder", "demo", "volumes", "count"),
+			core.NewNamespace("intel", "openstack", "cinder", "_cloud", "meta", "catalog_ok"),
+		} {
+			mts = append(mts, plugin.MetricType{Namespace_: ns, Config_: cfg.ConfigDataNode})
+		}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			_, err := collector.CollectMetrics(mts)
+
+			Convey("Then missing service is reported in error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "cinder service not found in catalog")
+			})
+		})
+
+		Convey("When CollectMetrics() is called in best-effort mode", func() {
+			cfg.AddItem("collection_mode", ctypes.ConfigValueStr{Value: "besteffort"})
+			for i := range mts {
+				mts[i].Config_ = cfg.ConfigDataNode
+			}
+			collector := New()
+			metrics, err := collector.CollectMetrics(mts)
+
+			Convey("Then catalog is reported as not usable", func() {
+				So(err, ShouldBeNil)
+				So(metrics, ShouldHaveLength, 1)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/openstack/cinder/_cloud/meta/catalog_ok")
+				So(metrics[0].Data(), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestCollectMetricsPerTenantScope() {
+
+	Convey("Given set of metric types and per tenant collection scope", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "per_tenant"})
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "openstack", "cinder", "_cloud", "volumes", "total"),
+			Config_:    cfg.ConfigDataNode}
+
+		Convey("When CollectMetrics() is called", func() {
+			collector := New()
+			volumesCalls := s.VolumesCalls
+			mts, err := collector.CollectMetrics([]plugin.MetricType{m})
 
+			Convey("Then volumes of each tenant are listed with its own token without all_tenants filter", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldHaveLength, 1)
+				So(s.VolumesCalls-volumesCalls, ShouldEqual, 2)
+				So(s.VolumesAllTenants, ShouldEqual, "")
+			})
+		})
+
+		Convey("When unsupported collection scope is configured", func() {
+			cfg.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "system"})
+			m.Config_ = cfg.ConfigDataNode
+			collector := New()
+			_, err := collector.CollectMetrics([]plugin.MetricType{m})
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CollectorSuite) TestDomainScope() {
+
+	Convey("Given config with domain collection scope", s.T(), func() {
+		cfg := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "domain"})
+		m := plugin.MetricType{Config_: cfg.ConfigDataNode}
+
+		Convey("When domain is not configured", func() {
+			_, scopeErr := getCollectionScope(m)
+			_, authErr := getAuthOpts(m)
+
+			Convey("Then error is reported", func() {
+				So(scopeErr, ShouldNotBeNil)
+				So(scopeErr.Error(), ShouldContainSubstring, "scope_domain_name")
+				So(authErr, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When domain is configured by name", func() {
+			cfg.AddItem("scope_domain_name", ctypes.ConfigValueStr{Value: "projects"})
+			m.Config_ = cfg.ConfigDataNode
+			scope, err := getCollectionScope(m)
+			So(err, ShouldBeNil)
+			opts, err := getAuthOpts(m)
+			So(err, ShouldBeNil)
+
+			Convey("Then projects are enumerated and looked up in that domain", func() {
+				So(scope, ShouldEqual, domainScope)
+				So(opts.DomainScopeName, ShouldEqual, "projects")
+				So(opts.DomainScopeID, ShouldBeEmpty)
+				So(opts.ProjectDomainName, ShouldEqual, "projects")
+			})
+		})
+
+		Convey("When domain is configured by ID together with domain of projects", func() {
+			cfg.AddItem("scope_domain_id", ctypes.ConfigValueStr{Value: "d2"})
+			cfg.AddItem("project_domain_name", ctypes.ConfigValueStr{Value: "other"})
+			m.Config_ = cfg.ConfigDataNode
+			opts, err := getAuthOpts(m)
+
+			Convey("Then configured domain of projects is kept", func() {
+				So(err, ShouldBeNil)
+				So(opts.DomainScopeID, ShouldEqual, "d2")
+				So(opts.ProjectDomainName, ShouldEqual, "other")
+				So(opts.ProjectDomainID, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When only common domain is configured", func() {
+			cfg.AddItem("domain_id", ctypes.ConfigValueStr{Value: "d3"})
+			m.Config_ = cfg.ConfigDataNode
+			opts, err := getAuthOpts(m)
+
+			Convey("Then token is scoped to it", func() {
+				So(err, ShouldBeNil)
+				So(opts.DomainScopeID, ShouldEqual, "d3")
+			})
+		})
+
+		Convey("When other collection scope is configured", func() {
+			cfg.AddItem("scope_domain_name", ctypes.ConfigValueStr{Value: "projects"})
+			cfg.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "per_tenant"})
+			m.Config_ = cfg.ConfigDataNode
+			opts, err := getAuthOpts(m)
+
+			Convey("Then token is not scoped to domain", func() {
+				So(err, ShouldBeNil)
+				So(opts.DomainScopeName, ShouldBeEmpty)
+				So(opts.ProjectDomainName, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given two tasks sharing endpoint and user with different domain scopes", s.T(), func() {
+		cfg1 := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg1.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "domain"})
+		cfg1.AddItem("scope_domain_id", ctypes.ConfigValueStr{Value: "d1"})
+		cfg2 := setupCfg(s.server.URL, "me", "secret", "admin")
+		cfg2.AddItem("collection_scope", ctypes.ConfigValueStr{Value: "domain"})
+		cfg2.AddItem("scope_domain_id", ctypes.ConfigValueStr{Value: "d2"})
+		opts1, err := getAuthOpts(plugin.MetricType{Config_: cfg1.ConfigDataNode})
+		So(err, ShouldBeNil)
+		opts2, err := getAuthOpts(plugin.MetricType{Config_: cfg2.ConfigDataNode})
+		So(err, ShouldBeNil)
+
+		Convey("When tenants of both are listed through tenants cache", func() {
+			calls := 0
+			list := func(tenants map[string]string) func() (map[string]string, error) {
+				return func() (map[string]string, error) {
+					calls++
+					return tenants, nil
+				}
+			}
+			tenants1, err1 := cachedTenants(tenantsCacheKey("tenants", opts1, nil), time.Minute, list(map[string]string{"id1": "tenant1"}))
+			tenants2, err2 := cachedTenants(tenantsCacheKey("tenants", opts2, nil), time.Minute, list(map[string]string{"id2": "tenant2"}))
+
+			Convey("Then each domain gets its own tenants", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(calls, ShouldEqual, 2)
+				So(tenants1, ShouldResemble, map[string]string{"id1": "tenant1"})
+				So(tenants2, ShouldResemble, map[string]string{"id2": "tenant2"})
 			})
 		})
 	})
 }
 
+func BenchmarkBuildMetricTypes(b *testing.B) {
+	cfg := setupCfg("http://localhost", "me", "secret", "admin")
+	tenants := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		tenants[fmt.Sprintf("id%d", i)] = fmt.Sprintf("tenant%d", i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buildMetricTypes(tenants, defaultCloudNamespace, cfg)
+	}
+}
+
 func TestCollectorSuite(t *testing.T) {
 	collectorTestSuite := new(CollectorSuite)
 	suite.Run(t, collectorTestSuite)
@@ -214,6 +3382,22 @@ func registerIdentityRoot(s *CollectorSuite, r *mux.Router) {
 
 func registerIdentityToken(s *CollectorSuite, r *mux.Router) {
 	r.HandleFunc("/v2.0/tokens", func(w http.ResponseWriter, r *http.Request) {
+		// token of stalled tenant is issued only once stalled tokens are released, unauthorized tenant gets none
+		if s.StalledTokens != nil || s.UnauthorizedTenant != "" {
+			var body struct {
+				Auth struct {
+					TenantName string `json:"tenantName"`
+				} `json:"auth"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if s.StalledTokens != nil && body.Auth.TenantName == s.StalledTenant {
+				<-s.StalledTokens
+			}
+			if s.UnauthorizedTenant != "" && body.Auth.TenantName == s.UnauthorizedTenant {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
 		fmt.Fprintf(w, `
 				{
 					"access": {
@@ -236,7 +3420,7 @@ func registerIdentityToken(s *CollectorSuite, r *mux.Router) {
 								],
 								"endpoints_links": [],
 								"name": "cinderv2",
-								"type": "volumev2"
+								"type": "%s"
 							},
 							{
 								"endpoints": [
@@ -271,6 +3455,7 @@ func registerIdentityToken(s *CollectorSuite, r *mux.Router) {
 			th.Endpoint()+s.V2,
 			th.Endpoint()+s.V2,
 			th.Endpoint()+s.V2,
+			s.CatalogVolumeType,
 			th.Endpoint()+s.V1,
 			th.Endpoint()+s.V1,
 			th.Endpoint()+s.V1,
@@ -334,12 +3519,32 @@ func registerCinderApi(s *CollectorSuite) {
 								"rel": "self"
 							}
 						],
-						"status": "CURRENT",
+						"status": "SUPPORTED",
 						"updated": "2012-11-21T11:33:21Z"
+					},
+					{
+						"id": "v3.0",
+						"links": [
+							{
+								"href": "%s",
+								"rel": "self"
+							}
+						],
+						"min_version": "3.0",
+						"status": "CURRENT",
+						"updated": "2017-02-25T12:00:00Z",
+						"version": "3.59"
 					}
 				]
 			}
-			`, th.Endpoint()+"v1", th.Endpoint()+"v2")
+			`, th.Endpoint()+"v1", th.Endpoint()+"v2", th.Endpoint()+"v3")
+	})
+	// version root of Cinder served under path prefix
+	th.Mux.HandleFunc("/volume/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/volume/" {
+			s.PrefixedRootCalls++
+		}
+		w.WriteHeader(http.StatusOK)
 	})
 }
 
@@ -348,6 +3553,16 @@ func registerCinderLimits(s *CollectorSuite) {
 	s.MaxTotalVolumeGigabytes = 1000
 	s.MaxTotalVolumes = 10
 	th.Mux.HandleFunc(s.LimitsV2, func(w http.ResponseWriter, r *http.Request) {
+		s.LimitsCalls++
+		defer s.trackInFlight()()
+		if s.LimitsETag != "" {
+			w.Header().Set("ETag", s.LimitsETag)
+			if r.Header.Get("If-None-Match") == s.LimitsETag {
+				s.LimitsNotModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
 		fmt.Fprintf(w, `
 				{
 					"limits": {
@@ -368,18 +3583,220 @@ func registerCinderLimits(s *CollectorSuite) {
 				}
 			`, s.MaxTotalVolumeGigabytes, s.MaxTotalVolumes)
 	})
+	th.Mux.HandleFunc("/"+s.V2+"/types", func(w http.ResponseWriter, r *http.Request) {
+		defer s.trackInFlight()()
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `
+				{
+					"volume_types": [
+						{"id": "6685584b", "name": "ssd", "os-volume-type-access:is_public": true},
+						{"id": "8eb69a46", "name": "gold", "os-volume-type-access:is_public": false}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/types/", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		s.ExtraSpecsCalls++
+		extraSpecs := map[string]string{"6685584b": `{"volume_backend_name": "lvm"}`, "8eb69a46": `{}`}
+		fmt.Fprintf(w, `{"extra_specs": %s}`, extraSpecs[path.Base(path.Dir(r.URL.Path))])
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/os-quota-class-sets/default", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		s.DefaultQuotaCalls++
+		fmt.Fprintf(w, `
+				{
+					"quota_class_set": {
+						"id": "default",
+						"gigabytes": 1000,
+						"snapshots": 10,
+						"volumes": 10
+					}
+				}
+			`)
+	})
+	// quota usage of other tenants read with admin scope
+	th.Mux.HandleFunc("/"+s.V2+"/os-quota-sets/", func(w http.ResponseWriter, r *http.Request) {
+		th.TestFormValues(s.T(), r, map[string]string{"usage": "true"})
+		s.QuotaSetsCalls++
+		if s.QuotaSetsForbidden {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if s.QuotaSetsFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		limits := map[string]int{s.Tenant1ID: 20, s.Tenant2ID: 30}[path.Base(r.URL.Path)]
+		fmt.Fprintf(w, `
+				{
+					"quota_set": {
+						"id": "%s",
+						"volumes": {"in_use": %d, "limit": %d, "reserved": 0},
+						"gigabytes": {"in_use": 40, "limit": 500, "reserved": 0}
+					}
+				}
+			`, path.Base(r.URL.Path), limits/2, limits)
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/os-quota-sets/v2ffff", func(w http.ResponseWriter, r *http.Request) {
+		th.TestFormValues(s.T(), r, map[string]string{"usage": "true"})
+		// quota usage of older Cinder reports neither reserved usage nor groups quota
+		if s.QuotaUsageBare {
+			fmt.Fprintf(w, `{"quota_set": {"id": "v2ffff", "volumes": {"in_use": 2, "limit": 10}}}`)
+			return
+		}
+		fmt.Fprintf(w, `
+				{
+					"quota_set": {
+						"id": "v2ffff",
+						"volumes": {"in_use": 2, "limit": 10, "reserved": 3},
+						"gigabytes_ssd": {"in_use": 80, "limit": 100, "reserved": 0},
+						"groups": {"in_use": 4, "limit": -1, "reserved": 0}
+					}
+				}
+			`)
+	})
+}
+
+// trackInFlight counts tenant scoped calls handled at once when tracking is enabled, returned function ends the call
+func (s *CollectorSuite) trackInFlight() func() {
+	if !s.TrackInFlight {
+		return func() {}
+	}
+	s.inFlightMutex.Lock()
+	s.InFlight++
+	if s.InFlight > s.MaxInFlight {
+		s.MaxInFlight = s.InFlight
+	}
+	s.inFlightMutex.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	return func() {
+		s.inFlightMutex.Lock()
+		s.InFlight--
+		s.inFlightMutex.Unlock()
+	}
+}
+
+func registerCinderVolumeGroups(s *CollectorSuite) {
+	th.Mux.HandleFunc("/v3/v2ffff/groups/detail", func(w http.ResponseWriter, r *http.Request) {
+		defer s.trackInFlight()()
+		th.TestHeader(s.T(), r, "OpenStack-API-Version", "volume 3.13")
+		fmt.Fprintf(w, `
+				{
+					"groups": [
+						{"id": "grp1", "name": "group1", "status": "available"},
+						{"id": "grp2", "name": "group2", "status": "available"},
+						{"id": "grp3", "name": "group3", "status": "error"}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/v3/v2ffff/os-quota-sets/v2ffff", func(w http.ResponseWriter, r *http.Request) {
+		th.TestFormValues(s.T(), r, map[string]string{"usage": "true"})
+		fmt.Fprintf(w, `
+				{
+					"quota_set": {
+						"id": "v2ffff",
+						"groups": {"in_use": 3, "limit": 10, "reserved": 0}
+					}
+				}
+			`)
+	})
+}
+
+func registerCinderQoSSpecs(s *CollectorSuite) {
+	th.Mux.HandleFunc("/v2/v2ffff/qos-specs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+				{
+					"qos_specs": [
+						{"id": "qos1", "name": "gold", "consumer": "back-end", "specs": {"total_iops_sec": "1000"}},
+						{"id": "qos2", "name": "silver", "consumer": "front-end", "specs": {"total_iops_sec": "500"}}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/v2/v2ffff/qos-specs/qos1/associations", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+				{
+					"qos_associations": [
+						{"association_type": "volume_type", "name": "ssd", "id": "type1"},
+						{"association_type": "volume_type", "name": "nvme", "id": "type2"}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/v2/v2ffff/qos-specs/qos2/associations", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"qos_associations": []}`)
+	})
+}
+
+func registerCinderPools(s *CollectorSuite) {
+	th.Mux.HandleFunc("/v2/v2ffff/scheduler-stats/get_pools", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `
+				{
+					"pools": [
+						{"name": "node1@lvm#thin", "capabilities": {"total_capacity_gb": 100, "provisioned_capacity_gb": 250, "free_capacity_gb": 40}},
+						{"name": "node1@lvm#thick", "capabilities": {"total_capacity_gb": 200, "free_capacity_gb": 150}},
+						{"name": "node2@ceph#rbd", "capabilities": {"total_capacity_gb": "infinite", "provisioned_capacity_gb": 30}}
+					]
+				}
+			`)
+	})
+}
+
+func registerCinderServices(s *CollectorSuite) {
+	th.Mux.HandleFunc("/v2/v2ffff/os-services", func(w http.ResponseWriter, r *http.Request) {
+		// scheduler reports heartbeat slightly ahead of plugin clock, volume service stopped reporting 10 minutes ago
+		layout := "2006-01-02T15:04:05.000000"
+		now := time.Now().UTC()
+		fmt.Fprintf(w, `
+				{
+					"services": [
+						{"binary": "cinder-scheduler", "host": "controller", "state": "up", "updated_at": "%s"},
+						{"binary": "cinder-volume", "host": "node1@lvm", "state": "up", "updated_at": "%s"},
+						{"binary": "cinder-backup", "host": "controller", "state": "down", "updated_at": null}
+					]
+				}
+			`, now.Add(5*time.Second).Format(layout), now.Add(-600*time.Second).Format(layout))
+	})
 }
 
 func registerCinderVolumes(s *CollectorSuite) {
 	url := "/v2/v2ffff/volumes/detail" //?all_tenants=true
 	th.Mux.HandleFunc(url, func(w http.ResponseWriter, r *http.Request) {
-		th.TestFormValues(s.T(), r, map[string]string{"all_tenants": "true"})
+		if s.SlowProject != "" && r.URL.Query().Get("project_id") == s.SlowProject {
+			time.Sleep(200 * time.Millisecond)
+		}
+		s.VolumesCalls++
+		s.VolumesAllTenants = r.URL.Query().Get("all_tenants")
+		s.VolumesLimit = r.URL.Query().Get("limit")
+		if project := r.URL.Query().Get("project_id"); project != "" {
+			s.VolumesProjects = append(s.VolumesProjects, project)
+		}
+		if s.VolumesAllTenants != "" {
+			testAllTenantsForm(s, r)
+		}
+		if r.URL.Query().Get("deleted") != "" {
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			writeProjectFiltered(w, r, "volumes", "os-vol-tenant-attr:tenant_id", `{"volumes": [
+				{"id": "vol3dddd", "os-vol-tenant-attr:tenant_id": "%s", "size": 3, "status": "deleted"}]}`, s.Tenant2ID)
+			return
+		}
+		if s.FailingProject != "" && r.URL.Query().Get("project_id") == s.FailingProject {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if s.VolumesFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		th.TestMethod(s.T(), r, "GET")
 		th.TestHeader(s.T(), r, "X-Auth-Token", s.Token)
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
-		fmt.Fprintf(w, `
+		writeProjectFiltered(w, r, "volumes", "os-vol-tenant-attr:tenant_id", `
 			{
 				"volumes": [
 					{
@@ -476,21 +3893,72 @@ func registerCinderVolumes(s *CollectorSuite) {
 					}
     			]
        		 }
-		`, s.Vol1, s.Tenant1ID, s.Vol1Size, s.Vol2, s.Tenant2ID, s.Vol2Size)
+		`, s.Vol1, s.Vol1TenantID, s.Vol1Size, s.Vol2, s.Tenant2ID, s.Vol2Size)
 	})
 
 }
 
+// testAllTenantsForm checks parameters of all tenants listing, optionally filtered by project and paginated
+func testAllTenantsForm(s *CollectorSuite, r *http.Request) {
+	values := map[string]string{"all_tenants": "true"}
+	for _, param := range []string{"project_id", "limit", "deleted"} {
+		if value := r.URL.Query().Get(param); value != "" {
+			values[param] = value
+		}
+	}
+	th.TestFormValues(s.T(), r, values)
+}
+
+// writeProjectFiltered writes formatted response listing items of collection, only those belonging to project given
+// by project_id parameter are kept when it is set, and items are paged by marker and limit parameters when set
+func writeProjectFiltered(w http.ResponseWriter, r *http.Request, collection, field, format string, a ...interface{}) {
+	body := fmt.Sprintf(format, a...)
+	project, marker := r.URL.Query().Get("project_id"), r.URL.Query().Get("marker")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if project == "" && marker == "" && limit <= 0 {
+		fmt.Fprint(w, body)
+		return
+	}
+	response := map[string][]map[string]interface{}{}
+	json.Unmarshal([]byte(body), &response)
+	items := []map[string]interface{}{}
+	following := marker == ""
+	for _, item := range response[collection] {
+		if !following {
+			following = item["id"] == marker
+			continue
+		}
+		if (project == "" || item[field] == project) && (limit <= 0 || len(items) < limit) {
+			items = append(items, item)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{collection: items})
+}
+
 func registerCinderSnapshots(s *CollectorSuite) {
 	snapshots := "/v2/v2ffff/snapshots/detail"
 	th.Mux.HandleFunc(snapshots, func(w http.ResponseWriter, r *http.Request) {
+		s.SnapshotsCalls++
 		th.TestMethod(s.T(), r, "GET")
 		th.TestHeader(s.T(), r, "X-Auth-Token", s.Token)
-		th.TestFormValues(s.T(), r, map[string]string{"all_tenants": "true"})
+		if r.URL.Query().Get("all_tenants") != "" {
+			testAllTenantsForm(s, r)
+		}
+		if r.URL.Query().Get("deleted") != "" {
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			writeProjectFiltered(w, r, "snapshots", "os-extended-snapshot-attributes:project_id", `{"snapshots": [
+				{"id": "snap3cccc", "os-extended-snapshot-attributes:project_id": "%s", "size": 1, "status": "deleted"}]}`, s.Tenant1ID)
+			return
+		}
+		if s.SnapshotsFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
-		fmt.Fprintf(w, `
+		writeProjectFiltered(w, r, "snapshots", "os-extended-snapshot-attributes:project_id", `
 			{
 				"snapshots": [
 					{
@@ -503,10 +3971,10 @@ func registerCinderSnapshots(s *CollectorSuite) {
             			"os-extended-snapshot-attributes:project_id": "%s",
 						"size": %d,
 						"status": "available",
-						"volume_id": "495a1698-ca2f-4e84-8d34-fa544c65ae3d"
+						"volume_id": "%s"
 					}
 				]
 			}
-		`, s.Tenant2ID, s.SnapShotSize)
+		`, s.Tenant2ID, s.SnapShotSize, s.SnapshotVolumeID)
 	})
 }
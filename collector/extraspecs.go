@@ -0,0 +1,67 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
+)
+
+const (
+	// defaultExtraSpecsCacheTTL is default time in seconds for which extra specs of volume types are reused
+	defaultExtraSpecsCacheTTL = 600
+)
+
+// collectExtraSpecs counts volume types by values of given extra spec keys by authenticating to admin, types not
+// carrying the key are counted under types.UnsetMetadataValue. Extra specs of types already known are reused until
+// extra_specs_cache_ttl expires, so only newly created types are read in between
+func (c *collector) collectExtraSpecs(cfg interface{}, admin string, keys []string) (map[string]map[string]uint, error) {
+	provider, service, err := c.authenticate(cfg, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(getConfigInt(cfg, "extra_specs_cache_ttl", defaultExtraSpecsCacheTTL)) * time.Second
+	opts := types.ExtraSpecsOptions{}
+	if time.Since(c.extraSpecsFetched) <= ttl {
+		opts.Known = c.extraSpecs
+	}
+
+	start := time.Now()
+	extraSpecs, err := service.GetExtraSpecs(provider, opts)
+	c.cinderTimer.since(start)
+	if err != nil {
+		return nil, err
+	}
+	c.apiClock.record("volume_types")
+	if opts.Known == nil {
+		c.extraSpecsFetched = time.Now()
+	}
+	c.extraSpecs = extraSpecs
+
+	counts := make(map[string]map[string]uint, len(keys))
+	for _, key := range keys {
+		counts[key] = map[string]uint{}
+		for _, specs := range extraSpecs {
+			value, found := specs[key]
+			if !found {
+				value = types.UnsetMetadataValue
+			}
+			counts[key][value]++
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,126 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	// collection modes, in strict mode any error aborts whole collection, in best-effort mode only metrics
+	// of failed family and tenants are omitted
+	strictMode     = "strict"
+	bestEffortMode = "besteffort"
+)
+
+// familyError is error of collecting given family of metrics
+type familyError struct {
+	family string
+	err    error
+}
+
+func (e familyError) Error() string {
+	return fmt.Sprintf("%s: %v", e.family, e.err)
+}
+
+// familyErrors gathers errors of families collected concurrently
+type familyErrors []familyError
+
+func (e familyErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// failures records families which failed to be collected, keyed by namespace element of tenant and family name
+// Empty tenant stands for all tenants, including cloud-wide metrics. It is safe for concurrent use
+type failures struct {
+	sync.Mutex
+	strict   bool
+	families map[string]bool
+}
+
+// newFailures creates failures for collection_mode read from configuration, strict by default
+func newFailures(cfg interface{}) (*failures, error) {
+	mode := getConfigString(cfg, "collection_mode", strictMode)
+	if mode != strictMode && mode != bestEffortMode {
+		return nil, fmt.Errorf("Unknown collection_mode %q, expected %s or %s", mode, strictMode, bestEffortMode)
+	}
+	return &failures{strict: mode == strictMode, families: map[string]bool{}}, nil
+}
+
+// handle returns err in strict mode, in best-effort mode it logs err and marks given families of tenants as failed
+// Errors of type familyErrors mark their own families
+func (f *failures) handle(err error, families []string, tenants ...string) error {
+	if err == nil {
+		return nil
+	}
+	if f.strict {
+		return err
+	}
+	log.Printf("Skipping metrics of tenants %v: %v", tenants, err)
+
+	if errs, ok := err.(familyErrors); ok {
+		families = make([]string, 0, len(errs))
+		for _, e := range errs {
+			families = append(families, e.family)
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for _, tenant := range tenants {
+		for _, family := range families {
+			f.families[tenant+"/"+family] = true
+		}
+	}
+	return nil
+}
+
+// failedPercent returns percentage of given tenants with any family failed to be collected, failures concerning
+// all tenants count for each of them
+func (f *failures) failedPercent(tenants []string) float64 {
+	f.Lock()
+	defer f.Unlock()
+	failed := map[string]bool{}
+	for key := range f.families {
+		failed[key[:strings.LastIndex(key, "/")]] = true
+	}
+	if failed[""] {
+		return 100
+	}
+	if len(tenants) == 0 {
+		return 0
+	}
+	count := 0
+	for _, tenant := range tenants {
+		if failed[tenant] {
+			count++
+		}
+	}
+	return float64(count) / float64(len(tenants)) * 100
+}
+
+// has checks if given family of tenant failed to be collected
+func (f *failures) has(tenant, family string) bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.families[tenant+"/"+family] || f.families["/"+family]
+}
@@ -0,0 +1,72 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestFailures(t *testing.T) {
+	Convey("Given collection mode configuration", t, func() {
+		cfg := setupCfg("http://localhost", "me", "secret", "admin")
+
+		Convey("When collection mode is not configured", func() {
+			f, err := newFailures(cfg)
+
+			Convey("Then errors are returned as they are", func() {
+				So(err, ShouldBeNil)
+				e := errors.New("failed")
+				So(f.handle(e, []string{"volumes"}, "demo"), ShouldEqual, e)
+			})
+		})
+
+		Convey("When best-effort mode is configured", func() {
+			cfg.AddItem("collection_mode", ctypes.ConfigValueStr{Value: "besteffort"})
+			f, err := newFailures(cfg)
+			So(err, ShouldBeNil)
+
+			Convey("Then failed families of given tenants are recorded", func() {
+				So(f.handle(errors.New("failed"), []string{"limits"}, "demo"), ShouldBeNil)
+				So(f.has("demo", "limits"), ShouldBeTrue)
+				So(f.has("demo", "volumes"), ShouldBeFalse)
+				So(f.has("admin", "limits"), ShouldBeFalse)
+			})
+
+			Convey("and families of family errors are recorded for all tenants", func() {
+				errs := familyErrors{{family: "snapshots", err: errors.New("failed")}}
+				So(f.handle(errs, []string{"volumes", "snapshots"}, ""), ShouldBeNil)
+				So(f.has("demo", "snapshots"), ShouldBeTrue)
+				So(f.has("admin", "snapshots"), ShouldBeTrue)
+				So(f.has("demo", "volumes"), ShouldBeFalse)
+			})
+		})
+
+		Convey("When unknown collection mode is configured", func() {
+			cfg.AddItem("collection_mode", ctypes.ConfigValueStr{Value: "lenient"})
+			_, err := newFailures(cfg)
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,80 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// sources of metric timestamps: time metric is emitted, or time API call returning its data returned
+	timestampNow = "now"
+	timestampAPI = "api"
+)
+
+// apiTimer accumulates time spent in API calls, it is safe for concurrent use
+type apiTimer struct {
+	mutex sync.Mutex
+	total time.Duration
+}
+
+// since adds time elapsed from start to accumulated total
+func (t *apiTimer) since(start time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.total += time.Since(start)
+}
+
+// milliseconds returns accumulated total in milliseconds
+func (t *apiTimer) milliseconds() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return float64(t.total) / float64(time.Millisecond)
+}
+
+// apiClock records when API calls of each family returned, it is safe for concurrent use
+type apiClock struct {
+	mutex    sync.Mutex
+	returned map[string]time.Time
+}
+
+// record sets time API call of given family returned to current time
+func (c *apiClock) record(family string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.returned == nil {
+		c.returned = map[string]time.Time{}
+	}
+	c.returned[family] = time.Now()
+}
+
+// at returns time last API call of given family returned, false when no call of the family returned
+func (c *apiClock) at(family string) (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	returned, found := c.returned[family]
+	return returned, found
+}
+
+// getTimestampSource returns configured source of metric timestamps, time of emission is used when not configured
+func getTimestampSource(cfg interface{}) (string, error) {
+	source := getConfigString(cfg, "timestamp_source", timestampNow)
+	if source != timestampNow && source != timestampAPI {
+		return "", fmt.Errorf("Unsupported timestamp source %q, use %q or %q", source, timestampNow, timestampAPI)
+	}
+	return source, nil
+}
@@ -0,0 +1,54 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApiTimer(t *testing.T) {
+	Convey("Given API timer", t, func() {
+		timer := &apiTimer{}
+
+		Convey("When no call was measured", func() {
+			Convey("Then zero is returned", func() {
+				So(timer.milliseconds(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When calls are measured concurrently", func() {
+			start := time.Now().Add(-10 * time.Millisecond)
+			var done sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					timer.since(start)
+				}()
+			}
+			done.Wait()
+
+			Convey("Then durations of all calls are summed", func() {
+				So(timer.milliseconds(), ShouldBeGreaterThanOrEqualTo, 30)
+			})
+		})
+	})
+}
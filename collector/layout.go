@@ -0,0 +1,186 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+const (
+	// nestedLayout keeps tenant and descriptive elements (volume types, statuses etc.) in namespaces
+	nestedLayout = "nested"
+	// flatLayout moves tenant and descriptive elements from namespaces into tags
+	flatLayout = "flat"
+	// tenantTag is tag holding tenant name (or cloud namespace) of metric emitted in flat layout
+	tenantTag = "tenant"
+	// unknownDomain takes place of domain of tenant which was not listed together with domains
+	unknownDomain = "unknown"
+)
+
+// getNamespaceLayout returns configured namespace layout, nested one is used when not configured
+func getNamespaceLayout(cfg interface{}) (string, error) {
+	layout := getConfigString(cfg, "namespace_layout", nestedLayout)
+	if layout != nestedLayout && layout != flatLayout {
+		return "", fmt.Errorf("Unsupported namespace layout %q, use %q or %q", layout, nestedLayout, flatLayout)
+	}
+	return layout, nil
+}
+
+// domainsByTenantName returns domain IDs given by tenant ID keyed by tenant name
+func domainsByTenantName(tenants, domains map[string]string) map[string]string {
+	byName := make(map[string]string, len(tenants))
+	for id, tenantName := range tenants {
+		if domain, found := domains[id]; found {
+			byName[tenantName] = domain
+		} else {
+			byName[tenantName] = unknownDomain
+		}
+	}
+	return byName
+}
+
+// insertDomain returns copy of namespace with domain of tenant inserted before tenant element, namespaces
+// of cloud-wide metrics are returned unchanged
+func insertDomain(namespace core.Namespace, domains map[string]string, cloudNs string) core.Namespace {
+	if len(namespace) < 4 || namespace[3].Value == cloudNs {
+		return namespace
+	}
+	domain, found := domains[namespace[3].Value]
+	if !found {
+		domain = unknownDomain
+	}
+	inserted := make(core.Namespace, 0, len(namespace)+1)
+	inserted = append(inserted, namespace[:3]...)
+	inserted = append(inserted, core.NamespaceElement{Value: domain})
+	return append(inserted, namespace[3:]...)
+}
+
+// removeDomain returns copy of requested namespace without domain element preceding tenant element, namespaces
+// of cloud-wide metrics, given in original or sanitized form, are returned unchanged
+func removeDomain(namespace core.Namespace, cloudNs string) (core.Namespace, error) {
+	if len(namespace) > 3 && (namespace[3].Value == cloudNs || namespace[3].Value == sanitizeSegment(cloudNs)) {
+		return namespace, nil
+	}
+	if len(namespace) < 7 {
+		return nil, fmt.Errorf("Incorrect namespace length. Expected 7 including domain is %d", len(namespace))
+	}
+	removed := make(core.Namespace, 0, len(namespace)-1)
+	removed = append(removed, namespace[:3]...)
+	return append(removed, namespace[4:]...), nil
+}
+
+// flatTags returns tag names by position of nested namespace elements which are moved into tags in flat layout
+func flatTags(namespace []string) map[int]string {
+	tags := map[int]string{3: tenantTag}
+	if len(namespace) < 7 {
+		return tags
+	}
+	switch {
+	case namespace[4] == "limits" && namespace[5] == "by_type" && len(namespace) == 8:
+		tags[6] = "volume_type"
+	case namespace[4] == "volumes" && namespace[5] == "extra":
+		tags[6] = "extra_field"
+	case namespace[4] == "snapshots" && namespace[5] == "by_metadata":
+		tags[6] = "metadata_key"
+		if len(namespace) == 9 {
+			tags[7] = "metadata_value"
+		}
+	case namespace[4] == "qos_specs" && namespace[6] == "associations":
+		tags[5] = "qos_spec"
+	case namespace[4] == "pools" && namespace[6] == "overcommit_ratio":
+		tags[5] = "pool"
+	case namespace[4] == "services" && len(namespace) == 8 && namespace[7] == "heartbeat_age_seconds":
+		tags[5], tags[6] = "host", "binary"
+	case strings.HasPrefix(namespace[5], "by_") && len(namespace) == 8:
+		tags[6] = strings.TrimPrefix(namespace[5], "by_")
+	}
+	return tags
+}
+
+// flattenNamespace returns nested namespace without elements moved into tags, and those tags
+func flattenNamespace(namespace core.Namespace) (core.Namespace, map[string]string) {
+	positions := flatTags(namespace.Strings())
+	flat := make(core.Namespace, 0, len(namespace))
+	tags := make(map[string]string, len(positions))
+	for i, element := range namespace {
+		if tag, found := positions[i]; found {
+			tags[tag] = element.Value
+			continue
+		}
+		flat = append(flat, core.NamespaceElement{Value: element.Value})
+	}
+	return flat, tags
+}
+
+// flattenMetricTypes returns metric types of flat layout for nested ones, metric types of all tenants and values
+// share single flat namespace
+func flattenMetricTypes(mts []plugin.MetricType) []plugin.MetricType {
+	flat := []plugin.MetricType{}
+	seen := map[string]bool{}
+	for _, mt := range mts {
+		namespace, _ := flattenNamespace(mt.Namespace())
+		if key := namespace.String(); !seen[key] {
+			seen[key] = true
+			flat = append(flat, plugin.MetricType{Namespace_: namespace, Config_: mt.Config_})
+		}
+	}
+	return flat
+}
+
+// nestMetricTypes resolves requested metric types of flat layout to nested ones of all tenants found in nested
+// catalog, so nested collection can be reused. Namespaces are sanitized when requested, the same way as in catalog
+func nestMetricTypes(requested []plugin.MetricType, catalog []plugin.MetricType, sanitize bool) []plugin.MetricType {
+	byFlat := map[string][]core.Namespace{}
+	for _, mt := range catalog {
+		nested := mt.Namespace()
+		if sanitize {
+			nested = sanitizeNamespace(nested)
+		}
+		namespace, _ := flattenNamespace(nested)
+		byFlat[namespace.String()] = append(byFlat[namespace.String()], nested)
+	}
+
+	nested := []plugin.MetricType{}
+	for _, mt := range requested {
+		for _, namespace := range byFlat[mt.Namespace().String()] {
+			nestedType := mt
+			nestedType.Namespace_ = namespace
+			nested = append(nested, nestedType)
+		}
+	}
+	return nested
+}
+
+// flattenMetrics moves tenant and descriptive elements of emitted metrics from namespaces into tags,
+// metrics are sorted by flat namespace first so series of the same metric are emitted together
+func flattenMetrics(metrics []plugin.MetricType) []plugin.MetricType {
+	for i := range metrics {
+		namespace, tags := flattenNamespace(metrics[i].Namespace())
+		for key, value := range metrics[i].Tags_ {
+			tags[key] = value
+		}
+		metrics[i].Namespace_ = namespace
+		metrics[i].Tags_ = tags
+	}
+	sort.Stable(metricTypesBy{metrics, func(a, b plugin.MetricType) bool {
+		return a.Namespace().String() < b.Namespace().String()
+	}})
+	return metrics
+}
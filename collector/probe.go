@@ -0,0 +1,134 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rackspace/gophercloud"
+
+	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack"
+	blockstoragev2 "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2"
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
+)
+
+const (
+	// defaultProbeTimeout is default time limit in seconds of single reachability probe
+	defaultProbeTimeout = 2
+)
+
+// versionSuffix matches API version and tenant ending path of Block Storage endpoint (eg. /v2/<tenant_id>)
+var versionSuffix = regexp.MustCompile(`/v[0-9]+(\.[0-9]+)?(/[^/]*)?/?$`)
+
+// probeElements are last namespace elements of cloud meta metrics reporting reachability of endpoints
+var probeElements = []string{"keystone_reachable", "cinder_reachable"}
+
+// isProbe checks if namespace requests reachability of endpoint, cloud namespace may be given in its sanitized form
+func isProbe(namespace []string, cloudNs string) bool {
+	if len(namespace) != 6 || (namespace[3] != cloudNs && namespace[3] != sanitizeSegment(cloudNs)) || namespace[4] != "meta" {
+		return false
+	}
+	for _, element := range probeElements {
+		if namespace[5] == element {
+			return true
+		}
+	}
+	return false
+}
+
+// probeEndpoints checks whether Keystone and Cinder respond to unauthenticated version discovery, results are set
+// into meta as 0/1 flags. Cinder endpoint is configured cinder_url or the one found in service catalog of already
+// authenticated provider, Cinder is not probed when its endpoint cannot be resolved.
+func (c *collector) probeEndpoints(cfg interface{}, meta *types.Meta) {
+	transport := getTransport(cfg)
+	timeout := time.Duration(getConfigInt(cfg, "probe_timeout", defaultProbeTimeout)) * time.Second
+	flag := func(endpoint string) *int {
+		reachable := 1
+		if err := openstackintel.Probe(transport, endpoint, timeout); err != nil {
+			log.Printf("Endpoint %s is not reachable: %v", endpoint, err)
+			reachable = 0
+		}
+		return &reachable
+	}
+
+	opts, err := getAuthOpts(cfg)
+	if err != nil {
+		log.Printf("Cannot probe endpoints: %v", err)
+		return
+	}
+	meta.KeystoneReachable = flag(opts.Endpoint)
+
+	cinderURL := opts.CinderURL
+	if cinderURL == "" {
+		cinderURL = c.cinderEndpoint(cfg)
+	}
+	if cinderURL == "" {
+		log.Printf("Cinder endpoint is unknown, Cinder is not probed")
+		return
+	}
+	root, err := versionRoot(cinderURL)
+	if err != nil {
+		log.Printf("Cannot probe Cinder endpoint %s: %v", cinderURL, err)
+		return
+	}
+	meta.CinderReachable = flag(root)
+}
+
+// versionRoot returns version discovery URL of Block Storage endpoint, only version and tenant are trimmed from
+// its path, so Cinder served under path prefix (eg. /volume) is probed at its own root
+func versionRoot(endpoint string) (string, error) {
+	root, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	root.Path = versionSuffix.ReplaceAllString(root.Path, "")
+	if !strings.HasSuffix(root.Path, "/") {
+		root.Path += "/"
+	}
+	root.RawQuery = ""
+	return root.String(), nil
+}
+
+// cinderEndpoint returns Cinder endpoint from service catalog of authenticated provider, provider of configured
+// tenant is authenticated when none was authenticated yet; empty endpoint is returned when authentication fails
+func (c *collector) cinderEndpoint(cfg interface{}) string {
+	c.authMutex.Lock()
+	for _, provider := range c.providers {
+		if client, err := blockstoragev2.NewBlockStorageV2(provider, gophercloud.EndpointOpts{}); err == nil {
+			c.authMutex.Unlock()
+			return client.ResourceBaseURL()
+		}
+	}
+	c.authMutex.Unlock()
+
+	tenant := getConfigString(cfg, "tenant", "")
+	if getConfigString(cfg, "system_scope", "") != "" {
+		tenant = systemScopeKey
+	}
+	provider, _, err := c.authenticate(cfg, tenant)
+	if err != nil {
+		log.Printf("Cannot resolve Cinder endpoint: %v", err)
+		return ""
+	}
+	client, err := blockstoragev2.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return ""
+	}
+	return client.ResourceBaseURL()
+}
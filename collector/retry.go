@@ -0,0 +1,80 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"time"
+
+	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack"
+)
+
+const (
+	// defaultRetryBaseDelay is default delay in milliseconds before first retry, doubled with each next attempt
+	defaultRetryBaseDelay = 500
+
+	// maxRetryAfter caps delay requested by rate limiting server, so single call can not stall whole collection
+	maxRetryAfter = time.Minute
+)
+
+// retryPolicy describes how many times and with what backoff failed call is repeated,
+// positive deadline bounds total time spent in call including retries
+type retryPolicy struct {
+	count     int
+	baseDelay time.Duration
+	deadline  time.Duration
+}
+
+// getRetryPolicy reads retry_count, retry_base_delay and retry_deadline (milliseconds) from configuration,
+// by default calls are not retried
+func getRetryPolicy(cfg interface{}) retryPolicy {
+	count := getConfigInt(cfg, "retry_count", 0)
+	if count < 0 {
+		count = 0
+	}
+	delay := getConfigInt(cfg, "retry_base_delay", defaultRetryBaseDelay)
+	if delay < 0 {
+		delay = 0
+	}
+	deadline := getConfigInt(cfg, "retry_deadline", 0)
+	if deadline < 0 {
+		deadline = 0
+	}
+	return retryPolicy{count: count, baseDelay: time.Duration(delay) * time.Millisecond, deadline: time.Duration(deadline) * time.Millisecond}
+}
+
+// do calls fn until it succeeds or retries are exhausted, sleeping with exponential backoff in between.
+// When call was rate limited (HTTP 429) with Retry-After, delay requested by server is used instead
+// Call is not retried when next attempt would start past deadline. It returns error of last attempt
+func (p retryPolicy) do(fn func() error) error {
+	start := time.Now()
+	err := fn()
+	delay := p.baseDelay
+	for attempt := 0; err != nil && attempt < p.count; attempt++ {
+		wait := delay
+		if after, ok := openstackintel.RetryAfter(err); ok {
+			wait = after
+			if wait > maxRetryAfter {
+				wait = maxRetryAfter
+			}
+		}
+		if p.deadline > 0 && time.Since(start)+wait > p.deadline {
+			break
+		}
+		time.Sleep(wait)
+		delay *= 2
+		err = fn()
+	}
+	return err
+}
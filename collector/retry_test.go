@@ -0,0 +1,145 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	Convey("Given retry policy with 2 retries", t, func() {
+		policy := retryPolicy{count: 2, baseDelay: time.Millisecond}
+
+		Convey("When call fails transiently", func() {
+			calls := 0
+			err := policy.do(func() error {
+				calls++
+				if calls < 3 {
+					return errors.New("transient")
+				}
+				return nil
+			})
+
+			Convey("Then it is retried until success", func() {
+				So(err, ShouldBeNil)
+				So(calls, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When call keeps failing", func() {
+			calls := 0
+			err := policy.do(func() error {
+				calls++
+				return errors.New("permanent")
+			})
+
+			Convey("Then last error is returned after retries are exhausted", func() {
+				So(err, ShouldNotBeNil)
+				So(calls, ShouldEqual, 3)
+			})
+		})
+	})
+
+	Convey("Given retry policy with many retries and deadline", t, func() {
+		policy := retryPolicy{count: 10, baseDelay: 20 * time.Millisecond, deadline: 50 * time.Millisecond}
+
+		Convey("When call keeps failing", func() {
+			calls := 0
+			start := time.Now()
+			err := policy.do(func() error {
+				calls++
+				return errors.New("permanent")
+			})
+
+			Convey("Then retries stop before deadline is exceeded", func() {
+				So(err, ShouldNotBeNil)
+				So(calls, ShouldEqual, 2)
+				So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+			})
+		})
+	})
+
+	Convey("Given server rate limiting first request", t, func() {
+		requests := 0
+		retryAfter := ""
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests++; requests == 1 {
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		client := http.Client{Transport: openstackintel.RateLimited(nil)}
+		get := func() error {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+			return nil
+		}
+
+		Convey("When server responds with Retry-After", func() {
+			retryAfter = "0"
+			policy := retryPolicy{count: 1, baseDelay: 5 * time.Second}
+			start := time.Now()
+			err := policy.do(get)
+
+			Convey("Then call is retried after delay requested by server instead of backoff", func() {
+				So(err, ShouldBeNil)
+				So(requests, ShouldEqual, 2)
+				So(time.Since(start), ShouldBeLessThan, time.Second)
+			})
+		})
+
+		Convey("When server responds without Retry-After", func() {
+			policy := retryPolicy{count: 1, baseDelay: time.Millisecond}
+			err := policy.do(get)
+
+			Convey("Then call is retried with exponential backoff", func() {
+				So(err, ShouldBeNil)
+				So(requests, ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given configuration without retry settings", t, func() {
+		cfg := setupCfg("http://localhost", "me", "secret", "admin")
+
+		Convey("Then calls are not retried", func() {
+			policy := getRetryPolicy(cfg)
+			So(policy.count, ShouldEqual, 0)
+			So(policy.baseDelay, ShouldEqual, defaultRetryBaseDelay*time.Millisecond)
+		})
+	})
+}
@@ -0,0 +1,132 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// originalNamespaceTag is tag holding original namespace of metric which namespace was sanitized
+const originalNamespaceTag = "original_namespace"
+
+// sanitizeSegment makes namespace segment safe for Prometheus metric and label names: each character other than
+// ASCII letter, digit or underscore is replaced by underscore and segment starting with digit is prefixed with
+// underscore. Same segment is always sanitized the same way, different segments may share sanitized form.
+func sanitizeSegment(segment string) string {
+	if segment == "" {
+		return "_"
+	}
+	sanitized := strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, segment)
+	if sanitized[0] >= '0' && sanitized[0] <= '9' {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
+
+// sanitizeNamespace returns copy of namespace with sanitized segments, dynamic elements not resolved yet are kept
+func sanitizeNamespace(namespace core.Namespace) core.Namespace {
+	sanitized := make(core.Namespace, len(namespace))
+	copy(sanitized, namespace)
+	for i := range sanitized {
+		if sanitized[i].Value != "*" {
+			sanitized[i].Value = sanitizeSegment(sanitized[i].Value)
+		}
+	}
+	return sanitized
+}
+
+// sanitizer resolves sanitized segments of requested namespaces to original ones, segments are resolved
+// by position as sanitized forms are unique only among segments of the same kind
+// tenants - original tenant names by sanitized ones
+// byPosition - original configured names (volume types, metadata keys, extra fields) by family, subfamily
+// and sanitized name, those are located under <tenant>/<family>/<subfamily>/<name>
+type sanitizer struct {
+	tenants    map[string]string
+	byPosition map[string]map[string]string
+}
+
+// newSanitizer creates sanitizer for given tenants and names configured by user, it fails when sanitized
+// tenant names clash, as metrics of those tenants could not be told apart
+func newSanitizer(tenants map[string]string, cfg interface{}) (*sanitizer, error) {
+	s := &sanitizer{tenants: map[string]string{}, byPosition: map[string]map[string]string{}}
+	// cloud namespace takes place of tenant name in cloud-wide metrics
+	names := []string{getConfigString(cfg, "cloud_namespace", defaultCloudNamespace)}
+	for _, tenantName := range tenants {
+		names = append(names, tenantName)
+	}
+	for _, tenantName := range names {
+		sanitized := sanitizeSegment(tenantName)
+		if other, found := s.tenants[sanitized]; found && other != tenantName {
+			return nil, fmt.Errorf("Tenants %s and %s are both sanitized to %s, disable sanitize_namespace", other, tenantName, sanitized)
+		}
+		s.tenants[sanitized] = tenantName
+	}
+
+	positions := map[string][]string{
+		"limits/by_type":        getQuotaVolumeTypes(cfg),
+		"snapshots/by_metadata": getConfigList(cfg, "group_by_snapshot_metadata"),
+		"volumes/extra":         sortedKeys(getExtraVolumeFields(cfg)),
+	}
+	for position, names := range positions {
+		s.byPosition[position] = map[string]string{}
+		for _, name := range names {
+			s.byPosition[position][sanitizeSegment(name)] = name
+		}
+	}
+	return s, nil
+}
+
+// restore returns copy of requested namespace with tenant and configured names resolved to original ones,
+// segments not known to sanitizer are kept as requested
+func (s *sanitizer) restore(namespace core.Namespace) core.Namespace {
+	restored := make(core.Namespace, len(namespace))
+	copy(restored, namespace)
+	if len(restored) > 3 {
+		if tenantName, found := s.tenants[restored[3].Value]; found {
+			restored[3].Value = tenantName
+		}
+	}
+	if len(restored) > 6 {
+		names := s.byPosition[restored[4].Value+"/"+restored[5].Value]
+		if name, found := names[restored[6].Value]; found {
+			restored[6].Value = name
+		}
+	}
+	return restored
+}
+
+// matchKey returns key which requested value refers to, either directly or by its sanitized form,
+// requested value is returned when no key matches
+func matchKey(keys []string, requested string) string {
+	for _, key := range keys {
+		if key == requested {
+			return key
+		}
+	}
+	for _, key := range keys {
+		if sanitizeSegment(key) == requested {
+			return key
+		}
+	}
+	return requested
+}
@@ -0,0 +1,98 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSanitizeSegment(t *testing.T) {
+	Convey("Given namespace segments", t, func() {
+		Convey("When segment is already safe", func() {
+			Convey("Then it is kept as it is", func() {
+				So(sanitizeSegment("MaxTotalVolumes"), ShouldEqual, "MaxTotalVolumes")
+				So(sanitizeSegment("_cloud"), ShouldEqual, "_cloud")
+			})
+		})
+
+		Convey("When segment contains characters not allowed by Prometheus", func() {
+			Convey("Then each of them is replaced by underscore", func() {
+				So(sanitizeSegment("web-prod@default"), ShouldEqual, "web_prod_default")
+				So(sanitizeSegment("in-use"), ShouldEqual, "in_use")
+				So(sanitizeSegment("zażółć"), ShouldEqual, "za____")
+			})
+		})
+
+		Convey("When segment starts with digit or is empty", func() {
+			Convey("Then it is prefixed with underscore", func() {
+				So(sanitizeSegment("1st"), ShouldEqual, "_1st")
+				So(sanitizeSegment(""), ShouldEqual, "_")
+			})
+		})
+
+		Convey("When segment is sanitized twice", func() {
+			Convey("Then result does not change", func() {
+				So(sanitizeSegment(sanitizeSegment("9 volumes-ssd")), ShouldEqual, sanitizeSegment("9 volumes-ssd"))
+			})
+		})
+	})
+}
+
+func TestSanitizer(t *testing.T) {
+	Convey("Given tenants and configured names", t, func() {
+		node := cdata.NewNode()
+		node.AddItem("quota_volume_types", ctypes.ConfigValueStr{Value: "ssd-fast"})
+		cfg := plugin.ConfigType{ConfigDataNode: node}
+		tenants := map[string]string{"t1": "web-prod", "t2": "demo"}
+
+		Convey("When sanitized namespace is restored", func() {
+			s, err := newSanitizer(tenants, cfg)
+			So(err, ShouldBeNil)
+			restored := s.restore(core.NewNamespace("intel", "openstack", "cinder", "web_prod", "limits", "by_type", "ssd_fast", "gigabytes"))
+
+			Convey("Then tenant and volume type are resolved to original names", func() {
+				So(restored.Strings(), ShouldResemble, []string{"intel", "openstack", "cinder", "web-prod", "limits", "by_type", "ssd-fast", "gigabytes"})
+			})
+		})
+
+		Convey("When sanitized tenant names clash", func() {
+			tenants["t3"] = "web_prod"
+			_, err := newSanitizer(tenants, cfg)
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When requested value is matched against keys", func() {
+			keys := []string{"in-use", "in_use_2"}
+
+			Convey("Then original key is returned for sanitized value", func() {
+				So(matchKey(keys, "in_use"), ShouldEqual, "in-use")
+				So(matchKey(keys, "in_use_2"), ShouldEqual, "in_use_2")
+				So(matchKey(keys, "missing"), ShouldEqual, "missing")
+			})
+		})
+	})
+}
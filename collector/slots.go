@@ -0,0 +1,74 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// tenantSlots bounds number of tenants processed at once, tenant holding its slot longer than positive budget gives
+// it up to next tenant and continues aside; at most size tenants continue aside at once, so number of concurrent
+// calls never exceeds twice the size. Size not positive means no bound
+type tenantSlots struct {
+	slots   chan struct{}
+	running chan struct{}
+	budget  time.Duration
+}
+
+// newTenantSlots creates slots for given number of tenants at once and time budget of each tenant
+func newTenantSlots(size int, budget time.Duration) *tenantSlots {
+	if size <= 0 {
+		return &tenantSlots{}
+	}
+	return &tenantSlots{
+		slots:   make(chan struct{}, size),
+		running: make(chan struct{}, 2*size),
+		budget:  budget,
+	}
+}
+
+// getTenantSlots creates slots configured by tenant_batch_size and tenant_time_budget (milliseconds)
+func getTenantSlots(cfg interface{}) *tenantSlots {
+	budget := time.Duration(getConfigInt(cfg, "tenant_time_budget", 0)) * time.Millisecond
+	return newTenantSlots(getConfigInt(cfg, "tenant_batch_size", 0), budget)
+}
+
+// acquire waits for free slot for given tenant, returned function has to be called once tenant is processed
+func (s *tenantSlots) acquire(tenant string) func() {
+	if s.slots == nil {
+		return func() {}
+	}
+	s.running <- struct{}{}
+	s.slots <- struct{}{}
+
+	var release sync.Once
+	releaseSlot := func() { release.Do(func() { <-s.slots }) }
+	var timer *time.Timer
+	if s.budget > 0 {
+		timer = time.AfterFunc(s.budget, func() {
+			log.Printf("Tenant %s exceeded time budget of %s, processing next tenant meanwhile", tenant, s.budget)
+			releaseSlot()
+		})
+	}
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		releaseSlot()
+		<-s.running
+	}
+}
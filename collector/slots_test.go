@@ -0,0 +1,89 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// acquired acquires slot for tenant aside and tells whether it was acquired within timeout
+func acquired(slots *tenantSlots, tenant string, timeout time.Duration) (bool, func()) {
+	releases := make(chan func(), 1)
+	go func() { releases <- slots.acquire(tenant) }()
+	select {
+	case release := <-releases:
+		return true, release
+	case <-time.After(timeout):
+		return false, func() { (<-releases)() }
+	}
+}
+
+func TestTenantSlots(t *testing.T) {
+	Convey("Given slots for single tenant without time budget", t, func() {
+		slots := newTenantSlots(1, 0)
+		release := slots.acquire("admin")
+
+		Convey("When next tenant acquires slot", func() {
+			ok, releaseNext := acquired(slots, "demo", 20*time.Millisecond)
+
+			Convey("Then it waits until first tenant is processed", func() {
+				So(ok, ShouldBeFalse)
+				release()
+				releaseNext()
+			})
+		})
+	})
+
+	Convey("Given slots for single tenant with time budget", t, func() {
+		slots := newTenantSlots(1, 5*time.Millisecond)
+		release := slots.acquire("admin")
+
+		Convey("When first tenant exceeds its budget", func() {
+			ok, releaseNext := acquired(slots, "demo", 100*time.Millisecond)
+			okThird, releaseThird := acquired(slots, "prod", 50*time.Millisecond)
+
+			Convey("Then next tenant is processed meanwhile but overflow is capped", func() {
+				So(ok, ShouldBeTrue)
+				So(okThird, ShouldBeFalse)
+				release()
+				releaseNext()
+				releaseThird()
+			})
+		})
+	})
+
+	Convey("Given slots without size", t, func() {
+		slots := newTenantSlots(0, time.Millisecond)
+
+		Convey("When many tenants acquire slots", func() {
+			releases := []func(){}
+			for i := 0; i < 10; i++ {
+				releases = append(releases, slots.acquire("demo"))
+			}
+
+			Convey("Then none waits", func() {
+				So(releases, ShouldHaveLength, 10)
+				for _, release := range releases {
+					release()
+				}
+			})
+		})
+	})
+}
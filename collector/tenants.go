@@ -0,0 +1,81 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTenantsCacheTTL is default time in seconds for which listed tenants are reused
+	defaultTenantsCacheTTL = 30
+)
+
+// tenantsCall represents tenants listing shared by all callers asking for the same key,
+// it is in flight until done is closed and then it is reused until expiry
+type tenantsCall struct {
+	done    chan struct{}
+	tenants map[string]string
+	err     error
+	expires time.Time
+}
+
+// tenantsCache collapses concurrent and repeated tenants listings into single Keystone request
+var tenantsCache = struct {
+	sync.Mutex
+	calls map[string]*tenantsCall
+}{calls: map[string]*tenantsCall{}}
+
+// cachedTenants returns tenants listed by list function, callers asking for the same key while listing is in flight
+// wait for its result, which is then reused for ttl extended with random jitter. Failed listings are not cached.
+// Returned map is shared between callers and must not be modified.
+func cachedTenants(key string, ttl time.Duration, list func() (map[string]string, error)) (map[string]string, error) {
+	tenantsCache.Lock()
+	call, found := tenantsCache.calls[key]
+	if found {
+		select {
+		case <-call.done:
+			found = call.err == nil && time.Now().Before(call.expires)
+		default:
+			// listing in flight
+		}
+	}
+	if found {
+		tenantsCache.Unlock()
+		<-call.done
+		return call.tenants, call.err
+	}
+
+	call = &tenantsCall{done: make(chan struct{})}
+	tenantsCache.calls[key] = call
+	tenantsCache.Unlock()
+
+	call.tenants, call.err = list()
+	call.expires = time.Now().Add(withJitter(ttl))
+	close(call.done)
+
+	return call.tenants, call.err
+}
+
+// withJitter extends duration by random value up to 10% of it, so cached entries of many plugin instances
+// do not expire at once
+func withJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
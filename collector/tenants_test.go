@@ -0,0 +1,92 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCachedTenants(t *testing.T) {
+	Convey("Given tenants listing", t, func() {
+		var mutex sync.Mutex
+		calls := 0
+		release := make(chan struct{})
+		list := func() (map[string]string, error) {
+			mutex.Lock()
+			calls++
+			mutex.Unlock()
+			<-release
+			return map[string]string{"id": "tenant"}, nil
+		}
+
+		Convey("When it is requested concurrently", func() {
+			var done sync.WaitGroup
+			results := make([]map[string]string, 5)
+			for i := range results {
+				done.Add(1)
+				go func(i int) {
+					defer done.Done()
+					results[i], _ = cachedTenants("concurrent", time.Minute, list)
+				}(i)
+			}
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			done.Wait()
+			cachedTenants("concurrent", time.Minute, list)
+
+			Convey("Then single listing is done and its result is reused until expiry", func() {
+				So(calls, ShouldEqual, 1)
+				for _, tenants := range results {
+					So(tenants["id"], ShouldEqual, "tenant")
+				}
+			})
+		})
+
+		Convey("When cache expires", func() {
+			close(release)
+			cachedTenants("expiring", 0, list)
+			cachedTenants("expiring", 0, list)
+
+			Convey("Then tenants are listed again", func() {
+				So(calls, ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given failing tenants listing", t, func() {
+		calls := 0
+		list := func() (map[string]string, error) {
+			calls++
+			return nil, errors.New("keystone unavailable")
+		}
+
+		Convey("When it is requested twice", func() {
+			_, err := cachedTenants("failing", time.Minute, list)
+			cachedTenants("failing", time.Minute, list)
+
+			Convey("Then error is returned and not cached", func() {
+				So(err, ShouldNotBeNil)
+				So(calls, ShouldEqual, 2)
+			})
+		})
+	})
+}
@@ -0,0 +1,51 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import "strings"
+
+// flagElements are last namespace elements of metrics reporting 0/1 flags, which have no unit
+var flagElements = []string{"from_cache", "changed", "catalog_ok", "tenants_visible_ok", "all_tenants_ok", "keystone_reachable", "cinder_reachable", truncatedElement}
+
+// metricUnit returns unit of metric derived from its namespace: "ms" and "s" for durations, "B" and "GB" for sizes,
+// "%" for percentages and "count" for numbers of items; flags, ratios, rates and extra volume fields have no unit
+func metricUnit(namespace []string) string {
+	if len(namespace) < 6 {
+		return ""
+	}
+	last := namespace[len(namespace)-1]
+	switch {
+	case len(namespace) == 7 && namespace[4] == "volumes" && namespace[5] == "extra":
+		return ""
+	case strings.HasSuffix(last, "_ms"):
+		return "ms"
+	case strings.HasSuffix(last, "_seconds"):
+		return "s"
+	case strings.HasSuffix(last, "_percent"):
+		return "%"
+	case last == "bytes" || strings.HasSuffix(last, "_bytes"):
+		return "B"
+	case strings.Contains(strings.ToLower(last), "gigabytes") || strings.HasSuffix(last, "_gb"):
+		return "GB"
+	case strings.HasSuffix(last, "_ratio") || strings.HasSuffix(last, "_rate"):
+		return ""
+	}
+	for _, flag := range flagElements {
+		if last == flag {
+			return ""
+		}
+	}
+	return "count"
+}
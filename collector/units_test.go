@@ -0,0 +1,51 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetricUnit(t *testing.T) {
+	Convey("Given namespaces of metrics", t, func() {
+		for namespace, unit := range map[string]string{
+			"/intel/openstack/cinder/demo/volumes/count":                           "count",
+			"/intel/openstack/cinder/demo/volumes/bytes":                           "B",
+			"/intel/openstack/cinder/demo/volumes/inuse_gb":                        "GB",
+			"/intel/openstack/cinder/demo/volumes/error_percent":                   "%",
+			"/intel/openstack/cinder/demo/volumes/extra/size_gb":                   "",
+			"/intel/openstack/cinder/demo/volumes/by_status/available/gigabytes":   "GB",
+			"/intel/openstack/cinder/demo/snapshots/by_metadata/env/prod/count":    "count",
+			"/intel/openstack/cinder/demo/volumes/by_status/_truncated":            "",
+			"/intel/openstack/cinder/demo/limits/MaxTotalVolumeGigabytes":          "GB",
+			"/intel/openstack/cinder/demo/limits/MaxTotalVolumes":                  "count",
+			"/intel/openstack/cinder/demo/limits/cache_age_seconds":                "s",
+			"/intel/openstack/cinder/demo/limits/from_cache":                       "",
+			"/intel/openstack/cinder/_cloud/meta/cinder_latency_ms":                "ms",
+			"/intel/openstack/cinder/_cloud/meta/heap_bytes":                       "B",
+			"/intel/openstack/cinder/_cloud/meta/api_calls/volumes":                "count",
+			"/intel/openstack/cinder/_cloud/meta/api_error_rate":                   "",
+			"/intel/openstack/cinder/_cloud/meta/cinder_reachable":                 "",
+			"/intel/openstack/cinder/_cloud/pools/node1@lvm#thin/overcommit_ratio": "",
+		} {
+			So(metricUnit(strings.Split(strings.TrimPrefix(namespace, "/"), "/")), ShouldEqual, unit)
+		}
+	})
+}
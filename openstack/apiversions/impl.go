@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack/blockstorage/v1/apiversions"
 	"github.com/rackspace/gophercloud/pagination"
@@ -38,3 +39,22 @@ func Get(c *gophercloud.ServiceClient) apiversions.APIVersionPage {
 	}
 	return apiversions.APIVersionPage{pagination.SinglePageBase{Result: res, URL: *u}}
 }
+
+// ExtractMicroversions returns maximal microversions reported by version discovery keyed by API version ID,
+// versions not supporting microversions (eg. v1, v2) are reported with empty microversion
+func ExtractMicroversions(page pagination.Page) (map[string]string, error) {
+	var resp struct {
+		Versions []struct {
+			ID      string `mapstructure:"id"`
+			Version string `mapstructure:"version"`
+		} `mapstructure:"versions"`
+	}
+
+	err := mapstructure.Decode(page.(apiversions.APIVersionPage).Body, &resp)
+
+	microversions := map[string]string{}
+	for _, v := range resp.Versions {
+		microversions[v.ID] = v.Version
+	}
+	return microversions, err
+}
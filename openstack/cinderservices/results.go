@@ -0,0 +1,50 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Cinder API responses and their processing for Cinder services
+package cinderservices
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud"
+)
+
+// Service contains information associated with Cinder service running on given host
+type Service struct {
+	Binary    string `mapstructure:"binary"`
+	Host      string `mapstructure:"host"`
+	Zone      string `mapstructure:"zone"`
+	Status    string `mapstructure:"status"`
+	State     string `mapstructure:"state"`
+	UpdatedAt string `mapstructure:"updated_at"`
+}
+
+// ListResult contains the response body and error from a List request
+type ListResult struct {
+	gophercloud.Result
+}
+
+// Extract will get services out of the ListResult object
+func (r ListResult) Extract() ([]Service, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		Services []Service `mapstructure:"services"`
+	}
+
+	err := mapstructure.Decode(r.Body, &res)
+	return res.Services, err
+}
@@ -18,6 +18,11 @@ package openstack
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack"
@@ -25,18 +30,79 @@ import (
 	"github.com/rackspace/gophercloud/openstack/identity/v2/tenants"
 
 	apiversionsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/apiversions"
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/projects"
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/roleassignments"
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/tokens"
 	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2"
 )
 
+// DomainSeparator separates project name and domain ID in names of projects sharing name across domains
+const DomainSeparator = "@"
+
 var apiPriority = map[string]int{
 	"v1.0": 1,
 	"v2.0": 2,
 }
 
+// AuthOpts holds Keystone endpoint, credentials and scope used for authentication
+// Tenant - name of tenant which token is scoped to, empty for unscoped token
+// TenantID - ID of tenant which token is scoped to, takes precedence over Tenant
+// UserDomainName, UserDomainID - domain of user, ID takes precedence over name
+// ProjectDomainName, ProjectDomainID - domain of Tenant, needed only when it differs from domain of user
+// SystemScope - system scope of token (only "all" is recognized by Keystone), takes precedence over Tenant
+// DomainScopeName, DomainScopeID - domain of token authenticated without Tenant, projects listed by GetTenants are
+// limited to it; ID takes precedence over name, SystemScope takes precedence over both
+// Transport - HTTP transport used by provider client and its service clients, nil means default one
+// DisableReauth - when set, provider does not authenticate again with the same credentials on expired token (401)
+// CinderURL - Block Storage API v2 endpoint used instead of one found in service catalog, empty means catalog lookup
+type AuthOpts struct {
+	Endpoint          string
+	User              string
+	Password          string
+	Tenant            string
+	TenantID          string
+	UserDomainName    string
+	UserDomainID      string
+	ProjectDomainName string
+	ProjectDomainID   string
+	SystemScope       string
+	DomainScopeName   string
+	DomainScopeID     string
+	Transport         http.RoundTripper
+	DisableReauth     bool
+	CinderURL         string
+}
+
+// domainScoped checks if token authenticated without tenant is scoped to domain
+func (opts AuthOpts) domainScoped() bool {
+	return opts.SystemScope == "" && (opts.DomainScopeName != "" || opts.DomainScopeID != "")
+}
+
+// projectDomainDiffers checks if domain of tenant is set apart from domain of user
+func (opts AuthOpts) projectDomainDiffers() bool {
+	if opts.ProjectDomainName == "" && opts.ProjectDomainID == "" {
+		return false
+	}
+	return opts.ProjectDomainName != opts.UserDomainName || opts.ProjectDomainID != opts.UserDomainID
+}
+
+// CatalogError reports that Cinder service was not found in service catalog of authenticated token, usually because
+// of wrong region or disabled service
+type CatalogError struct {
+	Err error
+}
+
+func (e *CatalogError) Error() string {
+	return fmt.Sprintf("cinder service not found in catalog for any region: %v", e.Err)
+}
+
 // Commoner provides abstraction for shared functions mainly for mocking
 type Commoner interface {
-	GetTenants(endpoint, user, password, domain_name, domain_id string) (map[string]string, error)
+	GetTenants(opts AuthOpts, tags []string) (map[string]string, error)
+	GetTenantDomains(opts AuthOpts, tags []string) (map[string]string, error)
 	GetApiVersions(provider *gophercloud.ProviderClient) ([]string, error)
+	GetApiVersionsInfo(provider *gophercloud.ProviderClient) ([]APIVersion, error)
+	GetRoleProject(opts AuthOpts, role string) (string, error)
 }
 
 // Common is a receiver for Commoner interface
@@ -44,18 +110,34 @@ type Common struct{}
 
 // GetTenants is used to retrieve list of available tenant for authenticated user
 // List of tenants can then be used to authenticate user for each given tenant
-func (c Common) GetTenants(endpoint, user, password, domain_name, domain_id string) (map[string]string, error) {
+// When tags are provided only projects carrying all of them are returned (requires Keystone v3)
+// With system scope all projects are listed, with domain scope projects of that domain (requires Keystone v3)
+// Names are unique, names shared by projects of different domains are suffixed with domain ID (name@domain_id)
+func (c Common) GetTenants(opts AuthOpts, tags []string) (map[string]string, error) {
 	tnts := map[string]string{}
 
-	provider, err := Authenticate(endpoint, user, password, "", domain_name, domain_id)
+	opts.Tenant = ""
+	if opts.domainScoped() {
+		provider, domainID, err := authenticateDomain(opts)
+		if err != nil {
+			return nil, err
+		}
+		return getProjects(provider, tags, domainID)
+	}
+
+	provider, err := Authenticate(opts)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(tags) > 0 || opts.SystemScope != "" {
+		return getProjects(provider, tags, "")
+	}
+
 	client := openstack.NewIdentityV2(provider)
 
-	opts := tenants.ListOpts{}
-	pager := tenants.List(client, &opts)
+	listOpts := tenants.ListOpts{}
+	pager := tenants.List(client, &listOpts)
 
 	page, err := pager.AllPages()
 	if err != nil {
@@ -74,21 +156,179 @@ func (c Common) GetTenants(endpoint, user, password, domain_name, domain_id stri
 	return tnts, nil
 }
 
+// GetTenantDomains returns domain ID of tenants listed the same way as by GetTenants, by tenant ID
+// Domains are known only when tenants are listed from Keystone v3 (tags, system scope or domain scope),
+// nil is returned without any request otherwise
+func (c Common) GetTenantDomains(opts AuthOpts, tags []string) (map[string]string, error) {
+	opts.Tenant = ""
+	var provider *gophercloud.ProviderClient
+	var domainID string
+	var err error
+	switch {
+	case opts.domainScoped():
+		provider, domainID, err = authenticateDomain(opts)
+	case len(tags) > 0 || opts.SystemScope != "":
+		provider, err = Authenticate(opts)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matching, err := listProjects(provider, tags, domainID)
+	if err != nil {
+		return nil, err
+	}
+	domains := make(map[string]string, len(matching))
+	for _, p := range matching {
+		domains[p.ID] = p.DomainID
+	}
+	return domains, nil
+}
+
+// getProjects retrieves projects carrying all given tags, of given domain when its ID is not empty, from Keystone v3
+// Names shared by projects of different domains are suffixed with domain ID
+func getProjects(provider *gophercloud.ProviderClient, tags []string, domainID string) (map[string]string, error) {
+	tnts := map[string]string{}
+
+	matching, err := listProjects(provider, tags, domainID)
+	if err != nil {
+		return tnts, err
+	}
+
+	names := map[string]int{}
+	for _, p := range matching {
+		names[p.Name]++
+	}
+
+	for _, p := range matching {
+		if names[p.Name] > 1 {
+			tnts[p.ID] = p.Name + DomainSeparator + p.DomainID
+		} else {
+			tnts[p.ID] = p.Name
+		}
+	}
+
+	return tnts, nil
+}
+
+// listProjects lists projects carrying all given tags, of given domain when its ID is not empty, from Keystone v3
+// Tag filter is sent to Keystone and applied again on returned projects, as Keystone versions
+// not supporting tags ignore the filter and return all projects; domain filter is applied again the same way
+func listProjects(provider *gophercloud.ProviderClient, tags []string, domainID string) ([]projects.Project, error) {
+	client := identityV3(provider)
+
+	opts := projects.ListOpts{Tags: strings.Join(tags, ","), DomainID: domainID}
+	page, err := projects.List(client, opts).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("Listing projects requires Identity API v3: %v", err)
+	}
+
+	projectList, err := projects.ExtractProjects(page)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []projects.Project{}
+	for _, p := range projectList {
+		if p.HasTags(tags) && (domainID == "" || p.DomainID == domainID) {
+			matching = append(matching, p)
+		}
+	}
+	return matching, nil
+}
+
+// GetRoleProject returns name of project where user holds given role, directly or through group membership
+// Projects are checked in name order, so the same project is returned as long as assignments do not change
+// Requires Identity API v3
+func (c Common) GetRoleProject(opts AuthOpts, role string) (string, error) {
+	provider, err := newClient(opts)
+	if err != nil {
+		return "", err
+	}
+
+	// unscoped token is sufficient for listing role assignments of its user
+	result := tokens.Create(identityV3(provider), tokens.AuthOptions{
+		Username:   opts.User,
+		Password:   opts.Password,
+		DomainName: opts.UserDomainName,
+		DomainID:   opts.UserDomainID,
+	})
+	token, err := result.ExtractTokenID()
+	if err != nil {
+		return "", fmt.Errorf("Looking up role assignments requires Identity API v3: %v", err)
+	}
+	userID, err := result.ExtractUserID()
+	if err != nil {
+		return "", err
+	}
+	provider.TokenID = token
+
+	listOpts := roleassignments.ListOpts{UserID: userID, Effective: true, IncludeNames: true}
+	page, err := roleassignments.List(identityV3(provider), listOpts).AllPages()
+	if err != nil {
+		return "", err
+	}
+
+	assignments, err := roleassignments.ExtractRoleAssignments(page)
+	if err != nil {
+		return "", err
+	}
+
+	names := []string{}
+	for _, assignment := range assignments {
+		if assignment.Role.Name == role && assignment.Scope.Project.Name != "" {
+			names = append(names, assignment.Scope.Project.Name)
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("No project where user %s holds role %s found, set tenant explicitly", opts.User, role)
+	}
+	sort.Strings(names)
+
+	return names[0], nil
+}
+
 // GetApiVersions is used to retrieve list of available Cinder API versions
 // List of api version is then used to dispatch calls to proper API version based on defined priority
 func (c Common) GetApiVersions(provider *gophercloud.ProviderClient) ([]string, error) {
 	apis := []string{}
 
+	versions, err := c.GetApiVersionsInfo(provider)
+	if err != nil {
+		return apis, err
+	}
+
+	for _, version := range versions {
+		apis = append(apis, version.ID)
+	}
+
+	return apis, nil
+}
+
+// APIVersion describes Cinder API version reported by version discovery
+// Microversion - maximal microversion supported by API version, empty when API version has no microversions
+type APIVersion struct {
+	ID           string
+	Microversion string
+}
+
+// GetApiVersionsInfo is used to retrieve list of available Cinder API versions with their maximal microversions,
+// which reflect Cinder release. CatalogError is returned when Cinder is missing in service catalog, failure of version
+// discovery request is returned wrapped with context.
+func (c Common) GetApiVersionsInfo(provider *gophercloud.ProviderClient) ([]APIVersion, error) {
+	apis := []APIVersion{}
+
 	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
 
 	if err != nil {
-		return apis, err
+		return apis, &CatalogError{Err: err}
 	}
 
 	page := apiversionsintel.Get(client)
 	if page.Err != nil {
-		fmt.Println("err ", err)
-		return apis, err
+		return apis, fmt.Errorf("Discovering Cinder API versions failed: %v", page.Err)
 	}
 
 	apiVersions, err := apiversions.ExtractAPIVersions(page)
@@ -96,38 +336,280 @@ func (c Common) GetApiVersions(provider *gophercloud.ProviderClient) ([]string,
 		return apis, err
 	}
 
+	microversions, err := apiversionsintel.ExtractMicroversions(page)
+	if err != nil {
+		return apis, err
+	}
+
 	for _, apiVersion := range apiVersions {
-		apis = append(apis, apiVersion.ID)
+		apis = append(apis, APIVersion{ID: apiVersion.ID, Microversion: microversions[apiVersion.ID]})
 	}
 
 	return apis, nil
 }
 
+// VersionHint returns highest microversion of given API versions, which tells Cinder release,
+// or chosen API version when no microversions are reported
+func VersionHint(versions []APIVersion, chosen string) string {
+	hint := ""
+	for _, version := range versions {
+		if compareMicroversions(version.Microversion, hint) > 0 {
+			hint = version.Microversion
+		}
+	}
+	if hint == "" {
+		return chosen
+	}
+	return hint
+}
+
+// SupportsMicroversion returns true when any of given API versions supports required microversion
+func SupportsMicroversion(versions []APIVersion, required string) bool {
+	for _, version := range versions {
+		if version.Microversion != "" && compareMicroversions(version.Microversion, required) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compareMicroversions compares microversions in major.minor format, malformed or empty ones are the lowest
+func compareMicroversions(a, b string) int {
+	parse := func(v string) (int, int, bool) {
+		parts := strings.SplitN(v, ".", 2)
+		if len(parts) != 2 {
+			return 0, 0, false
+		}
+		major, err1 := strconv.Atoi(parts[0])
+		minor, err2 := strconv.Atoi(parts[1])
+		return major, minor, err1 == nil && err2 == nil
+	}
+	aMajor, aMinor, aOk := parse(a)
+	bMajor, bMinor, bOk := parse(b)
+	switch {
+	case !aOk && !bOk:
+		return 0
+	case !bOk:
+		return 1
+	case !aOk:
+		return -1
+	case aMajor != bMajor:
+		return aMajor - bMajor
+	default:
+		return aMinor - bMinor
+	}
+}
+
 // Authenticate is used to authenticate user for given tenant. Request is send to provided Keystone endpoint
 // Returns authenticated provider client, which is used as a base for service clients.
-func Authenticate(endpoint, user, password, tenant, domain_name, domain_id string) (*gophercloud.ProviderClient, error) {
+// Block storage service clients are created with Cinder endpoint given in options, if any, bypassing catalog.
+func Authenticate(opts AuthOpts) (*gophercloud.ProviderClient, error) {
+	provider, err := authenticate(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CinderURL != "" {
+		useCinderURL(provider, opts.CinderURL)
+	}
+	return provider, nil
+}
+
+// useCinderURL makes provider resolve block storage endpoint to given URL instead of looking it up in catalog,
+// Block Storage API v3 is reached at its v3 counterpart; locator is restored after reauthentication,
+// which resolves endpoints from catalog again
+func useCinderURL(provider *gophercloud.ProviderClient, cinderURL string) {
+	endpoint := gophercloud.NormalizeURL(cinderURL)
+	locator := func(eo gophercloud.EndpointOpts) (string, error) {
+		if eo.Type != "volumev2" {
+			return "", fmt.Errorf("Endpoint of %s service is not known, only Cinder endpoint is configured", eo.Type)
+		}
+		return endpoint, nil
+	}
+	provider.EndpointLocator = locator
+
+	if reauth := provider.ReauthFunc; reauth != nil {
+		provider.ReauthFunc = func() error {
+			if err := reauth(); err != nil {
+				return err
+			}
+			provider.EndpointLocator = locator
+			return nil
+		}
+	}
+}
+
+// authenticate obtains token scoped according to options, endpoints are resolved from its catalog
+func authenticate(opts AuthOpts) (*gophercloud.ProviderClient, error) {
+	if opts.SystemScope != "" {
+		return authenticateSystem(opts)
+	}
+	if opts.Tenant == "" && opts.TenantID == "" && opts.domainScoped() {
+		provider, _, err := authenticateDomain(opts)
+		return provider, err
+	}
+	// gophercloud looks tenant up in domain of user, tenant of other domain needs explicitly scoped v3 token
+	if opts.Tenant != "" && opts.TenantID == "" && opts.projectDomainDiffers() {
+		return authenticateV3(opts, tokens.Scope{
+			ProjectName:       opts.Tenant,
+			ProjectDomainID:   opts.ProjectDomainID,
+			ProjectDomainName: opts.ProjectDomainName,
+		})
+	}
+
 	authOpts := gophercloud.AuthOptions{
-		IdentityEndpoint: endpoint,
-		Username:         user,
-		Password:         password,
-		TenantName:       tenant,
-		AllowReauth:      true,
+		IdentityEndpoint: opts.Endpoint,
+		Username:         opts.User,
+		Password:         opts.Password,
+		TenantName:       opts.Tenant,
+		AllowReauth:      !opts.DisableReauth,
+	}
+	if opts.TenantID != "" {
+		authOpts.TenantName, authOpts.TenantID = "", opts.TenantID
+	}
+	if opts.UserDomainName != "" && opts.UserDomainID == "" {
+		authOpts.DomainName = opts.UserDomainName
+	}
+	if opts.UserDomainID != "" && opts.UserDomainName == "" {
+		authOpts.DomainID = opts.UserDomainID
+	}
+
+	provider, err := newClient(opts)
+	if err != nil {
+		return nil, err
 	}
-	if domain_name != "" && domain_id == "" {
-		authOpts.DomainName = domain_name
+
+	if err := openstack.Authenticate(provider, authOpts); err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// newClient creates unauthenticated provider client using transport given in options
+func newClient(opts AuthOpts) (*gophercloud.ProviderClient, error) {
+	provider, err := openstack.NewClient(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// gophercloud keeps only scheme and host as identity base, Keystone exposed under path prefix
+	// (eg. https://host/identity/v3) has to be discovered and addressed under that prefix
+	provider.IdentityBase = identityBase(opts.Endpoint)
+	// rate limited requests are reported with delay requested by server, so they can be retried after it
+	provider.HTTPClient = http.Client{Transport: RateLimited(opts.Transport)}
+	return provider, nil
+}
+
+// identityBase returns identity endpoint without trailing API version, keeping path prefix Keystone is exposed under
+func identityBase(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if last := segments[len(segments)-1]; last == "v2.0" || last == "v3" {
+		segments = segments[:len(segments)-1]
+	}
+	u.Path, u.RawQuery, u.Fragment = strings.Join(segments, "/"), "", ""
+	return gophercloud.NormalizeURL(u.String())
+}
+
+// authenticateSystem obtains system scoped token from Keystone v3, which grants read access to all projects
+// for users holding system role assignment (eg. system reader)
+func authenticateSystem(opts AuthOpts) (*gophercloud.ProviderClient, error) {
+	if opts.SystemScope != "all" {
+		return nil, fmt.Errorf("Unsupported system scope %q, only \"all\" is allowed", opts.SystemScope)
+	}
+
+	return authenticateV3(opts, tokens.Scope{System: true})
+}
+
+// authenticateDomain obtains domain scoped token from Keystone v3 for users holding role on domain (eg. domain admin),
+// ID of domain is returned as well, so projects may be filtered by domain configured by its name
+func authenticateDomain(opts AuthOpts) (*gophercloud.ProviderClient, string, error) {
+	domainID := opts.DomainScopeID
+	scope := tokens.Scope{DomainID: opts.DomainScopeID}
+	if domainID == "" {
+		scope.DomainName = opts.DomainScopeName
 	}
-	if domain_id != "" && domain_name == "" {
-		authOpts.DomainID = domain_id
+
+	provider, err := authenticateV3Token(opts, scope, func(result tokens.CreateResult) error {
+		if domainID != "" {
+			return nil
+		}
+		var err error
+		domainID, err = result.ExtractDomainID()
+		return err
+	})
+	if err != nil {
+		return nil, "", err
 	}
+	if domainID == "" {
+		return nil, "", fmt.Errorf("Token is not scoped to domain %q", opts.DomainScopeName)
+	}
+	return provider, domainID, nil
+}
 
-	provider, err := openstack.AuthenticatedClient(authOpts)
+// authenticateV3 obtains token of given scope from Keystone v3, endpoints are resolved from its catalog
+// and token is renewed the same way when it expires
+func authenticateV3(opts AuthOpts, scope tokens.Scope) (*gophercloud.ProviderClient, error) {
+	return authenticateV3Token(opts, scope, nil)
+}
+
+// authenticateV3Token obtains token the same way as authenticateV3, created token is passed to given function, when
+// set, before it is used by provider
+func authenticateV3Token(opts AuthOpts, scope tokens.Scope, created func(tokens.CreateResult) error) (*gophercloud.ProviderClient, error) {
+	provider, err := newClient(opts)
 	if err != nil {
 		return nil, err
 	}
 
+	authOpts := tokens.AuthOptions{
+		Username:   opts.User,
+		Password:   opts.Password,
+		DomainName: opts.UserDomainName,
+		DomainID:   opts.UserDomainID,
+		Scope:      scope,
+	}
+
+	auth := func() error {
+		provider.TokenID = ""
+		result := tokens.Create(identityV3(provider), authOpts)
+		token, err := result.ExtractTokenID()
+		if err != nil {
+			return err
+		}
+		catalog, err := result.ExtractCatalog()
+		if err != nil {
+			return err
+		}
+		if created != nil {
+			if err := created(result); err != nil {
+				return err
+			}
+		}
+		provider.TokenID = token
+		provider.EndpointLocator = tokens.EndpointLocator(catalog)
+		return nil
+	}
+
+	if err := auth(); err != nil {
+		return nil, err
+	}
+	if !opts.DisableReauth {
+		provider.ReauthFunc = auth
+	}
+
 	return provider, nil
 }
 
+// identityV3 creates Keystone v3 service client, configured endpoint is used if it already points to v3 API
+func identityV3(provider *gophercloud.ProviderClient) *gophercloud.ServiceClient {
+	if strings.HasSuffix(provider.IdentityEndpoint, "/v3/") {
+		return &gophercloud.ServiceClient{ProviderClient: provider, Endpoint: provider.IdentityEndpoint}
+	}
+	return openstack.NewIdentityV3(provider)
+}
+
 // ChooseVersion returns chosen Cinder API version based on defined priority
 func ChooseVersion(recognized []string) (string, error) {
 	if len(recognized) < 1 {
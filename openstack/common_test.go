@@ -17,16 +17,22 @@ limitations under the License.
 package openstack
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/rackspace/gophercloud"
 	th "github.com/rackspace/gophercloud/testhelper"
+
+	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2"
+	openstackintelv3 "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v3"
 )
 
 type CommonSuite struct {
@@ -36,6 +42,11 @@ type CommonSuite struct {
 	V1, V2                   string
 	Tenant1ID, Tenant2ID     string
 	Tenant1Name, Tenant2Name string
+	SystemToken              string
+	DomainToken              string
+	ProjectToken             string
+	UnscopedToken            string
+	UserID                   string
 }
 
 func (s *CommonSuite) SetupSuite() {
@@ -47,6 +58,9 @@ func (s *CommonSuite) SetupSuite() {
 	s.Tenant1ID = "3e3e3e"
 	s.Tenant2ID = "4f4f4f"
 	registerTenants(s)
+	registerProjects(s)
+	registerSystemToken(s)
+	registerRoleAssignments(s)
 }
 
 func (s *CommonSuite) TearDownSuite() {
@@ -57,7 +71,7 @@ func (s *CommonSuite) TestGetTenants() {
 	Convey("Given tenants are requested", s.T(), func() {
 		c := Common{}
 		Convey("When Gettenants is called", func() {
-			tenants, err := c.GetTenants(th.Endpoint(), "me", "secret", "", "")
+			tenants, err := c.GetTenants(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret"}, nil)
 
 			Convey("Then list of available tenats is returned", func() {
 				So(len(tenants), ShouldEqual, 2)
@@ -69,11 +83,377 @@ func (s *CommonSuite) TestGetTenants() {
 	})
 }
 
+func (s *CommonSuite) TestGetTenantsByTags() {
+	Convey("Given tenants carrying tags are requested", s.T(), func() {
+		c := Common{}
+		Convey("When Gettenants is called with tags", func() {
+			tenants, err := c.GetTenants(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret"}, []string{"monitored"})
+
+			Convey("Then only tagged tenants are returned", func() {
+				So(len(tenants), ShouldEqual, 1)
+				So(tenants[s.Tenant2ID], ShouldEqual, s.Tenant2Name)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestGetTenantsDuplicatedNames() {
+	Convey("Given projects sharing name across domains", s.T(), func() {
+		c := Common{}
+		Convey("When Gettenants is called", func() {
+			tenants, err := c.GetTenants(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret"}, []string{"duplicated"})
+
+			Convey("Then shared names are suffixed with domain ID", func() {
+				So(err, ShouldBeNil)
+				So(len(tenants), ShouldEqual, 3)
+				So(tenants["5a5a5a"], ShouldEqual, s.Tenant2Name+"@default")
+				So(tenants["6b6b6b"], ShouldEqual, s.Tenant2Name+"@d2")
+				So(tenants[s.Tenant1ID], ShouldEqual, s.Tenant1Name)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestGetTenantDomains() {
+	Convey("Given domains of tenants are requested", s.T(), func() {
+		c := Common{}
+		Convey("When GetTenantDomains is called with tags", func() {
+			domains, err := c.GetTenantDomains(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret"}, []string{"duplicated"})
+
+			Convey("Then domain of each listed project is returned", func() {
+				So(err, ShouldBeNil)
+				So(domains, ShouldResemble, map[string]string{"5a5a5a": "default", "6b6b6b": "d2", s.Tenant1ID: "d2"})
+			})
+		})
+
+		Convey("When GetTenantDomains is called without tags and scope", func() {
+			domains, err := c.GetTenantDomains(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret"}, nil)
+
+			Convey("Then domains are not known", func() {
+				So(err, ShouldBeNil)
+				So(domains, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestAuthenticateSystemScope() {
+	Convey("Given system scope is configured", s.T(), func() {
+		opts := AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", SystemScope: "all"}
+
+		Convey("When Authenticate is called", func() {
+			provider, err := Authenticate(opts)
+
+			Convey("Then system scoped token is returned", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.SystemToken)
+			})
+
+			Convey("and endpoints are resolved from token catalog", func() {
+				So(err, ShouldBeNil)
+				url, err := provider.EndpointLocator(gophercloud.EndpointOpts{Type: "volumev2", Availability: gophercloud.AvailabilityPublic})
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, s.BlockStorageEndpoint+"/")
+			})
+		})
+
+		Convey("When unsupported system scope is used", func() {
+			opts.SystemScope = "nova"
+			_, err := Authenticate(opts)
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestAuthenticateProjectDomain() {
+	Convey("Given project domain different from user domain", s.T(), func() {
+		opts := AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "demo",
+			UserDomainName: "services", ProjectDomainName: "projects"}
+
+		Convey("When Authenticate is called", func() {
+			provider, err := Authenticate(opts)
+
+			Convey("Then token scoped to project of project domain is returned", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.ProjectToken)
+			})
+		})
+
+		Convey("When project domain is the same as user domain", func() {
+			opts.UserDomainName, opts.ProjectDomainName = "", ""
+			provider, err := Authenticate(opts)
+
+			Convey("Then tenant is authenticated the usual way", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.Token)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestGetRoleProject() {
+	Convey("Given user holding roles on several projects", s.T(), func() {
+		c := Common{}
+		opts := AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret"}
+
+		Convey("When GetRoleProject is called for role held on several projects", func() {
+			project, err := c.GetRoleProject(opts, "reader")
+
+			Convey("Then first of them by name is returned", func() {
+				So(err, ShouldBeNil)
+				So(project, ShouldEqual, s.Tenant1Name)
+			})
+		})
+
+		Convey("When GetRoleProject is called for role held on different project only", func() {
+			project, err := c.GetRoleProject(opts, "member")
+
+			Convey("Then project holding that role is returned", func() {
+				So(err, ShouldBeNil)
+				So(project, ShouldEqual, s.Tenant2Name)
+			})
+		})
+
+		Convey("When GetRoleProject is called for role not held on any project", func() {
+			_, err := c.GetRoleProject(opts, "admin")
+
+			Convey("Then error is reported", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestAuthenticateTransport() {
+	Convey("Given HTTP transport is configured", s.T(), func() {
+		transport := &countingTransport{RoundTripper: NewTransport(TransportOpts{Dial: time.Second})}
+		opts := AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant", Transport: transport}
+
+		Convey("When Authenticate is called", func() {
+			provider, err := Authenticate(opts)
+
+			Convey("Then requests are sent with configured transport", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.Token)
+				So(transport.requests, ShouldBeGreaterThan, 0)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestAuthenticateReauth() {
+	rejected := false
+	th.Mux.HandleFunc("/expiring", func(w http.ResponseWriter, r *http.Request) {
+		if !rejected {
+			rejected = true
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		th.TestHeader(s.T(), r, "X-Auth-Token", s.Token)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	Convey("Given endpoint rejecting first request with expired token", s.T(), func() {
+		rejected = false
+		transport := &countingTransport{RoundTripper: http.DefaultTransport}
+		opts := AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant", Transport: transport}
+
+		Convey("When request is sent by authenticated provider", func() {
+			provider, err := Authenticate(opts)
+			So(err, ShouldBeNil)
+			authRequests := transport.requests
+			_, err = provider.Request("GET", th.Endpoint()+"expiring", gophercloud.RequestOpts{OkCodes: []int{200}})
+
+			Convey("Then provider authenticates again and request succeeds", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.Token)
+				// rejected request, authentication and repeated request
+				So(transport.requests-authRequests, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When reauthentication is disabled", func() {
+			opts.DisableReauth = true
+			provider, err := Authenticate(opts)
+			So(err, ShouldBeNil)
+			_, err = provider.Request("GET", th.Endpoint()+"expiring", gophercloud.RequestOpts{OkCodes: []int{200}})
+
+			Convey("Then expired token fails request", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestAuthenticatePathPrefix() {
+	// Keystone behind reverse proxy is served under /identity prefix only
+	th.Mux.HandleFunc("/identity/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/identity/" {
+			http.StripPrefix("/identity", th.Mux).ServeHTTP(w, r)
+			return
+		}
+		fmt.Fprintf(w, `
+				{
+					"versions": {
+						"values": [
+							{
+								"status": "stable",
+								"id": "v2.0",
+								"links": [
+									{ "href": "%s", "rel": "self" }
+								]
+							}
+						]
+					}
+				}
+				`, th.Endpoint()+"identity/v2.0/")
+	})
+
+	Convey("Given identity endpoint with path prefix", s.T(), func() {
+		transport := &countingTransport{RoundTripper: http.DefaultTransport}
+		opts := AuthOpts{Endpoint: th.Endpoint() + "identity", User: "me", Password: "secret", Tenant: "tenant", Transport: transport}
+
+		Convey("When Authenticate is called", func() {
+			provider, err := Authenticate(opts)
+
+			Convey("Then versions are discovered and token obtained under the prefix", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.Token)
+				So(transport.paths, ShouldResemble, []string{"/identity/", "/identity/v2.0/tokens"})
+			})
+		})
+
+		Convey("When Authenticate is called with system scope", func() {
+			opts.Endpoint = th.Endpoint() + "identity/v3"
+			opts.Tenant, opts.SystemScope = "", "all"
+			provider, err := Authenticate(opts)
+
+			Convey("Then token is obtained from versioned endpoint under the prefix", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.SystemToken)
+				So(transport.paths, ShouldResemble, []string{"/identity/v3/auth/tokens"})
+			})
+		})
+	})
+}
+
+func (s *CommonSuite) TestDomainScope() {
+	Convey("Given domain admin credentials", s.T(), func() {
+		opts := AuthOpts{Endpoint: th.Endpoint() + "v3", User: "me", Password: "secret"}
+
+		Convey("When Authenticate is called with domain scope given by name", func() {
+			opts.DomainScopeName = "projects"
+			provider, err := Authenticate(opts)
+
+			Convey("Then domain scoped token is obtained", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.DomainToken)
+			})
+		})
+
+		Convey("When Authenticate is called with domain and system scope", func() {
+			opts.DomainScopeID, opts.SystemScope = "d2", "all"
+			provider, err := Authenticate(opts)
+
+			Convey("Then system scope takes precedence", func() {
+				So(err, ShouldBeNil)
+				So(provider.TokenID, ShouldEqual, s.SystemToken)
+			})
+		})
+
+		Convey("When GetTenants is called with domain scope given by name", func() {
+			opts.DomainScopeName = "projects"
+			tenants, err := Common{}.GetTenants(opts, nil)
+
+			Convey("Then only projects of that domain are returned", func() {
+				So(err, ShouldBeNil)
+				So(tenants, ShouldResemble, map[string]string{s.Tenant1ID: s.Tenant1Name, s.Tenant2ID: s.Tenant2Name})
+			})
+		})
+
+		Convey("When GetTenants is called with domain scope given by ID", func() {
+			opts.DomainScopeName, opts.DomainScopeID = "ignored", "d2"
+			tenants, err := Common{}.GetTenants(opts, nil)
+
+			Convey("Then ID takes precedence and projects of that domain are returned", func() {
+				So(err, ShouldBeNil)
+				So(tenants, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When GetTenantDomains is called with domain scope", func() {
+			opts.DomainScopeName = "projects"
+			domains, err := Common{}.GetTenantDomains(opts, nil)
+
+			Convey("Then projects of that domain are returned with its ID", func() {
+				So(err, ShouldBeNil)
+				So(domains, ShouldResemble, map[string]string{s.Tenant1ID: "d2", s.Tenant2ID: "d2"})
+			})
+		})
+
+		Convey("When GetTenants is called with domain user has no role on", func() {
+			opts.DomainScopeName = "unknown"
+			_, err := Common{}.GetTenants(opts, nil)
+
+			Convey("Then authentication error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestIdentityBase(t *testing.T) {
+	Convey("Given identity endpoints", t, func() {
+		for endpoint, expected := range map[string]string{
+			"http://keystone:5000":                  "http://keystone:5000/",
+			"http://keystone:5000/v3":               "http://keystone:5000/",
+			"https://host/identity":                 "https://host/identity/",
+			"https://host/identity/v2.0/":           "https://host/identity/",
+			"https://host/cloud/identity/v3?x=1":    "https://host/cloud/identity/",
+			"https://host/identity/v3/subtree/path": "https://host/identity/v3/subtree/path/",
+		} {
+			So(identityBase(endpoint), ShouldEqual, expected)
+		}
+	})
+}
+
+func (s *CommonSuite) TestAuthenticateCinderURL() {
+	Convey("Given Cinder endpoint is configured", s.T(), func() {
+		opts := AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant", CinderURL: "http://cinder.internal:8776/v2/tenant_id"}
+
+		Convey("When Authenticate is called", func() {
+			provider, err := Authenticate(opts)
+			So(err, ShouldBeNil)
+
+			Convey("Then block storage clients use configured endpoint instead of catalog", func() {
+				client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+				So(err, ShouldBeNil)
+				So(client.Endpoint, ShouldEqual, "http://cinder.internal:8776/v2/tenant_id/")
+
+				client, err = openstackintelv3.NewBlockStorageV3(provider, gophercloud.EndpointOpts{})
+				So(err, ShouldBeNil)
+				So(client.Endpoint, ShouldEqual, "http://cinder.internal:8776/v3/tenant_id/")
+			})
+
+			Convey("and configured endpoint is kept after reauthentication", func() {
+				So(provider.ReauthFunc(), ShouldBeNil)
+				client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+				So(err, ShouldBeNil)
+				So(client.Endpoint, ShouldEqual, "http://cinder.internal:8776/v2/tenant_id/")
+			})
+		})
+	})
+}
+
 func (s *CommonSuite) TestGetAPI() {
 	Convey("Given api versions are requested", s.T(), func() {
 		c := Common{}
 		Convey("When GetAPIVersions is called", func() {
-			provider, err := Authenticate(th.Endpoint(), "me", "secret", "tenant", "", "")
+			provider, err := Authenticate(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
 			th.AssertNoErr(s.T(), err)
 			th.CheckEquals(s.T(), s.Token, provider.TokenID)
 
@@ -128,9 +508,93 @@ func (s *CommonSuite) TestGetAPI() {
 				So(err, ShouldBeNil)
 			})
 		})
+
+		Convey("When GetAPIVersions is called and version discovery fails", func() {
+			provider, err := Authenticate(AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+			transport := &http.Transport{
+				Proxy: func(req *http.Request) (*url.URL, error) {
+					return url.Parse(server.URL)
+				},
+			}
+
+			provider.HTTPClient = http.Client{Transport: transport}
+			apis, err := c.GetApiVersions(provider)
+
+			Convey("Then error of discovery request is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "Discovering Cinder API versions failed")
+				So(apis, ShouldBeEmpty)
+			})
+		})
 	})
 }
 
+func TestParseRetryAfter(t *testing.T) {
+	Convey("Given Retry-After header values", t, func() {
+		now := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		Convey("Then delay in seconds and HTTP date are understood", func() {
+			delay, ok := parseRetryAfter("120", now)
+			So(ok, ShouldBeTrue)
+			So(delay, ShouldEqual, 2*time.Minute)
+
+			delay, ok = parseRetryAfter("Sat, 01 Oct 2016 12:00:30 GMT", now)
+			So(ok, ShouldBeTrue)
+			So(delay, ShouldEqual, 30*time.Second)
+
+			delay, ok = parseRetryAfter("Sat, 01 Oct 2016 11:00:00 GMT", now)
+			So(ok, ShouldBeTrue)
+			So(delay, ShouldEqual, 0)
+		})
+
+		Convey("Then missing or malformed value is rejected", func() {
+			for _, value := range []string{"", "-1", "soon"} {
+				_, ok := parseRetryAfter(value, now)
+				So(ok, ShouldBeFalse)
+			}
+		})
+	})
+}
+
+func TestVersionHint(t *testing.T) {
+	Convey("Given API versions reported by version discovery", t, func() {
+		versions := []APIVersion{{ID: "v1.0"}, {ID: "v2.0"}}
+
+		Convey("When no microversions are reported", func() {
+			Convey("Then chosen API version is returned", func() {
+				So(VersionHint(versions, "v2.0"), ShouldEqual, "v2.0")
+			})
+		})
+
+		Convey("When microversions are reported", func() {
+			versions = append(versions, APIVersion{ID: "v3.0", Microversion: "3.9"}, APIVersion{ID: "v3.1", Microversion: "3.59"})
+
+			Convey("Then highest microversion is returned", func() {
+				So(VersionHint(versions, "v2.0"), ShouldEqual, "3.59")
+			})
+		})
+	})
+}
+
+// countingTransport counts requests sent through wrapped transport and records their paths
+type countingTransport struct {
+	http.RoundTripper
+	requests int
+	paths    []string
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests++
+	t.paths = append(t.paths, r.URL.Path)
+	return t.RoundTripper.RoundTrip(r)
+}
+
 func TestCommonSuite(t *testing.T) {
 	commonTestSuite := new(CommonSuite)
 	suite.Run(t, commonTestSuite)
@@ -261,6 +725,237 @@ func registerTenants(s *CommonSuite) {
 	})
 }
 
+func registerProjects(s *CommonSuite) {
+	th.Mux.HandleFunc("/v3/projects", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		// projects of domain are listed with domain scoped token, project of other domain is returned too
+		// as if filter was ignored
+		if r.URL.Query().Get("domain_id") != "" {
+			th.TestHeader(s.T(), r, "X-Auth-Token", s.DomainToken)
+			th.TestFormValues(s.T(), r, map[string]string{"domain_id": "d2"})
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `
+				{
+					"projects": [
+						{"domain_id": "d2", "enabled": true, "id": "%s", "name": "%s"},
+						{"domain_id": "d2", "enabled": true, "id": "%s", "name": "%s"},
+						{"domain_id": "default", "enabled": true, "id": "7c7c7c", "name": "other"}
+					],
+					"links": {"next": null, "previous": null, "self": "%s"}
+				}
+			`, s.Tenant1ID, s.Tenant1Name, s.Tenant2ID, s.Tenant2Name, th.Endpoint()+"v3/projects")
+			return
+		}
+		th.TestHeader(s.T(), r, "X-Auth-Token", s.Token)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		// same named projects of different domains
+		if r.URL.Query().Get("tags") == "duplicated" {
+			fmt.Fprintf(w, `
+				{
+					"projects": [
+						{
+							"domain_id": "default",
+							"enabled": true,
+							"id": "5a5a5a",
+							"name": "%s",
+							"tags": ["duplicated"]
+						},
+						{
+							"domain_id": "d2",
+							"enabled": true,
+							"id": "6b6b6b",
+							"name": "%s",
+							"tags": ["duplicated"]
+						},
+						{
+							"domain_id": "d2",
+							"enabled": true,
+							"id": "%s",
+							"name": "%s",
+							"tags": ["duplicated"]
+						}
+					],
+					"links": {
+						"next": null,
+						"previous": null,
+						"self": "%s"
+					}
+				}
+			`, s.Tenant2Name, s.Tenant2Name, s.Tenant1ID, s.Tenant1Name, th.Endpoint()+"v3/projects")
+			return
+		}
+		th.TestFormValues(s.T(), r, map[string]string{"tags": "monitored"})
+
+		// tags filter is ignored on purpose to verify client side filtering
+		fmt.Fprintf(w, `
+			{
+				"projects": [
+					{
+						"domain_id": "default",
+						"enabled": true,
+						"id": "%s",
+						"name": "%s",
+						"tags": []
+					},
+					{
+						"domain_id": "default",
+						"enabled": true,
+						"id": "%s",
+						"name": "%s",
+						"tags": ["monitored"]
+					}
+				],
+				"links": {
+					"next": null,
+					"previous": null,
+					"self": "%s"
+				}
+			}
+		`, s.Tenant1ID, s.Tenant1Name, s.Tenant2ID, s.Tenant2Name, th.Endpoint()+"v3/projects")
+	})
+}
+
+func registerSystemToken(s *CommonSuite) {
+	s.SystemToken = "3fa2c7ff3d5e4ae2b7cd17a4c1d9b370"
+	s.ProjectToken = "7c1e5b9a2d4f4e8b9a6c3d2e1f0a9b8c"
+	s.DomainToken = "9d8c7b6a5f4e4d3c2b1a0f9e8d7c6b5a"
+	th.Mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "POST")
+
+		type domain struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		var body struct {
+			Auth struct {
+				Identity struct {
+					Password struct {
+						User struct {
+							Domain domain `json:"domain"`
+						} `json:"user"`
+					} `json:"password"`
+				} `json:"identity"`
+				Scope struct {
+					System struct {
+						All bool `json:"all"`
+					} `json:"system"`
+					Domain  domain `json:"domain"`
+					Project struct {
+						Name   string `json:"name"`
+						Domain domain `json:"domain"`
+					} `json:"project"`
+				} `json:"scope"`
+			} `json:"auth"`
+		}
+		th.AssertNoErr(s.T(), json.NewDecoder(r.Body).Decode(&body))
+		// project scoped token is issued only for user of services domain scoped to project of projects domain
+		if project := body.Auth.Scope.Project; project.Name != "" {
+			if project.Domain.Name != "projects" || body.Auth.Identity.Password.User.Domain.Name != "services" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			w.Header().Add("X-Subject-Token", s.ProjectToken)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": {"methods": ["password"], "project": {"name": "%s"}, "catalog": []}}`, project.Name)
+			return
+		}
+		// domain scoped token is issued only for domain d2 named "projects"
+		if scope := body.Auth.Scope.Domain; scope.ID != "" || scope.Name != "" {
+			if scope.ID != "d2" && scope.Name != "projects" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			w.Header().Add("X-Subject-Token", s.DomainToken)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": {"methods": ["password"], "domain": {"id": "d2", "name": "projects"}, "catalog": []}}`)
+			return
+		}
+		if !body.Auth.Scope.System.All {
+			w.Header().Add("Content-Type", "application/json")
+			w.Header().Add("X-Subject-Token", s.UnscopedToken)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": {"methods": ["password"], "user": {"id": "%s", "name": "me"}}}`, s.UserID)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Add("X-Subject-Token", s.SystemToken)
+		w.WriteHeader(http.StatusCreated)
+
+		fmt.Fprintf(w, `
+			{
+				"token": {
+					"methods": ["password"],
+					"system": {"all": true},
+					"catalog": [
+						{
+							"endpoints": [
+								{
+									"id": "3ffe125aa59547029ed774c10b932349",
+									"interface": "public",
+									"region": "RegionOne",
+									"url": "%s"
+								}
+							],
+							"name": "cinderv2",
+							"type": "volumev2"
+						}
+					]
+				}
+			}
+		`, s.BlockStorageEndpoint)
+	})
+}
+
+func registerRoleAssignments(s *CommonSuite) {
+	s.UnscopedToken = "5b2f0c1a9d8e4f6a8c3b7e1d2a4f6c8e"
+	s.UserID = "u1u1u1"
+	th.Mux.HandleFunc("/v3/role_assignments", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		th.TestHeader(s.T(), r, "X-Auth-Token", s.UnscopedToken)
+		th.TestFormValues(s.T(), r, map[string]string{"user.id": s.UserID, "effective": "true", "include_names": "true"})
+
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `
+			{
+				"role_assignments": [
+					{
+						"role": {"id": "r1", "name": "member"},
+						"scope": {"project": {"id": "%s", "name": "%s"}},
+						"user": {"id": "%s"}
+					},
+					{
+						"role": {"id": "r2", "name": "reader"},
+						"scope": {"project": {"id": "%s", "name": "%s"}},
+						"user": {"id": "%s"}
+					},
+					{
+						"role": {"id": "r2", "name": "reader"},
+						"scope": {"project": {"id": "%s", "name": "%s"}},
+						"user": {"id": "%s"}
+					},
+					{
+						"role": {"id": "r3", "name": "admin"},
+						"scope": {"domain": {"id": "default", "name": "Default"}},
+						"user": {"id": "%s"}
+					}
+				],
+				"links": {
+					"next": null,
+					"previous": null,
+					"self": "%s"
+				}
+			}
+		`, s.Tenant2ID, s.Tenant2Name, s.UserID, s.Tenant2ID, s.Tenant2Name, s.UserID, s.Tenant1ID, s.Tenant1Name, s.UserID,
+			s.UserID, th.Endpoint()+"v3/role_assignments")
+	})
+}
+
 func registerAPI(s *CommonSuite) {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
@@ -17,6 +17,9 @@ limitations under the License.
 package limits
 
 import (
+	"encoding/json"
+	"net/http"
+
 	"github.com/rackspace/gophercloud"
 )
 
@@ -27,3 +30,42 @@ func Get(client *gophercloud.ServiceClient, limits string) GetResult {
 	res.Err = err
 	return res
 }
+
+// GetConditional prepares http GET call on Cinder endpoint sent with If-None-Match header when ETag of previous
+// response is given, response unchanged since then is reported as not modified with empty body
+func GetConditional(client *gophercloud.ServiceClient, limits string, etag string) GetResult {
+	var res GetResult
+	resp, err := client.Request("GET", client.ResourceBaseURL()+limits, gophercloud.RequestOpts{
+		OkCodes:     []int{http.StatusOK, http.StatusNotModified},
+		MoreHeaders: map[string]string{"If-None-Match": etag},
+	})
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+	res.Header = resp.Header
+	if resp.StatusCode == http.StatusNotModified {
+		res.NotModified = true
+		return res
+	}
+	res.Err = json.NewDecoder(resp.Body).Decode(&res.Body)
+	return res
+}
+
+// GetDefaultQuotas prepares http GET call for default quota class set
+func GetDefaultQuotas(client *gophercloud.ServiceClient) DefaultQuotasResult {
+	var res DefaultQuotasResult
+	_, err := client.Get(client.ServiceURL("os-quota-class-sets", "default"), &res.Body, nil)
+	res.Err = err
+	return res
+}
+
+// GetQuotaUsage prepares http GET call for quota set of given tenant including its usage
+func GetQuotaUsage(client *gophercloud.ServiceClient, tenantID string) QuotaUsageResult {
+	var res QuotaUsageResult
+	url := client.ServiceURL("os-quota-sets", tenantID) + "?usage=true"
+	_, err := client.Get(url, &res.Body, nil)
+	res.Err = err
+	return res
+}
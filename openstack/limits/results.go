@@ -22,8 +22,18 @@ import (
 )
 
 // GetResult contains the response body and error from a Get request
+// NotModified - response to conditional request was not modified since given ETag, body is empty then
 type GetResult struct {
 	commonResult
+	NotModified bool
+}
+
+// ETag returns ETag of response, empty when not reported
+func (r GetResult) ETag() string {
+	if r.Header == nil {
+		return ""
+	}
+	return r.Header.Get("ETag")
 }
 
 // Extract will get the limit object out of the commonResult object
@@ -45,17 +55,83 @@ func (r commonResult) Extract() (limits, error) {
 	return res.Absolute.Limits, err
 }
 
+// DefaultQuotasResult contains the response body and error from a GetDefaultQuotas request
+type DefaultQuotasResult struct {
+	commonResult
+}
+
+// DefaultQuotas represents default quota class set, quotas not reported are left nil
+type DefaultQuotas struct {
+	Volumes   *int `mapstructure:"volumes"`
+	Gigabytes *int `mapstructure:"gigabytes"`
+}
+
+// Extract will get default quotas out of the DefaultQuotasResult object
+func (r DefaultQuotasResult) Extract() (DefaultQuotas, error) {
+	var res struct {
+		QuotaClassSet DefaultQuotas `mapstructure:"quota_class_set"`
+	}
+	if r.Err != nil {
+		return res.QuotaClassSet, r.Err
+	}
+
+	err := mapstructure.Decode(r.Body, &res)
+	return res.QuotaClassSet, err
+}
+
+// QuotaUsageResult contains the response body and error from a GetQuotaUsage request
+type QuotaUsageResult struct {
+	commonResult
+}
+
+// QuotaUsage represents limit and usage of single quota resource, reserved usage is nil when not reported
+type QuotaUsage struct {
+	Limit    int  `mapstructure:"limit"`
+	InUse    int  `mapstructure:"in_use"`
+	Reserved *int `mapstructure:"reserved"`
+}
+
+// Extract will get quota usage by resource name (eg. gigabytes_ssd) out of the QuotaUsageResult object,
+// fields which are not quota resources (eg. id) are skipped
+func (r QuotaUsageResult) Extract() (map[string]QuotaUsage, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		QuotaSet map[string]interface{} `mapstructure:"quota_set"`
+	}
+	if err := mapstructure.Decode(r.Body, &res); err != nil {
+		return nil, err
+	}
+
+	usage := map[string]QuotaUsage{}
+	for resource, value := range res.QuotaSet {
+		if _, ok := value.(map[string]interface{}); !ok {
+			continue
+		}
+		var u QuotaUsage
+		if err := mapstructure.Decode(value, &u); err != nil {
+			return nil, err
+		}
+		usage[resource] = u
+	}
+
+	return usage, nil
+}
+
+// backup quotas are optional and left nil when not reported
 type limits struct {
-	TotalSnapshotsUsed       int `mapstructure:"totalSnapshotsUsed"`
-	MaxTotalBackups          int `mapstructure:"maxTotalBackups"`
-	MaxTotalVolumeGigabytes  int `mapstructure:"maxTotalVolumeGigabytes"`
-	MaxTotalSnapshots        int `mapstructure:"maxTotalSnapshots"`
-	MaxTotalBackupGigabytes  int `mapstructure:"maxTotalBackupGigabytes"`
-	TotalBackupGigabytesUsed int `mapstructure:"totalBackupGigabytesUsed"`
-	MaxTotalVolumes          int `mapstructure:"maxTotalVolumes"`
-	TotalVolumesUsed         int `mapstructure:"totalVolumesUsed"`
-	TotalBackupsUsed         int `mapstructure:"totalBackupsUsed"`
-	TotalGigabytesUsed       int `mapstructure:"totalGigabytesUsed"`
+	TotalSnapshotsUsed       int  `mapstructure:"totalSnapshotsUsed"`
+	MaxTotalBackups          *int `mapstructure:"maxTotalBackups"`
+	MaxTotalVolumeGigabytes  int  `mapstructure:"maxTotalVolumeGigabytes"`
+	MaxTotalSnapshots        int  `mapstructure:"maxTotalSnapshots"`
+	MaxTotalBackupGigabytes  *int `mapstructure:"maxTotalBackupGigabytes"`
+	TotalBackupGigabytesUsed *int `mapstructure:"totalBackupGigabytesUsed"`
+	MaxTotalVolumes          int  `mapstructure:"maxTotalVolumes"`
+	TotalVolumesUsed         int  `mapstructure:"totalVolumesUsed"`
+	TotalBackupsUsed         *int `mapstructure:"totalBackupsUsed"`
+	TotalGigabytesUsed       int  `mapstructure:"totalGigabytesUsed"`
 }
 
 type absolute struct {
@@ -0,0 +1,62 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Cinder API responses and their processing for back-end storage pools
+
+package pools
+
+import (
+	"strconv"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud"
+)
+
+// Pool contains information associated with Cinder back-end storage pool
+type Pool struct {
+	Name         string                 `mapstructure:"name"`
+	Capabilities map[string]interface{} `mapstructure:"capabilities"`
+}
+
+// Capacity returns capability of pool given in GB, capacities reported as "infinite" or "unknown",
+// or not reported at all, are not known
+func (p Pool) Capacity(capability string) (float64, bool) {
+	switch v := p.Capabilities[capability].(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ListResult contains the response body and error from a List request
+type ListResult struct {
+	gophercloud.Result
+}
+
+// Extract will get pools out of the ListResult object
+func (r ListResult) Extract() ([]Pool, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		Pools []Pool `mapstructure:"pools"`
+	}
+
+	err := mapstructure.Decode(r.Body, &res)
+	return res.Pools, err
+}
@@ -0,0 +1,55 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// requests contains Keystone v3 API requests for projects
+
+package projects
+
+import (
+	"github.com/rackspace/gophercloud"
+	"github.com/rackspace/gophercloud/pagination"
+)
+
+// ListOpts holds options for listing projects. It is passed to the projects.List function.
+type ListOpts struct {
+	// List only projects having all given tags (comma-separated)
+	Tags string `q:"tags"`
+	// List only projects of given domain
+	DomainID string `q:"domain_id"`
+}
+
+// ToProjectListQuery formats a ListOpts into a query string.
+func (opts ListOpts) ToProjectListQuery() (string, error) {
+	q, err := gophercloud.BuildQueryString(opts)
+	if err != nil {
+		return "", err
+	}
+	return q.String(), nil
+}
+
+// List returns projects optionally limited by the conditions provided in ListOpts.
+func List(client *gophercloud.ServiceClient, opts ListOpts) pagination.Pager {
+	url := listURL(client)
+	query, err := opts.ToProjectListQuery()
+	if err != nil {
+		return pagination.Pager{Err: err}
+	}
+	url += query
+
+	createPage := func(r pagination.PageResult) pagination.Page {
+		return ProjectPage{pagination.LinkedPageBase{PageResult: r}}
+	}
+
+	return pagination.NewPager(client, url, createPage)
+}
@@ -0,0 +1,74 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Cinder API responses and their processing for QoS specs
+
+package qosspecs
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud"
+)
+
+// QoSSpec contains information associated with Cinder QoS spec
+type QoSSpec struct {
+	ID       string `mapstructure:"id"`
+	Name     string `mapstructure:"name"`
+	Consumer string `mapstructure:"consumer"`
+}
+
+// Association contains information about entity, usually volume type, associated with QoS spec
+type Association struct {
+	ID              string `mapstructure:"id"`
+	Name            string `mapstructure:"name"`
+	AssociationType string `mapstructure:"association_type"`
+}
+
+// ListResult contains the response body and error from a List request
+type ListResult struct {
+	gophercloud.Result
+}
+
+// Extract will get QoS specs out of the ListResult object
+func (r ListResult) Extract() ([]QoSSpec, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		QoSSpecs []QoSSpec `mapstructure:"qos_specs"`
+	}
+
+	err := mapstructure.Decode(r.Body, &res)
+	return res.QoSSpecs, err
+}
+
+// AssociationsResult contains the response body and error from a GetAssociations request
+type AssociationsResult struct {
+	gophercloud.Result
+}
+
+// Extract will get associations out of the AssociationsResult object
+func (r AssociationsResult) Extract() ([]Association, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		Associations []Association `mapstructure:"qos_associations"`
+	}
+
+	err := mapstructure.Decode(r.Body, &res)
+	return res.Associations, err
+}
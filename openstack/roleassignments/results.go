@@ -0,0 +1,69 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Keystone v3 API responses and their processing for role assignments
+
+package roleassignments
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud/pagination"
+)
+
+// RoleAssignment contains information associated with Keystone v3 role assignment
+type RoleAssignment struct {
+	Role  Role  `mapstructure:"role"`
+	Scope Scope `mapstructure:"scope"`
+}
+
+// Role identifies assigned role, name is filled only when names are included
+type Role struct {
+	ID   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+}
+
+// Scope holds target of role assignment, project is empty for domain and system assignments
+type Scope struct {
+	Project Project `mapstructure:"project"`
+}
+
+// Project identifies project which role is assigned on, name is filled only when names are included
+type Project struct {
+	ID   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+}
+
+// RoleAssignmentPage is a pagination.Page that is returned from a call to the List function.
+type RoleAssignmentPage struct {
+	pagination.LinkedPageBase
+}
+
+// IsEmpty returns true if a RoleAssignmentPage contains no role assignments.
+func (r RoleAssignmentPage) IsEmpty() (bool, error) {
+	assignments, err := ExtractRoleAssignments(r)
+	if err != nil {
+		return true, err
+	}
+	return len(assignments) == 0, nil
+}
+
+// ExtractRoleAssignments extracts and returns role assignments. It is used while iterating over a roleassignments.List call.
+func ExtractRoleAssignments(page pagination.Page) ([]RoleAssignment, error) {
+	var response struct {
+		RoleAssignments []RoleAssignment `mapstructure:"role_assignments"`
+	}
+
+	err := mapstructure.Decode(page.(RoleAssignmentPage).Body, &response)
+	return response.RoleAssignments, err
+}
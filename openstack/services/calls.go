@@ -0,0 +1,120 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// calls contains counting of HTTP requests made to Cinder per metric family
+
+package services
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/rackspace/gophercloud"
+)
+
+// CallCounter counts HTTP requests (including pagination pages) per metric family together with failed ones,
+// it is safe for concurrent use
+type CallCounter struct {
+	mutex  sync.Mutex
+	counts map[string]uint
+	failed uint
+}
+
+// NewCallCounter creates counter without any requests counted
+func NewCallCounter() *CallCounter {
+	return &CallCounter{counts: map[string]uint{}}
+}
+
+// Reset drops all counted requests
+func (c *CallCounter) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts = map[string]uint{}
+	c.failed = 0
+}
+
+// Get returns number of requests counted for given family
+func (c *CallCounter) Get(family string) uint {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[family]
+}
+
+// ErrorRate returns fraction of counted requests of all families which failed, 0 when no request was counted
+func (c *CallCounter) ErrorRate() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	total := uint(0)
+	for _, count := range c.counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(c.failed) / float64(total)
+}
+
+func (c *CallCounter) add(family string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[family]++
+}
+
+func (c *CallCounter) fail() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.failed++
+}
+
+// countingTransport counts requests passed to underlying transport
+type countingTransport struct {
+	base    http.RoundTripper
+	family  string
+	counter *CallCounter
+}
+
+// RoundTrip counts request and passes it to underlying transport, request is counted as failed when transport
+// returns error or Cinder responds with error status
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.counter.add(t.family)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		t.counter.fail()
+	}
+	return resp, err
+}
+
+// counted returns copy of provider which requests are counted for given family, provider is returned unchanged
+// when counter is not set
+func counted(provider *gophercloud.ProviderClient, counter *CallCounter, family string) *gophercloud.ProviderClient {
+	if counter == nil {
+		return provider
+	}
+
+	base := provider.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	copied := *provider
+	copied.HTTPClient.Transport = countingTransport{base: base, family: family, counter: counter}
+	// token renewed by reauthentication is stored in original provider, copy has to pick it up before retry
+	if provider.ReauthFunc != nil {
+		copied.ReauthFunc = func() error {
+			err := provider.ReauthFunc()
+			copied.TokenID = provider.TokenID
+			return err
+		}
+	}
+	return &copied
+}
@@ -17,24 +17,53 @@ limitations under the License.
 package services
 
 import (
+	"fmt"
+
 	"github.com/rackspace/gophercloud"
 
 	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack"
 	cinderv1 "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v1/cinder"
 	cinderv2 "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2/cinder"
+	volumegroupsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/volumegroups"
 	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
 )
 
 // Cinderer allows usage of different Cinder API versions for metric collection
 type Cinderer interface {
-	GetLimits(provider *gophercloud.ProviderClient) (types.Limits, error)
-	GetVolumes(provider *gophercloud.ProviderClient) (map[string]types.Volumes, error)
-	GetSnapshots(provider *gophercloud.ProviderClient) (map[string]types.Snapshots, error)
+	GetLimits(provider *gophercloud.ProviderClient, opts types.LimitsOptions) (types.Limits, error)
+	GetVolumes(provider *gophercloud.ProviderClient, opts types.ListOptions) (map[string]types.Volumes, error)
+	GetSnapshots(provider *gophercloud.ProviderClient, opts types.ListOptions) (map[string]types.Snapshots, error)
+	GetDefaultQuotas(provider *gophercloud.ProviderClient) (types.DefaultQuota, error)
+	GetVolumeTypes(provider *gophercloud.ProviderClient) (types.VolumeTypes, error)
+	GetVolumeGroups(provider *gophercloud.ProviderClient, opts types.VolumeGroupsOptions) (types.VolumeGroups, error)
+	GetQoSSpecs(provider *gophercloud.ProviderClient, opts types.QoSSpecsOptions) (types.QoSSpecs, error)
+	GetPools(provider *gophercloud.ProviderClient, opts types.PoolsOptions) (types.Pools, error)
+	GetExtraSpecs(provider *gophercloud.ProviderClient, opts types.ExtraSpecsOptions) (map[string]map[string]string, error)
+	GetServices(provider *gophercloud.ProviderClient) ([]types.ServiceHeartbeat, error)
 }
 
 // Services serves as a API calls dispatcher
 type Service struct {
-	cinder Cinderer
+	cinder       Cinderer
+	version      string
+	volumeGroups bool
+	calls        *CallCounter
+}
+
+// Version returns Cinder version hint derived from version discovery (highest microversion or chosen API version),
+// empty when service was not dispatched
+func (s Service) Version() string {
+	return s.version
+}
+
+// SupportsVolumeGroups returns true when version discovery reported microversion supporting generic volume groups
+func (s Service) SupportsVolumeGroups() bool {
+	return s.volumeGroups
+}
+
+// CountCalls sets counter of HTTP requests made by dispatched calls, requests are not counted when it is not set
+func (c *Service) CountCalls(counter *CallCounter) {
+	c.calls = counter
 }
 
 // Set allows to set proper API version implementation
@@ -43,42 +72,88 @@ func (c *Service) Set(new Cinderer) {
 }
 
 // GetLimits dispatches call to proper API version calls to collect limits metrics
-func (s Service) GetLimits(provider *gophercloud.ProviderClient) (types.Limits, error) {
-	return s.cinder.GetLimits(provider)
+func (s Service) GetLimits(provider *gophercloud.ProviderClient, opts types.LimitsOptions) (types.Limits, error) {
+	return s.cinder.GetLimits(counted(provider, s.calls, "limits"), opts)
 }
 
 // GetVolumes dispatches call to proper API version calls to collect volumes metrics
-func (s Service) GetVolumes(provider *gophercloud.ProviderClient) (map[string]types.Volumes, error) {
-	return s.cinder.GetVolumes(provider)
+func (s Service) GetVolumes(provider *gophercloud.ProviderClient, opts types.ListOptions) (map[string]types.Volumes, error) {
+	return s.cinder.GetVolumes(counted(provider, s.calls, "volumes"), opts)
+}
+
+// GetDefaultQuotas dispatches call to proper API version calls to collect default quotas
+func (s Service) GetDefaultQuotas(provider *gophercloud.ProviderClient) (types.DefaultQuota, error) {
+	return s.cinder.GetDefaultQuotas(counted(provider, s.calls, "default_quota"))
+}
+
+// GetVolumeTypes dispatches call to proper API version calls to collect volume types metrics
+func (s Service) GetVolumeTypes(provider *gophercloud.ProviderClient) (types.VolumeTypes, error) {
+	return s.cinder.GetVolumeTypes(counted(provider, s.calls, "volume_types"))
+}
+
+// GetVolumeGroups dispatches call to proper API version calls to collect generic volume groups metrics,
+// nothing is collected when Cinder does not support generic volume groups
+func (s Service) GetVolumeGroups(provider *gophercloud.ProviderClient, opts types.VolumeGroupsOptions) (types.VolumeGroups, error) {
+	if !s.volumeGroups {
+		return types.VolumeGroups{}, nil
+	}
+	return s.cinder.GetVolumeGroups(counted(provider, s.calls, "groups"), opts)
+}
+
+// GetQoSSpecs dispatches call to proper API version calls to collect QoS specs metrics
+func (s Service) GetQoSSpecs(provider *gophercloud.ProviderClient, opts types.QoSSpecsOptions) (types.QoSSpecs, error) {
+	return s.cinder.GetQoSSpecs(counted(provider, s.calls, "qos_specs"), opts)
+}
+
+// GetPools dispatches call to proper API version calls to collect back-end storage pools metrics
+func (s Service) GetPools(provider *gophercloud.ProviderClient, opts types.PoolsOptions) (types.Pools, error) {
+	return s.cinder.GetPools(counted(provider, s.calls, "pools"), opts)
+}
+
+// GetExtraSpecs dispatches call to proper API version calls to collect extra specs of volume types
+func (s Service) GetExtraSpecs(provider *gophercloud.ProviderClient, opts types.ExtraSpecsOptions) (map[string]map[string]string, error) {
+	return s.cinder.GetExtraSpecs(counted(provider, s.calls, "volume_types"), opts)
+}
+
+// GetServices dispatches call to proper API version calls to collect heartbeats of Cinder services
+func (s Service) GetServices(provider *gophercloud.ProviderClient) ([]types.ServiceHeartbeat, error) {
+	return s.cinder.GetServices(counted(provider, s.calls, "services"))
 }
 
 // GetSnapshots dispatches call to proper API version calls to collect snapshot metrics
-func (s Service) GetSnapshots(provider *gophercloud.ProviderClient) (map[string]types.Snapshots, error) {
-	return s.cinder.GetSnapshots(provider)
+func (s Service) GetSnapshots(provider *gophercloud.ProviderClient, opts types.ListOptions) (map[string]types.Snapshots, error) {
+	return s.cinder.GetSnapshots(counted(provider, s.calls, "snapshots"), opts)
 }
 
 // Dispatch redirects to selected Cinder API version based on priority
-func Dispatch(provider *gophercloud.ProviderClient) Service {
+func Dispatch(provider *gophercloud.ProviderClient) (Service, error) {
 	cmn := openstackintel.Common{}
-	versions, err := cmn.GetApiVersions(provider)
+	versions, err := cmn.GetApiVersionsInfo(provider)
 	if err != nil {
-		panic(err)
+		return Service{}, err
 	}
 
-	chosen, err := openstackintel.ChooseVersion(versions)
+	ids := make([]string, 0, len(versions))
+	for _, version := range versions {
+		ids = append(ids, version.ID)
+	}
+	chosen, err := openstackintel.ChooseVersion(ids)
 	if err != nil {
-		panic(err)
+		return Service{}, err
 	}
 
-	service := Service{}
+	service := Service{
+		version:      openstackintel.VersionHint(versions, chosen),
+		volumeGroups: openstackintel.SupportsMicroversion(versions, volumegroupsintel.Microversion),
+	}
 	switch chosen {
 	case "v1.0":
 		service.Set(cinderv1.ServiceV1{})
 	case "v2.0":
 		service.Set(cinderv2.ServiceV2{})
 	default:
-		panic("Could not select dispatcher")
+		return Service{}, fmt.Errorf("Could not select dispatcher for Cinder API version %s", chosen)
 	}
 
-	return service
+	return service, nil
 }
@@ -0,0 +1,103 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// requests contains Keystone v3 API requests for tokens
+
+package tokens
+
+import (
+	"net/http"
+
+	"github.com/rackspace/gophercloud"
+)
+
+// AuthOptions holds password credentials and scope of requested token
+type AuthOptions struct {
+	Username   string
+	Password   string
+	DomainName string
+	DomainID   string
+	Scope      Scope
+}
+
+// Scope defines authorization scope of requested token
+// System - system scoped token for all projects
+// DomainID, DomainName - domain scoped token for projects of given domain, domain ID takes precedence over name
+// ProjectID - token scoped to project of given ID, takes precedence over ProjectName
+// ProjectName - token scoped to project of given name in domain given by ProjectDomainID or ProjectDomainName
+// (default domain when none is set), domain ID takes precedence over domain name
+type Scope struct {
+	System            bool
+	DomainID          string
+	DomainName        string
+	ProjectID         string
+	ProjectName       string
+	ProjectDomainID   string
+	ProjectDomainName string
+}
+
+// ToTokenCreateMap formats AuthOptions into request body
+func (opts AuthOptions) ToTokenCreateMap() map[string]interface{} {
+	user := map[string]interface{}{
+		"name":     opts.Username,
+		"password": opts.Password,
+	}
+	if opts.DomainID != "" {
+		user["domain"] = map[string]string{"id": opts.DomainID}
+	} else if opts.DomainName != "" {
+		user["domain"] = map[string]string{"name": opts.DomainName}
+	} else {
+		user["domain"] = map[string]string{"id": "default"}
+	}
+
+	auth := map[string]interface{}{
+		"identity": map[string]interface{}{
+			"methods":  []string{"password"},
+			"password": map[string]interface{}{"user": user},
+		},
+	}
+	switch {
+	case opts.Scope.System:
+		auth["scope"] = map[string]interface{}{"system": map[string]bool{"all": true}}
+	case opts.Scope.DomainID != "":
+		auth["scope"] = map[string]interface{}{"domain": map[string]string{"id": opts.Scope.DomainID}}
+	case opts.Scope.DomainName != "":
+		auth["scope"] = map[string]interface{}{"domain": map[string]string{"name": opts.Scope.DomainName}}
+	case opts.Scope.ProjectID != "":
+		auth["scope"] = map[string]interface{}{"project": map[string]string{"id": opts.Scope.ProjectID}}
+	case opts.Scope.ProjectName != "":
+		project := map[string]interface{}{"name": opts.Scope.ProjectName}
+		if opts.Scope.ProjectDomainID != "" {
+			project["domain"] = map[string]string{"id": opts.Scope.ProjectDomainID}
+		} else if opts.Scope.ProjectDomainName != "" {
+			project["domain"] = map[string]string{"name": opts.Scope.ProjectDomainName}
+		} else {
+			project["domain"] = map[string]string{"id": "default"}
+		}
+		auth["scope"] = map[string]interface{}{"project": project}
+	}
+
+	return map[string]interface{}{"auth": auth}
+}
+
+// Create prepares http POST call on Keystone v3 tokens endpoint
+func Create(client *gophercloud.ServiceClient, opts AuthOptions) CreateResult {
+	var res CreateResult
+	var resp *http.Response
+	resp, res.Err = client.Post(tokenURL(client), opts.ToTokenCreateMap(), &res.Body, &gophercloud.RequestOpts{OkCodes: []int{201}})
+	if resp != nil {
+		res.Header = resp.Header
+	}
+	return res
+}
@@ -0,0 +1,123 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Keystone v3 API responses and their processing for tokens
+
+package tokens
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud"
+)
+
+// CreateResult contains the response body, headers and error from a Create request
+type CreateResult struct {
+	gophercloud.Result
+}
+
+// CatalogEntry represents single service in token service catalog
+type CatalogEntry struct {
+	Type      string     `mapstructure:"type"`
+	Name      string     `mapstructure:"name"`
+	Endpoints []Endpoint `mapstructure:"endpoints"`
+}
+
+// Endpoint represents single endpoint of catalog service
+type Endpoint struct {
+	Interface string `mapstructure:"interface"`
+	Region    string `mapstructure:"region"`
+	URL       string `mapstructure:"url"`
+}
+
+// ExtractTokenID returns ID of created token, which is sent in X-Subject-Token header
+func (r CreateResult) ExtractTokenID() (string, error) {
+	if r.Err != nil {
+		return "", r.Err
+	}
+	return r.Header.Get("X-Subject-Token"), nil
+}
+
+// ExtractUserID returns ID of user which token was created for
+func (r CreateResult) ExtractUserID() (string, error) {
+	if r.Err != nil {
+		return "", r.Err
+	}
+
+	var response struct {
+		Token struct {
+			User struct {
+				ID string `mapstructure:"id"`
+			} `mapstructure:"user"`
+		} `mapstructure:"token"`
+	}
+
+	err := mapstructure.Decode(r.Body, &response)
+	return response.Token.User.ID, err
+}
+
+// ExtractDomainID returns ID of domain which token is scoped to, empty for token not scoped to domain
+func (r CreateResult) ExtractDomainID() (string, error) {
+	if r.Err != nil {
+		return "", r.Err
+	}
+
+	var response struct {
+		Token struct {
+			Domain struct {
+				ID string `mapstructure:"id"`
+			} `mapstructure:"domain"`
+		} `mapstructure:"token"`
+	}
+
+	err := mapstructure.Decode(r.Body, &response)
+	return response.Token.Domain.ID, err
+}
+
+// ExtractCatalog returns service catalog of created token
+func (r CreateResult) ExtractCatalog() ([]CatalogEntry, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var response struct {
+		Token struct {
+			Catalog []CatalogEntry `mapstructure:"catalog"`
+		} `mapstructure:"token"`
+	}
+
+	err := mapstructure.Decode(r.Body, &response)
+	return response.Token.Catalog, err
+}
+
+// EndpointLocator returns function looking up service endpoints in given catalog
+func EndpointLocator(catalog []CatalogEntry) gophercloud.EndpointLocator {
+	return func(opts gophercloud.EndpointOpts) (string, error) {
+		for _, entry := range catalog {
+			if entry.Type != opts.Type || (opts.Name != "" && entry.Name != opts.Name) {
+				continue
+			}
+			for _, endpoint := range entry.Endpoints {
+				if opts.Region != "" && endpoint.Region != opts.Region {
+					continue
+				}
+				if endpoint.Interface == string(opts.Availability) {
+					return gophercloud.NormalizeURL(endpoint.URL), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("No suitable endpoint could be found in the service catalog for %s", opts.Type)
+	}
+}
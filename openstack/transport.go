@@ -0,0 +1,153 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// transport contains HTTP transport with timeouts protecting against half-open connections
+
+package openstack
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rackspace/gophercloud"
+)
+
+// TransportOpts holds timeouts of HTTP transport used for Keystone and Cinder requests
+// Dial - time limit for establishing TCP connection
+// TLSHandshake - time limit for TLS handshake
+// ResponseHeader - time limit for reading response headers after request is written
+// IdleConn - time after which idle keep-alive connection is closed
+type TransportOpts struct {
+	Dial           time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	IdleConn       time.Duration
+}
+
+// NewTransport creates HTTP transport with given timeouts, it is meant to be shared by all clients
+func NewTransport(opts TransportOpts) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   opts.Dial,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.TLSHandshake,
+		ResponseHeaderTimeout: opts.ResponseHeader,
+		IdleConnTimeout:       opts.IdleConn,
+	}
+}
+
+// Probe sends unauthenticated GET request to given endpoint (eg. version discovery) and checks that it responds
+// within timeout, any response other than server error or not found means endpoint is reachable
+func Probe(transport http.RoundTripper, endpoint string, timeout time.Duration) error {
+	client := http.Client{Transport: transport, Timeout: timeout}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Endpoint %s responded with status %d", endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// RateLimitedError is returned instead of response when Keystone or Cinder rejects request with status 429
+// and tells in Retry-After header how long to wait before trying again
+type RateLimitedError struct {
+	URL        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("Request to %s was rate limited, retry after %s", e.URL, e.RetryAfter)
+}
+
+// RetryAfter returns delay requested by server which rate limited the call, false when error is not caused
+// by rate limiting or server did not tell how long to wait
+func RetryAfter(err error) (time.Duration, bool) {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if limited, ok := err.(*RateLimitedError); ok {
+		return limited.RetryAfter, true
+	}
+	return 0, false
+}
+
+// StatusCode returns HTTP status of unexpected response which call failed with, false when error is not caused
+// by response status (eg. connection failure)
+func StatusCode(err error) (int, bool) {
+	if e, ok := err.(*gophercloud.UnexpectedResponseCodeError); ok {
+		return e.Actual, true
+	}
+	return 0, false
+}
+
+// RateLimited wraps transport so that response with status 429 carrying Retry-After header is turned
+// into RateLimitedError, nil transport means default one. Response without the header is passed unchanged
+func RateLimited(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return rateLimitedTransport{base: base}
+}
+
+type rateLimitedTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip passes request to underlying transport and checks response for rate limiting
+func (t rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return resp, err
+	}
+	resp.Body.Close()
+	return nil, &RateLimitedError{URL: req.URL.String(), RetryAfter: delay}
+}
+
+// parseRetryAfter reads Retry-After header given either as number of seconds or as HTTP date,
+// date already passed means no delay
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := at.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
@@ -17,12 +17,15 @@ limitations under the License.
 package cinder
 
 import (
+	"fmt"
+
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack"
 	"github.com/rackspace/gophercloud/openstack/blockstorage/v1/snapshots"
 	"github.com/rackspace/gophercloud/openstack/blockstorage/v1/volumes"
 
 	limitsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/limits"
+	volumetypesintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/volumetypes"
 	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
 )
 
@@ -30,8 +33,12 @@ import (
 type ServiceV1 struct{}
 
 // GetLimits collects tenant limits by sending REST call to cinderhost:8776/v1/tenant_id/limits
-func (s ServiceV1) GetLimits(provider *gophercloud.ProviderClient) (types.Limits, error) {
+// Limits of other tenant than scoped one are read from quota usage with Block Storage API v2 only
+func (s ServiceV1) GetLimits(provider *gophercloud.ProviderClient, opts types.LimitsOptions) (types.Limits, error) {
 	limits := types.Limits{}
+	if opts.TenantID != "" {
+		return limits, fmt.Errorf("limits of tenant %s cannot be read from quota usage with Block Storage API v1", opts.TenantID)
+	}
 
 	client, err := openstack.NewBlockStorageV1(provider, gophercloud.EndpointOpts{})
 	if err != nil {
@@ -49,8 +56,72 @@ func (s ServiceV1) GetLimits(provider *gophercloud.ProviderClient) (types.Limits
 	return limits, nil
 }
 
+// GetDefaultQuotas collects default quotas by sending REST call to cinderhost:8776/v1/tenant_id/os-quota-class-sets/default
+// Listing default quota class requires admin role
+func (s ServiceV1) GetDefaultQuotas(provider *gophercloud.ProviderClient) (types.DefaultQuota, error) {
+	quota := types.DefaultQuota{}
+
+	client, err := openstack.NewBlockStorageV1(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return quota, err
+	}
+
+	defaults, err := limitsintel.GetDefaultQuotas(client).Extract()
+	if err != nil {
+		return quota, err
+	}
+
+	quota.Volumes = defaults.Volumes
+	quota.Gigabytes = defaults.Gigabytes
+
+	return quota, nil
+}
+
+// GetVolumeTypes counts volume types accessible by tenant by sending REST call to cinderhost:8776/v1/tenant_id/types
+func (s ServiceV1) GetVolumeTypes(provider *gophercloud.ProviderClient) (types.VolumeTypes, error) {
+	volumeTypes := types.VolumeTypes{}
+
+	client, err := openstack.NewBlockStorageV1(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return volumeTypes, err
+	}
+
+	list, err := volumetypesintel.List(client).Extract()
+	if err != nil {
+		return volumeTypes, err
+	}
+	volumeTypes.AccessibleCount = uint(len(list))
+
+	return volumeTypes, nil
+}
+
+// GetVolumeGroups does not collect anything, generic volume groups are not available to Block Storage API v1 clouds
+func (s ServiceV1) GetVolumeGroups(_ *gophercloud.ProviderClient, _ types.VolumeGroupsOptions) (types.VolumeGroups, error) {
+	return types.VolumeGroups{}, nil
+}
+
+// GetQoSSpecs does not collect anything, QoS specs are collected with Block Storage API v2 only
+func (s ServiceV1) GetQoSSpecs(_ *gophercloud.ProviderClient, _ types.QoSSpecsOptions) (types.QoSSpecs, error) {
+	return types.QoSSpecs{}, nil
+}
+
+// GetPools does not collect anything, back-end storage pools are collected with Block Storage API v2 only
+func (s ServiceV1) GetPools(_ *gophercloud.ProviderClient, _ types.PoolsOptions) (types.Pools, error) {
+	return types.Pools{}, nil
+}
+
+// GetServices does not collect anything, heartbeats of Cinder services are collected with Block Storage API v2 only
+func (s ServiceV1) GetServices(_ *gophercloud.ProviderClient) ([]types.ServiceHeartbeat, error) {
+	return nil, nil
+}
+
+// GetExtraSpecs does not collect anything, extra specs of volume types are collected with Block Storage API v2 only
+func (s ServiceV1) GetExtraSpecs(_ *gophercloud.ProviderClient, _ types.ExtraSpecsOptions) (map[string]map[string]string, error) {
+	return map[string]map[string]string{}, nil
+}
+
 // GetVolumes collects volumes data by sending REST call to cinderhost:8776/v1/tenant_id/volumes
-func (s ServiceV1) GetVolumes(provider *gophercloud.ProviderClient) (map[string]types.Volumes, error) {
+func (s ServiceV1) GetVolumes(provider *gophercloud.ProviderClient, _ types.ListOptions) (map[string]types.Volumes, error) {
 	vols := map[string]types.Volumes{}
 
 	client, err := openstack.NewBlockStorageV1(provider, gophercloud.EndpointOpts{})
@@ -84,7 +155,8 @@ func (s ServiceV1) GetVolumes(provider *gophercloud.ProviderClient) (map[string]
 }
 
 // GetSnapshots collects snapshot data by sending REST call to cinderhost:8776/v1/tenant_id/snapshots
-func (s ServiceV1) GetSnapshots(provider *gophercloud.ProviderClient) (map[string]types.Snapshots, error) {
+// Incremental listing is not supported by API version 1, full listing is always done
+func (s ServiceV1) GetSnapshots(provider *gophercloud.ProviderClient, _ types.ListOptions) (map[string]types.Snapshots, error) {
 	snaps := map[string]types.Snapshots{}
 
 	client, err := openstack.NewBlockStorageV1(provider, gophercloud.EndpointOpts{})
@@ -107,6 +179,8 @@ func (s ServiceV1) GetSnapshots(provider *gophercloud.ProviderClient) (map[strin
 
 	for _, snapshot := range snapshotList {
 		snapCounts := snaps["tenant_id"]
+		snapCounts.Orphaned = -1
+		snapCounts.MaxPerVolume = -1
 		snapCounts.Count += 1
 		snapCounts.Bytes += snapshot.Size * 1024 * 1024 * 1024
 	}
@@ -18,20 +18,48 @@ limitations under the License.
 package cinder
 
 import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/rackspace/gophercloud"
+	"github.com/rackspace/gophercloud/pagination"
 
+	cinderservicesintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/cinderservices"
 	limitsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/limits"
+	poolsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/pools"
+	qosspecsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/qosspecs"
 	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2"
 	snapshotsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2/snapshots"
 	volumesintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v2/volumes"
+	openstackintelv3 "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/v3"
+	volumegroupsintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/volumegroups"
+	volumetypesintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack/volumetypes"
 	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
 )
 
+const (
+	// changesSinceFormat is time format accepted by changes-since filter
+	changesSinceFormat = "2006-01-02T15:04:05"
+	// changesSinceOverlap is subtracted from listing time to tolerate clock skew between plugin and Cinder
+	changesSinceOverlap = time.Minute
+)
+
 // ServiceV2 serves as dispatcher for Cinder API version 2.0
 type ServiceV2 struct{}
 
 // GetLimits collects tenant limits by sending REST call to cinderhost:8776/v2/tenant_id/limits
-func (s ServiceV2) GetLimits(provider *gophercloud.ProviderClient) (types.Limits, error) {
+// Quotas of volume types given in options, reserved usage and generic volume groups quota, when requested in options,
+// are collected from cinderhost:8776/v2/tenant_id/os-quota-sets/tenant_id?usage=true
+// Limits are fetched conditionally when validator is given in options and quota usage is not queried, limits not
+// modified since then are not returned
+// When tenant ID is given in options all limits are read from its quota usage, which requires admin role unless
+// tenant is the scoped one
+func (s ServiceV2) GetLimits(provider *gophercloud.ProviderClient, opts types.LimitsOptions) (types.Limits, error) {
 	limits := types.Limits{}
 
 	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
@@ -39,51 +67,438 @@ func (s ServiceV2) GetLimits(provider *gophercloud.ProviderClient) (types.Limits
 		return limits, err
 	}
 
-	tenantLimits, err := limitsintel.Get(client, "limits").Extract()
+	if opts.TenantID != "" {
+		usage, err := limitsintel.GetQuotaUsage(client, opts.TenantID).Extract()
+		if err != nil {
+			return limits, err
+		}
+		limits.MaxTotalVolumes, limits.VolumesUsed = usage["volumes"].Limit, usage["volumes"].InUse
+		limits.MaxTotalVolumeGigabytes, limits.GigabytesUsed = usage["gigabytes"].Limit, usage["gigabytes"].InUse
+		// backup quotas are optional the same way as in limits
+		if u, found := usage["backups"]; found {
+			limits.Backups, limits.BackupsUsed = &u.Limit, &u.InUse
+		}
+		if u, found := usage["backup_gigabytes"]; found {
+			limits.BackupGigabytes, limits.BackupGigabytesUsed = &u.Limit, &u.InUse
+		}
+		addQuotaUsage(&limits, usage, opts)
+		return limits, nil
+	}
+
+	var result limitsintel.GetResult
+	if opts.Conditional != nil && len(opts.VolumeTypes) == 0 && !opts.Reserved && !opts.Groups {
+		result = limitsintel.GetConditional(client, "limits", opts.Conditional.ETag)
+		if result.NotModified {
+			// ETag is kept when not repeated in not modified response
+			if etag := result.ETag(); etag != "" {
+				opts.Conditional.ETag = etag
+			}
+			opts.Conditional.NotModified = true
+			return limits, nil
+		}
+		opts.Conditional.ETag = result.ETag()
+	} else {
+		result = limitsintel.Get(client, "limits")
+	}
+	tenantLimits, err := result.Extract()
 	if err != nil {
 		return limits, err
 	}
 
 	limits.MaxTotalVolumes = tenantLimits.MaxTotalVolumes
 	limits.MaxTotalVolumeGigabytes = tenantLimits.MaxTotalVolumeGigabytes
+	limits.VolumesUsed = tenantLimits.TotalVolumesUsed
+	limits.GigabytesUsed = tenantLimits.TotalGigabytesUsed
+	limits.Backups = tenantLimits.MaxTotalBackups
+	limits.BackupsUsed = tenantLimits.TotalBackupsUsed
+	limits.BackupGigabytes = tenantLimits.MaxTotalBackupGigabytes
+	limits.BackupGigabytesUsed = tenantLimits.TotalBackupGigabytesUsed
+
+	if len(opts.VolumeTypes) == 0 && !opts.Reserved && !opts.Groups {
+		return limits, nil
+	}
+
+	// block storage endpoint is scoped to tenant, its last path element is tenant ID
+	tenantID := path.Base(strings.TrimSuffix(client.ResourceBaseURL(), "/"))
+	usage, err := limitsintel.GetQuotaUsage(client, tenantID).Extract()
+	if err != nil {
+		return limits, err
+	}
+	addQuotaUsage(&limits, usage, opts)
 
 	return limits, nil
 }
 
-// GetVolumes collects volumes data by sending REST call to cinderhost:8776/v2/tenant_id/volumes/detail?all_tenants=true
-func (s ServiceV2) GetVolumes(provider *gophercloud.ProviderClient) (map[string]types.Volumes, error) {
-	vols := map[string]types.Volumes{}
+// addQuotaUsage sets quotas of volume types, reserved usage and generic volume groups quota requested in options
+// from quota usage
+func addQuotaUsage(limits *types.Limits, usage map[string]limitsintel.QuotaUsage, opts types.LimitsOptions) {
+	for _, volumeType := range opts.VolumeTypes {
+		if u, found := usage["gigabytes_"+volumeType]; found {
+			opts.ByType[volumeType] = types.TypeLimits{Gigabytes: u.Limit, GigabytesUsed: u.InUse}
+		}
+	}
+	if opts.Reserved {
+		limits.VolumesReserved = usage["volumes"].Reserved
+		limits.GigabytesReserved = usage["gigabytes"].Reserved
+		limits.ReservedQueried = true
+	}
+	// groups quota is reported only by clouds supporting generic volume groups
+	limits.GroupsQueried = opts.Groups
+	if u, found := usage["groups"]; opts.Groups && found {
+		remaining := -1
+		if u.Limit >= 0 {
+			remaining = u.Limit - u.InUse
+		}
+		limits.Groups, limits.GroupsUsed, limits.GroupsRemaining = &u.Limit, &u.InUse, &remaining
+	}
+}
+
+// GetDefaultQuotas collects default quotas by sending REST call to cinderhost:8776/v2/tenant_id/os-quota-class-sets/default
+// Listing default quota class requires admin role
+func (s ServiceV2) GetDefaultQuotas(provider *gophercloud.ProviderClient) (types.DefaultQuota, error) {
+	quota := types.DefaultQuota{}
+
+	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return quota, err
+	}
+
+	defaults, err := limitsintel.GetDefaultQuotas(client).Extract()
+	if err != nil {
+		return quota, err
+	}
+
+	quota.Volumes = defaults.Volumes
+	quota.Gigabytes = defaults.Gigabytes
+
+	return quota, nil
+}
+
+// GetVolumeTypes counts volume types accessible by tenant by sending REST call to cinderhost:8776/v2/tenant_id/types
+func (s ServiceV2) GetVolumeTypes(provider *gophercloud.ProviderClient) (types.VolumeTypes, error) {
+	volumeTypes := types.VolumeTypes{}
+
+	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return volumeTypes, err
+	}
+
+	list, err := volumetypesintel.List(client).Extract()
+	if err != nil {
+		return volumeTypes, err
+	}
+	volumeTypes.AccessibleCount = uint(len(list))
+
+	return volumeTypes, nil
+}
+
+// GetQoSSpecs counts QoS specs by sending REST call to cinderhost:8776/v2/tenant_id/qos-specs, volume types associated
+// with each spec are counted into options by calling cinderhost:8776/v2/tenant_id/qos-specs/spec_id/associations
+func (s ServiceV2) GetQoSSpecs(provider *gophercloud.ProviderClient, opts types.QoSSpecsOptions) (types.QoSSpecs, error) {
+	qosSpecs := types.QoSSpecs{}
+
+	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return qosSpecs, err
+	}
+
+	list, err := qosspecsintel.List(client).Extract()
+	if err != nil {
+		return qosSpecs, err
+	}
+	count := uint(len(list))
+	qosSpecs.Count = &count
+
+	for _, spec := range list {
+		associations, err := qosspecsintel.GetAssociations(client, spec.ID).Extract()
+		if err != nil {
+			return qosSpecs, err
+		}
+		opts.Associations[spec.Name] = uint(len(associations))
+	}
+
+	return qosSpecs, nil
+}
+
+// GetPools counts back-end storage pools by sending REST call to cinderhost:8776/v2/tenant_id/scheduler-stats/get_pools,
+// overcommit ratio (provisioned to total capacity) of each pool reporting both capacities is set into options
+func (s ServiceV2) GetPools(provider *gophercloud.ProviderClient, opts types.PoolsOptions) (types.Pools, error) {
+	pools := types.Pools{}
 
+	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return pools, err
+	}
+
+	list, err := poolsintel.List(client).Extract()
+	if err != nil {
+		return pools, err
+	}
+	count := uint(len(list))
+	pools.Count = &count
+
+	for _, pool := range list {
+		provisioned, found := pool.Capacity("provisioned_capacity_gb")
+		if !found {
+			continue
+		}
+		if total, found := pool.Capacity("total_capacity_gb"); found && total > 0 {
+			opts.OvercommitRatio[pool.Name] = provisioned / total
+		}
+	}
+
+	return pools, nil
+}
+
+// GetServices collects last heartbeats of Cinder services by sending REST call to cinderhost:8776/v2/tenant_id/os-services,
+// it requires admin role. Services which never reported their state, or report it in unknown format, are omitted
+func (s ServiceV2) GetServices(provider *gophercloud.ProviderClient) ([]types.ServiceHeartbeat, error) {
 	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
 	if err != nil {
 		return nil, err
 	}
 
-	opts := volumesintel.ListOpts{AllTenants: true}
+	list, err := cinderservicesintel.List(client).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeats := make([]types.ServiceHeartbeat, 0, len(list))
+	for _, service := range list {
+		if service.UpdatedAt == "" {
+			continue
+		}
+		updatedAt, err := types.ParseCinderTime(service.UpdatedAt)
+		if err != nil {
+			log.Printf("WARNING: heartbeat of %s on %s omitted: %v", service.Binary, service.Host, err)
+			continue
+		}
+		heartbeats = append(heartbeats, types.ServiceHeartbeat{Host: service.Host, Binary: service.Binary, UpdatedAt: updatedAt})
+	}
 
-	pager := volumesintel.List(client, opts)
-	page, err := pager.AllPages()
+	return heartbeats, nil
+}
+
+// GetExtraSpecs collects extra specs of volume types listed by sending REST call to cinderhost:8776/v2/tenant_id/types,
+// extra specs of each type not known in options are requested from cinderhost:8776/v2/tenant_id/types/type_id/extra_specs
+// Extra specs are returned by volume type ID for listed types only, reading them requires admin role
+func (s ServiceV2) GetExtraSpecs(provider *gophercloud.ProviderClient, opts types.ExtraSpecsOptions) (map[string]map[string]string, error) {
+	extraSpecs := map[string]map[string]string{}
+
+	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
 	if err != nil {
 		return nil, err
 	}
 
-	volumes, err := volumesintel.ExtractVolumes(page)
+	list, err := volumetypesintel.List(client).Extract()
 	if err != nil {
 		return nil, err
 	}
 
+	for _, volumeType := range list {
+		if known, found := opts.Known[volumeType.ID]; found {
+			extraSpecs[volumeType.ID] = known
+			continue
+		}
+		specs, err := volumetypesintel.GetExtraSpecs(client, volumeType.ID).Extract()
+		if err != nil {
+			return nil, err
+		}
+		extraSpecs[volumeType.ID] = specs
+	}
+
+	return extraSpecs, nil
+}
+
+// GetVolumeGroups counts generic volume groups of tenant by sending REST call to cinderhost:8776/v3/tenant_id/groups/detail
+// with microversion supporting them, groups are grouped by status into options. Quota of groups is collected from
+// cinderhost:8776/v3/tenant_id/os-quota-sets/tenant_id?usage=true
+func (s ServiceV2) GetVolumeGroups(provider *gophercloud.ProviderClient, opts types.VolumeGroupsOptions) (types.VolumeGroups, error) {
+	volumeGroups := types.VolumeGroups{}
+
+	client, err := openstackintelv3.NewBlockStorageV3(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return volumeGroups, err
+	}
+
+	page, err := volumegroupsintel.List(client).AllPages()
+	if err != nil {
+		return volumeGroups, err
+	}
+	list, err := volumegroupsintel.ExtractVolumeGroups(page)
+	if err != nil {
+		return volumeGroups, err
+	}
+	count := uint(len(list))
+	volumeGroups.Count = &count
+	for _, group := range list {
+		opts.ByStatus.Add([]string{"status"}, map[string]string{"status": group.Status}, 0)
+	}
+
+	// block storage endpoint is scoped to tenant, its last path element is tenant ID
+	tenantID := path.Base(strings.TrimSuffix(client.ResourceBaseURL(), "/"))
+	usage, err := limitsintel.GetQuotaUsage(client, tenantID).Extract()
+	if err != nil {
+		return volumeGroups, err
+	}
+	if u, found := usage["groups"]; found {
+		volumeGroups.Quota, volumeGroups.QuotaInUse = &u.Limit, &u.InUse
+	}
+
+	return volumeGroups, nil
+}
+
+// GetVolumes collects volumes data by sending REST call to cinderhost:8776/v2/tenant_id/volumes/detail?all_tenants=true
+// Volumes carrying metadata key given in options are counted as managed, status given in options limits listing
+// IDs of listed volumes are recorded in options volume set when provided, extra fields given in options are summed
+// from raw volume payload and volumes are grouped by fields given in options
+// Deleted volumes are listed separately and counted only as deleted when requested in options
+func (s ServiceV2) GetVolumes(provider *gophercloud.ProviderClient, opts types.ListOptions) (map[string]types.Volumes, error) {
+	vols := map[string]types.Volumes{}
+
+	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, err
+	}
+
+	listOpts := volumesintel.ListOpts{AllTenants: opts.AllTenants, ProjectID: opts.ProjectID, Status: opts.VolumeStatus}
+
+	volumes, rawVolumes, err := listVolumes(client, listOpts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.ExtraVolumeFields) > 0 {
+		for i, raw := range rawVolumes {
+			tenantID := volumes[i].TenantID()
+			for metric, field := range opts.ExtraVolumeFields {
+				value, ok := numericField(raw, field)
+				if !ok {
+					continue
+				}
+				if opts.ExtraVolumeValues[tenantID] == nil {
+					opts.ExtraVolumeValues[tenantID] = map[string]float64{}
+				}
+				opts.ExtraVolumeValues[tenantID][metric] += value
+			}
+		}
+	}
+
+	errors := map[string]uint{}
+	hosts := map[string]map[string]bool{}
 	for _, volume := range volumes {
-		volCounts := vols[volume.OsVolTenantAttrTenantID]
+		if opts.VolumeIDs != nil {
+			opts.VolumeIDs[volume.ID] = true
+		}
+		if opts.VolumeHosts != nil && volume.OsVolHostAttrHost != "" {
+			opts.VolumeHosts[volume.ID] = volume.OsVolHostAttrHost
+		}
+		tenantID := volume.TenantID()
+		volCounts := vols[tenantID]
 		volCounts.Count += 1
 		volCounts.Bytes += volume.Size * 1024 * 1024 * 1024
-		vols[volume.OsVolTenantAttrTenantID] = volCounts
+		switch volume.Status {
+		case "in-use":
+			volCounts.InUseGB += volume.Size
+			if len(volume.Attachments) == 0 {
+				volCounts.InconsistentAttachment += 1
+			}
+		case "available":
+			volCounts.AvailableGB += volume.Size
+		case "deleting":
+			volCounts.Deleting += 1
+		}
+		if strings.HasPrefix(volume.Status, "error") {
+			errors[tenantID] += 1
+		}
+		switch {
+		case volume.Encrypted == nil:
+			volCounts.UnknownEncryptionGB += volume.Size
+		case *volume.Encrypted:
+			volCounts.EncryptedGB += volume.Size
+		default:
+			volCounts.UnencryptedGB += volume.Size
+		}
+		volCounts.AttachmentsTotal += uint(len(volume.Attachments))
+		if len(volume.Attachments) > 1 {
+			volCounts.Multiattach += 1
+		}
+		if state := volume.MigrationState(); state != "" && state != "success" {
+			volCounts.Migrating += 1
+		}
+		// host is reported as host@backend#pool, pools of the same back-end are not distinguished
+		if host := strings.SplitN(volume.OsVolHostAttrHost, "#", 2)[0]; host != "" && !hosts[tenantID][host] {
+			if hosts[tenantID] == nil {
+				hosts[tenantID] = map[string]bool{}
+			}
+			hosts[tenantID][host] = true
+			volCounts.DistinctHosts += 1
+		}
+		if _, managed := volume.Metadata[opts.ManagedMetadataKey]; opts.ManagedMetadataKey != "" && managed {
+			volCounts.Managed += 1
+		}
+		vols[tenantID] = volCounts
+		if len(opts.GroupVolumesBy) > 0 {
+			groups, found := opts.VolumeGroups[tenantID]
+			if !found {
+				groups = types.Groups{}
+				opts.VolumeGroups[tenantID] = groups
+			}
+			groups.Add(opts.GroupVolumesBy, volumeGroupValues(volume, opts.SizeBuckets), volume.Size)
+		}
+	}
+
+	for tenantID, volCounts := range vols {
+		if volCounts.Count > 0 {
+			volCounts.AvgSizeGB = float64(volCounts.Bytes) / (1024 * 1024 * 1024) / float64(volCounts.Count)
+			volCounts.ErrorPercent = float64(errors[tenantID]) / float64(volCounts.Count) * 100
+			volCounts.Errors = errors[tenantID]
+			vols[tenantID] = volCounts
+		}
+	}
+
+	if opts.IncludeDeleted {
+		if err := countDeletedVolumes(client, vols, volumes, opts); err != nil {
+			return nil, err
+		}
 	}
 
 	return vols, nil
 }
 
+// countDeletedVolumes lists deleted volumes and counts those not listed as existing into deleted volumes of their
+// tenant, extra volume fields are not summed for them
+func countDeletedVolumes(client *gophercloud.ServiceClient, vols map[string]types.Volumes, existing []volumesintel.Volume, opts types.ListOptions) error {
+	listOpts := volumesintel.ListOpts{AllTenants: opts.AllTenants, ProjectID: opts.ProjectID, Deleted: true}
+	opts.ExtraVolumeFields = nil
+	deleted, _, err := listVolumes(client, listOpts, opts)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(existing))
+	for _, volume := range existing {
+		listed[volume.ID] = true
+	}
+	for _, volume := range deleted {
+		if listed[volume.ID] {
+			continue
+		}
+		volCounts := vols[volume.TenantID()]
+		volCounts.Deleted += 1
+		vols[volume.TenantID()] = volCounts
+	}
+
+	return nil
+}
+
 // GetSnapshots collects snapshot data by sending REST call to cinderhost:8776/v2/tenant_id/snapshots/detail?all_tenants=true
-func (s ServiceV2) GetSnapshots(provider *gophercloud.ProviderClient) (map[string]types.Snapshots, error) {
+// When snapshot index is provided in options and was already populated, only snapshots changed since previous listing
+// are requested (changes-since filter) and merged into index. Full listing is done when Cinder rejects the filter.
+// Snapshots which source volume is missing in options volume set are counted as orphaned.
+// Snapshots are counted by back-end host of their source volume when volume hosts are given in options.
+// Deleted snapshots are listed separately and counted only as deleted when requested in options.
+func (s ServiceV2) GetSnapshots(provider *gophercloud.ProviderClient, opts types.ListOptions) (map[string]types.Snapshots, error) {
 	snaps := map[string]types.Snapshots{}
 
 	client, err := openstackintel.NewBlockStorageV2(provider, gophercloud.EndpointOpts{})
@@ -91,24 +506,296 @@ func (s ServiceV2) GetSnapshots(provider *gophercloud.ProviderClient) (map[strin
 		return snaps, err
 	}
 
-	opts := snapshotsintel.ListOpts{AllTenants: true}
-	pager := snapshotsintel.List(client, opts)
-	page, err := pager.AllPages()
+	if opts.Snapshots != nil {
+		if err := getSnapshotsIncremental(client, opts.Snapshots, opts); err != nil {
+			return nil, err
+		}
+		countByMetadata(opts.Snapshots, opts)
+		groupSnapshots(opts.Snapshots, opts)
+		countByHost(opts.Snapshots, opts)
+		return aggregateSnapshots(client, opts.Snapshots, opts)
+	}
+
+	snapshotList, err := listSnapshots(client, snapshotsintel.ListOpts{AllTenants: opts.AllTenants, ProjectID: opts.ProjectID}, opts)
 	if err != nil {
 		return snaps, err
 	}
 
-	snapshotList, err := snapshotsintel.ExtractSnapshots(page)
+	idx := types.NewSnapshotIndex()
+	indexSnapshots(idx, snapshotList)
+	countByMetadata(idx, opts)
+	groupSnapshots(idx, opts)
+	countByHost(idx, opts)
+
+	return aggregateSnapshots(client, idx, opts)
+}
+
+// aggregateSnapshots sums indexed snapshots per tenant, deleted snapshots not present in index are counted into
+// deleted snapshots of their tenant when requested in options
+func aggregateSnapshots(client *gophercloud.ServiceClient, idx *types.SnapshotIndex, opts types.ListOptions) (map[string]types.Snapshots, error) {
+	snaps := idx.Aggregate(opts.VolumeIDs)
+	if !opts.IncludeDeleted {
+		return snaps, nil
+	}
+
+	deleted, err := listSnapshots(client, snapshotsintel.ListOpts{AllTenants: opts.AllTenants, ProjectID: opts.ProjectID, Deleted: true}, opts)
 	if err != nil {
-		return snaps, err
+		return nil, err
+	}
+	for _, snapshot := range deleted {
+		if _, found := idx.Items[snapshot.ID]; found {
+			continue
+		}
+		tenantID := snapshot.OsExtendedSnapshotAttributesProjectID
+		snapCounts, found := snaps[tenantID]
+		if !found && opts.VolumeIDs == nil {
+			snapCounts.Orphaned = -1
+			snapCounts.MaxPerVolume = -1
+		}
+		snapCounts.Deleted += 1
+		snaps[tenantID] = snapCounts
 	}
 
+	return snaps, nil
+}
+
+// countByMetadata records snapshot counts by metadata keys given in options
+func countByMetadata(idx *types.SnapshotIndex, opts types.ListOptions) {
+	if len(opts.SnapshotMetadataKeys) == 0 {
+		return
+	}
+	for tenantID, counts := range idx.CountByMetadata(opts.SnapshotMetadataKeys) {
+		opts.SnapshotMetadataCounts[tenantID] = counts
+	}
+}
+
+// groupSnapshots records snapshot groups by fields given in options
+func groupSnapshots(idx *types.SnapshotIndex, opts types.ListOptions) {
+	if len(opts.GroupSnapshotsBy) == 0 {
+		return
+	}
+	for tenantID, groups := range idx.Group(opts.GroupSnapshotsBy) {
+		opts.SnapshotGroups[tenantID] = groups
+	}
+}
+
+// countByHost records snapshot counts by back-end host of their source volume when volume hosts are given in options
+func countByHost(idx *types.SnapshotIndex, opts types.ListOptions) {
+	if opts.VolumeHosts == nil {
+		return
+	}
+	for host, count := range idx.CountByHost(opts.VolumeHosts) {
+		opts.SnapshotHostCounts[host] = count
+	}
+}
+
+// volumeGroupValues returns values of volume fields which volumes can be grouped by, size bucket is given
+// by upper bounds of buckets
+func volumeGroupValues(volume volumesintel.Volume, sizeBuckets []int) map[string]string {
+	return map[string]string{
+		"status":            volume.Status,
+		"volume_type":       volume.VolumeType,
+		"availability_zone": volume.AvailabilityZone,
+		"bootable":          volume.Bootable,
+		"size_bucket":       types.SizeBucket(volume.Size, sizeBuckets),
+	}
+}
+
+func getSnapshotsIncremental(client *gophercloud.ServiceClient, idx *types.SnapshotIndex, listOpts types.ListOptions) error {
+	// listing start time is remembered (with small overlap for clock skew) before request is sent,
+	// so snapshots modified while listing is in progress are requested again next time
+	started := time.Now().UTC().Add(-changesSinceOverlap)
+
+	opts := snapshotsintel.ListOpts{AllTenants: listOpts.AllTenants}
+	full := idx.Since.IsZero() || idx.Unsupported
+	if !full {
+		opts.ChangesSince = idx.Since.Format(changesSinceFormat)
+	}
+
+	snapshotList, err := listSnapshots(client, opts, listOpts)
+	if err != nil && !full {
+		if e, ok := err.(*gophercloud.UnexpectedResponseCodeError); ok && e.Actual == http.StatusBadRequest {
+			// changes-since filter is not supported, fall back to full listing from now on
+			full = true
+			idx.Unsupported = true
+			opts.ChangesSince = ""
+			snapshotList, err = listSnapshots(client, opts, listOpts)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if full {
+		idx.Items = map[string]types.SnapshotEntry{}
+		idx.Resynced = started
+	}
+	indexSnapshots(idx, snapshotList)
+	idx.Since = started
+
+	return nil
+}
+
+// indexSnapshots merges listed snapshots into index, deleted snapshots are removed
+func indexSnapshots(idx *types.SnapshotIndex, snapshotList []snapshotsintel.Snapshot) {
 	for _, snapshot := range snapshotList {
-		snapCounts := snaps[snapshot.OsExtendedSnapshotAttributesProjectID]
-		snapCounts.Count += 1
-		snapCounts.Bytes += snapshot.Size * 1024 * 1024 * 1024
-		snaps[snapshot.OsExtendedSnapshotAttributesProjectID] = snapCounts
+		if snapshot.Status == "deleted" {
+			delete(idx.Items, snapshot.ID)
+			continue
+		}
+		metadata := make(map[string]string, len(snapshot.Meta))
+		for key, value := range snapshot.Meta {
+			metadata[key] = fmt.Sprint(value)
+		}
+		idx.Items[snapshot.ID] = types.SnapshotEntry{
+			TenantID: snapshot.OsExtendedSnapshotAttributesProjectID,
+			VolumeID: snapshot.VolumeID,
+			Size:     snapshot.Size,
+			Status:   snapshot.Status,
+			Metadata: metadata,
+		}
 	}
+}
 
-	return snaps, nil
+// numericField returns numeric value of payload field under dot separated path, numeric strings are accepted
+func numericField(payload map[string]interface{}, path string) (float64, bool) {
+	var value interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		if value, ok = object[key]; !ok {
+			return 0, false
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// listVolumes lists volumes page by page when page size is given in options, each page following last volume of
+// previous one. Failed page is listed again with retry given in options, so listing resumes where it failed.
+// Raw volumes are extracted only when extra volume fields are given in options. Volumes listed more than once
+// (eg. shifted to next page by concurrent deletion) are returned only once.
+func listVolumes(client *gophercloud.ServiceClient, listOpts volumesintel.ListOpts, opts types.ListOptions) ([]volumesintel.Volume, []map[string]interface{}, error) {
+	retry := opts.RetryPage
+	if retry == nil {
+		retry = func(list func() error) error { return list() }
+	}
+	if opts.PageSize > 0 {
+		listOpts.Limit = opts.PageSize
+	}
+
+	volumes := []volumesintel.Volume{}
+	rawVolumes := []map[string]interface{}{}
+	// volumes created or deleted while listing shift following pages, so volume may be listed again on next page,
+	// it is counted only once. Page not adding any volume means listing does not advance (eg. marker is ignored),
+	// listing is failed rather than repeated forever
+	listed := map[string]bool{}
+	duplicates := 0
+	for {
+		before := len(volumes)
+		var page pagination.Page
+		err := retry(func() error {
+			var err error
+			page, err = volumesintel.List(client, listOpts).AllPages()
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pageVolumes, err := volumesintel.ExtractVolumes(page)
+		if err != nil {
+			return nil, nil, err
+		}
+		var pageRawVolumes []map[string]interface{}
+		if len(opts.ExtraVolumeFields) > 0 {
+			if pageRawVolumes, err = volumesintel.ExtractRawVolumes(page); err != nil {
+				return nil, nil, err
+			}
+		}
+		for i, volume := range pageVolumes {
+			if listed[volume.ID] {
+				duplicates++
+				continue
+			}
+			listed[volume.ID] = true
+			volumes = append(volumes, volume)
+			if pageRawVolumes != nil {
+				rawVolumes = append(rawVolumes, pageRawVolumes[i])
+			}
+		}
+
+		if opts.PageSize <= 0 || len(pageVolumes) < opts.PageSize {
+			if duplicates > 0 {
+				log.Printf("DEBUG: dropped %d volumes listed more than once across pages", duplicates)
+			}
+			return volumes, rawVolumes, nil
+		}
+		marker := pageVolumes[len(pageVolumes)-1].ID
+		if len(volumes) == before || marker == listOpts.Marker {
+			return nil, nil, fmt.Errorf("Listing of volumes does not advance past marker %q, check pagination support of Cinder", listOpts.Marker)
+		}
+		listOpts.Marker = marker
+	}
+}
+
+// listSnapshots lists snapshots page by page the same way as listVolumes lists volumes
+func listSnapshots(client *gophercloud.ServiceClient, listOpts snapshotsintel.ListOpts, opts types.ListOptions) ([]snapshotsintel.Snapshot, error) {
+	retry := opts.RetryPage
+	if retry == nil {
+		retry = func(list func() error) error { return list() }
+	}
+	if opts.PageSize > 0 {
+		listOpts.Limit = opts.PageSize
+	}
+
+	snapshots := []snapshotsintel.Snapshot{}
+	listed := map[string]bool{}
+	duplicates := 0
+	for {
+		before := len(snapshots)
+		var page pagination.Page
+		err := retry(func() error {
+			var err error
+			page, err = snapshotsintel.List(client, listOpts).AllPages()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		pageSnapshots, err := snapshotsintel.ExtractSnapshots(page)
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range pageSnapshots {
+			if listed[snapshot.ID] {
+				duplicates++
+				continue
+			}
+			listed[snapshot.ID] = true
+			snapshots = append(snapshots, snapshot)
+		}
+
+		if opts.PageSize <= 0 || len(pageSnapshots) < opts.PageSize {
+			if duplicates > 0 {
+				log.Printf("DEBUG: dropped %d snapshots listed more than once across pages", duplicates)
+			}
+			return snapshots, nil
+		}
+		marker := pageSnapshots[len(pageSnapshots)-1].ID
+		if len(snapshots) == before || marker == listOpts.Marker {
+			return nil, fmt.Errorf("Listing of snapshots does not advance past marker %q, check pagination support of Cinder", listOpts.Marker)
+		}
+		listOpts.Marker = marker
+	}
 }
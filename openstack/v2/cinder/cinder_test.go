@@ -18,12 +18,14 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	th "github.com/rackspace/gophercloud/testhelper"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/suite"
 
 	openstackintel "github.com/intelsdi-x/snap-plugin-collector-cinder/openstack"
+	"github.com/intelsdi-x/snap-plugin-collector-cinder/types"
 )
 
 type CinderV2Suite struct {
@@ -36,7 +38,23 @@ type CinderV2Suite struct {
 	Vol1Size, Vol2Size                       int
 	Token                                    string
 	SnapShotSize                             int
+	SnapshotsChangesSince                    string
+	ChangesSinceRejected                     bool
+	ChangesSinceRequests                     int
+	VolumesStatus                            string
+	VolumesAllTenants                        string
+	VolumesTenantField                       string
+	Vol1Status                               string
+	Vol1Migstat                              string
+	Vol1Attachments                          string
+	Vol1Host                                 string
+	VolumesPageFailures                      int
+	VolumesPageOverlap                       bool
+	SnapshotsPageFailures                    int
+	SnapshotsPageOverlap                     bool
+	MarkerIgnored                            bool
 	Tenant1ID, Tenant2ID                     string
+	LimitsETag                               string
 }
 
 func (s *CinderV2Suite) SetupSuite() {
@@ -54,9 +72,18 @@ func (s *CinderV2Suite) SetupSuite() {
 	s.Vol2 = "vol2id_321"
 	s.Vol1Size = 11
 	s.Vol2Size = 22
+	s.VolumesTenantField = "os-vol-tenant-attr:tenant_id"
+	s.Vol1Status = "available"
+	s.Vol1Migstat = "null"
+	s.Vol1Attachments = "[]"
+	s.Vol1Host = `"rbd:volumes#DEFAULT"`
 	registerVolumes(s)
 	s.SnapShotSize = 5
 	registerSnapshots(s)
+	registerVolumeGroups(s)
+	registerQoSSpecs(s)
+	registerPools(s)
+	registerServices(s)
 }
 
 func (suite *CinderV2Suite) TearDownSuite() {
@@ -72,23 +99,423 @@ func (s *CinderV2Suite) TestGetLimits() {
 	Convey("Given Cinder absolute limits are requested", s.T(), func() {
 
 		Convey("When authentication is required", func() {
-			provider, err := openstackintel.Authenticate(th.Endpoint(), "me", "secret", "tenant", "", "")
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
 			th.AssertNoErr(s.T(), err)
 			th.CheckEquals(s.T(), s.Token, provider.TokenID)
 
 			Convey("and GetLimits called", func() {
 				dispatch := ServiceV2{}
-				limits, err := dispatch.GetLimits(provider)
+				limits, err := dispatch.GetLimits(provider, types.LimitsOptions{})
 
 				Convey("Then proper limits values are returned", func() {
 					So(limits.MaxTotalVolumes, ShouldEqual, s.MaxTotalVolumes)
 					So(limits.MaxTotalVolumeGigabytes, ShouldEqual, s.MaxTotalVolumeGigabytes)
+					So(limits.VolumesUsed, ShouldEqual, 2)
+					So(limits.GigabytesUsed, ShouldEqual, 4)
+					So(*limits.Backups, ShouldEqual, 10)
+					So(*limits.BackupsUsed, ShouldEqual, 1)
+					So(*limits.BackupGigabytes, ShouldEqual, 1000)
+					So(*limits.BackupGigabytesUsed, ShouldEqual, 3)
 				})
 
 				Convey("and no error reported", func() {
 					So(err, ShouldBeNil)
 				})
 			})
+
+			Convey("and GetSnapshots called three times while changes-since filter is rejected", func() {
+				s.ChangesSinceRejected = true
+				defer func() { s.ChangesSinceRejected = false }()
+				dispatch := ServiceV2{}
+				idx := types.NewSnapshotIndex()
+				_, err1 := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, Snapshots: idx})
+				requests := s.ChangesSinceRequests
+				_, err2 := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, Snapshots: idx})
+				rejected := s.ChangesSinceRequests - requests
+				snapshots, err3 := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, Snapshots: idx})
+
+				Convey("Then filter is tried once and snapshots are listed fully afterwards", func() {
+					So(err1, ShouldBeNil)
+					So(err2, ShouldBeNil)
+					So(err3, ShouldBeNil)
+					So(rejected, ShouldEqual, 1)
+					So(idx.Unsupported, ShouldBeTrue)
+					So(s.ChangesSinceRequests-requests, ShouldEqual, 1)
+					So(s.SnapshotsChangesSince, ShouldBeEmpty)
+					So(snapshots[s.Tenant1ID].Count, ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetLimitsConditional() {
+	Convey("Given Cinder reports ETag of limits", s.T(), func() {
+		s.LimitsETag = `"limits-1"`
+		defer func() { s.LimitsETag = "" }()
+		provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+		th.AssertNoErr(s.T(), err)
+		dispatch := ServiceV2{}
+
+		Convey("When GetLimits is called without ETag", func() {
+			conditional := &types.ConditionalGet{}
+			limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Conditional: conditional})
+
+			Convey("Then limits are returned with their ETag", func() {
+				So(err, ShouldBeNil)
+				So(limits.MaxTotalVolumes, ShouldEqual, s.MaxTotalVolumes)
+				So(conditional.ETag, ShouldEqual, `"limits-1"`)
+				So(conditional.NotModified, ShouldBeFalse)
+			})
+		})
+
+		Convey("When GetLimits is called with current ETag", func() {
+			conditional := &types.ConditionalGet{ETag: `"limits-1"`}
+			limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Conditional: conditional})
+
+			Convey("Then limits are reported not modified", func() {
+				So(err, ShouldBeNil)
+				So(conditional.NotModified, ShouldBeTrue)
+				So(conditional.ETag, ShouldEqual, `"limits-1"`)
+				So(limits.MaxTotalVolumes, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When GetLimits is called with stale ETag", func() {
+			conditional := &types.ConditionalGet{ETag: `"limits-0"`}
+			limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Conditional: conditional})
+
+			Convey("Then current limits are returned", func() {
+				So(err, ShouldBeNil)
+				So(conditional.NotModified, ShouldBeFalse)
+				So(conditional.ETag, ShouldEqual, `"limits-1"`)
+				So(limits.MaxTotalVolumes, ShouldEqual, s.MaxTotalVolumes)
+			})
+		})
+
+		Convey("When GetLimits is called with current ETag and quota usage is queried", func() {
+			conditional := &types.ConditionalGet{ETag: `"limits-1"`}
+			limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Conditional: conditional, Reserved: true})
+
+			Convey("Then limits are fetched unconditionally", func() {
+				So(err, ShouldBeNil)
+				So(conditional.NotModified, ShouldBeFalse)
+				So(limits.MaxTotalVolumes, ShouldEqual, s.MaxTotalVolumes)
+				So(*limits.VolumesReserved, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetLimitsByType() {
+	Convey("Given Cinder quotas of volume types are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetLimits called with volume types", func() {
+				dispatch := ServiceV2{}
+				opts := types.LimitsOptions{VolumeTypes: []string{"ssd", "hdd", "nvme"}, ByType: map[string]types.TypeLimits{}}
+				_, err := dispatch.GetLimits(provider, opts)
+
+				Convey("Then quotas of volume types are returned", func() {
+					So(err, ShouldBeNil)
+					So(opts.ByType["ssd"].Gigabytes, ShouldEqual, 100)
+					So(opts.ByType["ssd"].GigabytesUsed, ShouldEqual, 80)
+					So(opts.ByType["hdd"].Gigabytes, ShouldEqual, -1)
+					So(opts.ByType["hdd"].GigabytesUsed, ShouldEqual, 500)
+				})
+
+				Convey("and types absent from quota usage are skipped", func() {
+					So(err, ShouldBeNil)
+					_, found := opts.ByType["nvme"]
+					So(found, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetLimitsReserved() {
+	Convey("Given reserved usage of Cinder quotas is requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetLimits called with reserved usage", func() {
+				dispatch := ServiceV2{}
+				limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Reserved: true})
+
+				Convey("Then reserved volumes and gigabytes are returned", func() {
+					So(err, ShouldBeNil)
+					So(limits.VolumesReserved, ShouldNotBeNil)
+					So(*limits.VolumesReserved, ShouldEqual, 2)
+					So(limits.GigabytesReserved, ShouldNotBeNil)
+					So(*limits.GigabytesReserved, ShouldEqual, 0)
+				})
+			})
+
+			Convey("and GetLimits called without reserved usage", func() {
+				dispatch := ServiceV2{}
+				limits, err := dispatch.GetLimits(provider, types.LimitsOptions{})
+
+				Convey("Then reserved usage is not reported", func() {
+					So(err, ShouldBeNil)
+					So(limits.VolumesReserved, ShouldBeNil)
+					So(limits.GigabytesReserved, ShouldBeNil)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetLimitsGroups() {
+	Convey("Given generic volume groups quota is requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetLimits called with groups quota", func() {
+				dispatch := ServiceV2{}
+				limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Groups: true})
+
+				Convey("Then groups quota, its usage and headroom are returned", func() {
+					So(err, ShouldBeNil)
+					So(limits.Groups, ShouldNotBeNil)
+					So(*limits.Groups, ShouldEqual, 10)
+					So(limits.GroupsUsed, ShouldNotBeNil)
+					So(*limits.GroupsUsed, ShouldEqual, 3)
+					So(limits.GroupsRemaining, ShouldNotBeNil)
+					So(*limits.GroupsRemaining, ShouldEqual, 7)
+					So(limits.VolumesReserved, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetLimits called without groups quota", func() {
+				dispatch := ServiceV2{}
+				limits, err := dispatch.GetLimits(provider, types.LimitsOptions{Reserved: true})
+
+				Convey("Then groups quota is not reported", func() {
+					So(err, ShouldBeNil)
+					So(limits.Groups, ShouldBeNil)
+					So(limits.GroupsUsed, ShouldBeNil)
+					So(limits.GroupsRemaining, ShouldBeNil)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetLimitsByTenantID() {
+	Convey("Given limits of tenant are requested by its ID", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetLimits called with tenant ID", func() {
+				dispatch := ServiceV2{}
+				opts := types.LimitsOptions{TenantID: "v2ffff", VolumeTypes: []string{"ssd"}, ByType: map[string]types.TypeLimits{}, Groups: true}
+				limits, err := dispatch.GetLimits(provider, opts)
+
+				Convey("Then limits are read from quota usage of tenant", func() {
+					So(err, ShouldBeNil)
+					So(limits.MaxTotalVolumes, ShouldEqual, 10)
+					So(limits.VolumesUsed, ShouldEqual, 4)
+					So(limits.MaxTotalVolumeGigabytes, ShouldEqual, 1000)
+					So(limits.GigabytesUsed, ShouldEqual, 580)
+					So(opts.ByType["ssd"], ShouldResemble, types.TypeLimits{Gigabytes: 100, GigabytesUsed: 80})
+					So(limits.Groups, ShouldNotBeNil)
+					So(*limits.Groups, ShouldEqual, 10)
+				})
+
+				Convey("and backup quotas not reported in quota usage are left unset", func() {
+					So(limits.Backups, ShouldBeNil)
+					So(limits.BackupGigabytes, ShouldBeNil)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetDefaultQuotas() {
+	Convey("Given Cinder default quotas are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetDefaultQuotas called", func() {
+				dispatch := ServiceV2{}
+				quota, err := dispatch.GetDefaultQuotas(provider)
+
+				Convey("Then default quota class values are returned", func() {
+					So(err, ShouldBeNil)
+					So(*quota.Volumes, ShouldEqual, 10)
+					So(*quota.Gigabytes, ShouldEqual, 1000)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetVolumeGroups() {
+	Convey("Given Cinder volume groups are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetVolumeGroups called", func() {
+				dispatch := ServiceV2{}
+				opts := types.VolumeGroupsOptions{ByStatus: types.Groups{}}
+				volumeGroups, err := dispatch.GetVolumeGroups(provider, opts)
+
+				Convey("Then number of groups and groups quota are returned", func() {
+					So(err, ShouldBeNil)
+					So(*volumeGroups.Count, ShouldEqual, 3)
+					So(*volumeGroups.Quota, ShouldEqual, 10)
+					So(*volumeGroups.QuotaInUse, ShouldEqual, 3)
+				})
+
+				Convey("Then groups are grouped by status", func() {
+					So(opts.ByStatus["status"]["available"].Count, ShouldEqual, 2)
+					So(opts.ByStatus["status"]["error"].Count, ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetQoSSpecs() {
+	Convey("Given Cinder QoS specs are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetQoSSpecs called", func() {
+				dispatch := ServiceV2{}
+				opts := types.QoSSpecsOptions{Associations: map[string]uint{}}
+				qosSpecs, err := dispatch.GetQoSSpecs(provider, opts)
+
+				Convey("Then number of QoS specs is returned", func() {
+					So(err, ShouldBeNil)
+					So(*qosSpecs.Count, ShouldEqual, 2)
+				})
+
+				Convey("Then associations of each spec are counted", func() {
+					So(opts.Associations, ShouldResemble, map[string]uint{"gold": 2, "silver": 0})
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetPools() {
+	Convey("Given Cinder pools are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetPools called", func() {
+				dispatch := ServiceV2{}
+				opts := types.PoolsOptions{OvercommitRatio: map[string]float64{}}
+				pools, err := dispatch.GetPools(provider, opts)
+
+				Convey("Then number of pools is returned", func() {
+					So(err, ShouldBeNil)
+					So(*pools.Count, ShouldEqual, 3)
+				})
+
+				Convey("Then overcommit ratio is set only for pools reporting both capacities", func() {
+					So(opts.OvercommitRatio, ShouldResemble, map[string]float64{"node1@lvm#thin": 2.5})
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetServices() {
+	Convey("Given Cinder services are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetServices called", func() {
+				dispatch := ServiceV2{}
+				heartbeats, err := dispatch.GetServices(provider)
+
+				Convey("Then last heartbeat of each service reporting its state is returned", func() {
+					So(err, ShouldBeNil)
+					So(heartbeats, ShouldResemble, []types.ServiceHeartbeat{
+						{Host: "controller", Binary: "cinder-scheduler", UpdatedAt: time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)},
+						{Host: "node1@lvm", Binary: "cinder-volume", UpdatedAt: time.Date(2016, 10, 1, 11, 58, 30, 123000000, time.UTC)},
+					})
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetVolumeTypes() {
+	Convey("Given Cinder volume types are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetVolumeTypes called", func() {
+				dispatch := ServiceV2{}
+				volumeTypes, err := dispatch.GetVolumeTypes(provider)
+
+				Convey("Then number of accessible volume types is returned", func() {
+					So(err, ShouldBeNil)
+					So(volumeTypes.AccessibleCount, ShouldEqual, 2)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetExtraSpecs() {
+	Convey("Given extra specs of volume types are requested", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+
+			Convey("and GetExtraSpecs called", func() {
+				dispatch := ServiceV2{}
+				extraSpecs, err := dispatch.GetExtraSpecs(provider, types.ExtraSpecsOptions{})
+
+				Convey("Then extra specs of each listed volume type are returned", func() {
+					So(err, ShouldBeNil)
+					So(extraSpecs, ShouldResemble, map[string]map[string]string{
+						"6685584b": {"volume_backend_name": "lvm", "replication": "true"},
+						"8eb69a46": {},
+					})
+				})
+			})
+
+			Convey("and GetExtraSpecs called with known extra specs", func() {
+				dispatch := ServiceV2{}
+				known := map[string]map[string]string{"8eb69a46": {"volume_backend_name": "ceph"}, "removed": {}}
+				extraSpecs, err := dispatch.GetExtraSpecs(provider, types.ExtraSpecsOptions{Known: known})
+
+				Convey("Then known extra specs are reused and types no longer listed are dropped", func() {
+					So(err, ShouldBeNil)
+					So(extraSpecs, ShouldResemble, map[string]map[string]string{
+						"6685584b": {"volume_backend_name": "lvm", "replication": "true"},
+						"8eb69a46": {"volume_backend_name": "ceph"},
+					})
+				})
+			})
 		})
 	})
 }
@@ -97,13 +524,13 @@ func (s *CinderV2Suite) TestGetVolumes() {
 	Convey("Given Cinder volumes are requested", s.T(), func() {
 
 		Convey("When authentication is required", func() {
-			provider, err := openstackintel.Authenticate(th.Endpoint(), "me", "secret", "tenant", "", "")
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
 			th.AssertNoErr(s.T(), err)
 			th.CheckEquals(s.T(), s.Token, provider.TokenID)
 
 			Convey("and GetVolumes called", func() {
 				dispatch := ServiceV2{}
-				volumes, err := dispatch.GetVolumes(provider)
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, ManagedMetadataKey: "managed"})
 
 				Convey("Then proper limits values are returned", func() {
 					So(len(volumes), ShouldEqual, 2)
@@ -111,6 +538,265 @@ func (s *CinderV2Suite) TestGetVolumes() {
 					So(volumes[s.Tenant2ID].Bytes, ShouldEqual, s.Vol2Size*1024*1024*1024)
 					So(volumes[s.Tenant1ID].Count, ShouldEqual, 1)
 					So(volumes[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].AvailableGB, ShouldEqual, s.Vol1Size)
+					So(volumes[s.Tenant1ID].InUseGB, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].InUseGB, ShouldEqual, s.Vol2Size)
+					So(volumes[s.Tenant1ID].InconsistentAttachment, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].InconsistentAttachment, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].Managed, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].Managed, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].Deleting, ShouldEqual, 0)
+					So(volumes[s.Tenant1ID].AvgSizeGB, ShouldEqual, s.Vol1Size)
+					So(volumes[s.Tenant2ID].AvgSizeGB, ShouldEqual, s.Vol2Size)
+					So(volumes[s.Tenant1ID].ErrorPercent, ShouldEqual, 0)
+					So(volumes[s.Tenant1ID].Migrating, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].Migrating, ShouldEqual, 0)
+					So(volumes[s.Tenant1ID].Multiattach, ShouldEqual, 0)
+					So(volumes[s.Tenant1ID].AttachmentsTotal, ShouldEqual, 0)
+					So(volumes[s.Tenant1ID].DistinctHosts, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].DistinctHosts, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].UnencryptedGB, ShouldEqual, s.Vol1Size)
+					So(volumes[s.Tenant1ID].EncryptedGB, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].EncryptedGB, ShouldEqual, s.Vol2Size)
+					So(volumes[s.Tenant2ID].UnknownEncryptionGB, ShouldEqual, 0)
+				})
+
+				Convey("and no error reported", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetVolumes called with deleted volumes included", func() {
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, IncludeDeleted: true})
+
+				Convey("Then deleted volumes not listed as existing are counted only as deleted", func() {
+					So(err, ShouldBeNil)
+					So(volumes[s.Tenant1ID].Deleted, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].Deleted, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Bytes, ShouldEqual, s.Vol2Size*1024*1024*1024)
+				})
+			})
+
+			Convey("and GetVolumes called while owner of volume is reported as project ID", func() {
+				s.VolumesTenantField = "project_id"
+				defer func() { s.VolumesTenantField = "os-vol-tenant-attr:tenant_id" }()
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true})
+
+				Convey("Then volumes are attributed to tenants by either field", func() {
+					So(err, ShouldBeNil)
+					So(len(volumes), ShouldEqual, 2)
+					So(volumes[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(volumes[""].Count, ShouldEqual, 0)
+				})
+			})
+
+			Convey("and GetVolumes called while volume is in error status", func() {
+				s.Vol1Status = "error_extending"
+				defer func() { s.Vol1Status = "available" }()
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true})
+
+				Convey("Then percentage of volumes in error state is returned", func() {
+					So(err, ShouldBeNil)
+					So(volumes[s.Tenant1ID].ErrorPercent, ShouldEqual, 100)
+					So(volumes[s.Tenant2ID].ErrorPercent, ShouldEqual, 0)
+					So(volumes[s.Tenant1ID].Errors, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Errors, ShouldEqual, 0)
+				})
+			})
+
+			Convey("and GetVolumes called while volume is attached to several instances", func() {
+				s.Vol1Attachments = `[{"server_id": "srv1"}, {"server_id": "srv2"}]`
+				defer func() { s.Vol1Attachments = "[]" }()
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true})
+
+				Convey("Then volume is counted as multiattached and its attachments are summed", func() {
+					So(err, ShouldBeNil)
+					So(volumes[s.Tenant1ID].Multiattach, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].AttachmentsTotal, ShouldEqual, 2)
+					So(volumes[s.Tenant2ID].Multiattach, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].AttachmentsTotal, ShouldEqual, 0)
+				})
+			})
+
+			Convey("and GetVolumes called while volume is being migrated", func() {
+				s.Vol1Migstat = `"migrating"`
+				defer func() { s.Vol1Migstat = "null" }()
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true})
+
+				Convey("Then only volumes with migration status other than success are counted as migrating", func() {
+					So(err, ShouldBeNil)
+					So(volumes[s.Tenant1ID].Migrating, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Migrating, ShouldEqual, 0)
+				})
+			})
+
+			Convey("and GetVolumes called with page size while one page fails", func() {
+				s.VolumesPageFailures = 1
+				defer func() { s.VolumesPageFailures = 0 }()
+				retried := 0
+				retryPage := func(list func() error) error {
+					err := list()
+					for ; err != nil && retried < 3; retried++ {
+						err = list()
+					}
+					return err
+				}
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, PageSize: 1, RetryPage: retryPage})
+
+				Convey("Then failed page is retried and listing continues", func() {
+					So(err, ShouldBeNil)
+					So(retried, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(volumes[s.Tenant2ID].Bytes, ShouldEqual, 2*1024*1024*1024)
+				})
+
+				Convey("and volumes not reporting encryption are counted as unknown", func() {
+					So(volumes[s.Tenant2ID].UnknownEncryptionGB, ShouldEqual, 2)
+					So(volumes[s.Tenant2ID].EncryptedGB+volumes[s.Tenant2ID].UnencryptedGB, ShouldEqual, 0)
+				})
+			})
+
+			Convey("and GetVolumes called with page size while pages overlap", func() {
+				s.VolumesPageOverlap = true
+				defer func() { s.VolumesPageOverlap = false }()
+				dispatch := ServiceV2{}
+				extra := map[string]map[string]float64{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, PageSize: 1,
+					ExtraVolumeFields: map[string]string{"size_sum": "size"}, ExtraVolumeValues: extra})
+
+				Convey("Then volume listed on both pages is counted once", func() {
+					So(err, ShouldBeNil)
+					So(volumes[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(volumes[s.Tenant1ID].Bytes, ShouldEqual, 1024*1024*1024)
+					So(volumes[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(extra[s.Tenant1ID]["size_sum"], ShouldEqual, 1)
+					So(extra[s.Tenant2ID]["size_sum"], ShouldEqual, 2)
+				})
+			})
+
+			Convey("and GetVolumes called with page size while marker is ignored", func() {
+				s.MarkerIgnored = true
+				defer func() { s.MarkerIgnored = false }()
+				dispatch := ServiceV2{}
+				_, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, PageSize: 1})
+
+				Convey("Then listing stops with error instead of repeating the same page", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "does not advance")
+				})
+			})
+
+			Convey("and GetVolumes called with page size while page keeps failing", func() {
+				s.VolumesPageFailures = 1
+				defer func() { s.VolumesPageFailures = 0 }()
+				dispatch := ServiceV2{}
+				_, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, PageSize: 1})
+
+				Convey("Then error is returned when retry is not set", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("and GetVolumes called while host of volume is not visible", func() {
+				s.Vol1Host = "null"
+				defer func() { s.Vol1Host = `"rbd:volumes#DEFAULT"` }()
+				dispatch := ServiceV2{}
+				volumes, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true})
+
+				Convey("Then volume is not counted on any host", func() {
+					So(err, ShouldBeNil)
+					So(volumes[s.Tenant1ID].DistinctHosts, ShouldEqual, 0)
+					So(volumes[s.Tenant2ID].DistinctHosts, ShouldEqual, 1)
+				})
+			})
+
+			Convey("and GetVolumes called with volume hosts", func() {
+				dispatch := ServiceV2{}
+				opts := types.ListOptions{AllTenants: true, VolumeHosts: map[string]string{}}
+				_, err := dispatch.GetVolumes(provider, opts)
+
+				Convey("Then back-end host of each volume is recorded", func() {
+					So(err, ShouldBeNil)
+					So(opts.VolumeHosts, ShouldResemble, map[string]string{s.Vol1: "rbd:volumes#DEFAULT", s.Vol2: "rbd:volumes#DEFAULT"})
+				})
+			})
+
+			Convey("and GetVolumes called with status filter", func() {
+				dispatch := ServiceV2{}
+				_, err := dispatch.GetVolumes(provider, types.ListOptions{AllTenants: true, VolumeStatus: "deleting"})
+
+				Convey("Then status filter is sent", func() {
+					So(s.VolumesStatus, ShouldEqual, "deleting")
+				})
+
+				Convey("and no error reported", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetVolumes called with extra fields", func() {
+				dispatch := ServiceV2{}
+				opts := types.ListOptions{
+					AllTenants:        true,
+					ExtraVolumeFields: map[string]string{"size": "size", "migstat": "os-vol-mig-status-attr:migstat", "missing": "a.b"},
+					ExtraVolumeValues: map[string]map[string]float64{},
+				}
+				_, err := dispatch.GetVolumes(provider, opts)
+
+				Convey("Then numeric fields are summed per tenant", func() {
+					So(opts.ExtraVolumeValues[s.Tenant1ID]["size"], ShouldEqual, s.Vol1Size)
+					So(opts.ExtraVolumeValues[s.Tenant2ID]["size"], ShouldEqual, s.Vol2Size)
+				})
+
+				Convey("and non numeric or missing fields are skipped", func() {
+					_, found := opts.ExtraVolumeValues[s.Tenant1ID]["migstat"]
+					So(found, ShouldBeFalse)
+					_, found = opts.ExtraVolumeValues[s.Tenant1ID]["missing"]
+					So(found, ShouldBeFalse)
+				})
+
+				Convey("and no error reported", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetVolumes called with several group-by fields", func() {
+				dispatch := ServiceV2{}
+				opts := types.ListOptions{
+					AllTenants:     true,
+					GroupVolumesBy: []string{"status", "availability_zone", "volume_type"},
+					VolumeGroups:   map[string]types.Groups{},
+				}
+				_, err := dispatch.GetVolumes(provider, opts)
+
+				Convey("Then volumes are grouped by each field per tenant in single listing", func() {
+					So(opts.VolumeGroups[s.Tenant1ID]["status"]["available"], ShouldResemble, types.Group{Count: 1, Gigabytes: s.Vol1Size})
+					So(opts.VolumeGroups[s.Tenant2ID]["availability_zone"]["nova"], ShouldResemble, types.Group{Count: 1, Gigabytes: s.Vol2Size})
+					So(opts.VolumeGroups[s.Tenant1ID]["volume_type"][types.UnsetMetadataValue].Count, ShouldEqual, 1)
+					_, found := opts.VolumeGroups[s.Tenant1ID]["bootable"]
+					So(found, ShouldBeFalse)
+				})
+
+				Convey("and no error reported", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetVolumes called without all tenants visibility", func() {
+				dispatch := ServiceV2{}
+				_, err := dispatch.GetVolumes(provider, types.ListOptions{})
+
+				Convey("Then all_tenants filter is not sent", func() {
+					So(s.VolumesAllTenants, ShouldEqual, "")
 				})
 
 				Convey("and no error reported", func() {
@@ -125,18 +811,172 @@ func (s *CinderV2Suite) TestGetSnapshots() {
 	Convey("Given Cinder snapshots are requested", s.T(), func() {
 
 		Convey("When authentication is required", func() {
-			provider, err := openstackintel.Authenticate(th.Endpoint(), "me", "secret", "tenant", "", "")
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
 			th.AssertNoErr(s.T(), err)
 			th.CheckEquals(s.T(), s.Token, provider.TokenID)
 
 			Convey("and GetSnapshots called", func() {
 				dispatch := ServiceV2{}
-				snapshots, err := dispatch.GetSnapshots(provider)
+				snapshots, err := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true})
 
 				Convey("Then proper limits values are returned", func() {
 					So(len(snapshots), ShouldEqual, 1)
 					So(snapshots[s.Tenant1ID].Count, ShouldEqual, 1)
 					So(snapshots[s.Tenant1ID].Bytes, ShouldEqual, s.SnapShotSize*1024*1024*1024)
+					So(snapshots[s.Tenant1ID].Orphaned, ShouldEqual, -1)
+				})
+
+				Convey("and no error reported", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetSnapshots called with deleted snapshots included", func() {
+				dispatch := ServiceV2{}
+				snapshots, err := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, IncludeDeleted: true})
+
+				Convey("Then deleted snapshots not listed as existing are counted only as deleted", func() {
+					So(err, ShouldBeNil)
+					So(snapshots[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(snapshots[s.Tenant1ID].Deleted, ShouldEqual, 0)
+					So(snapshots[s.Tenant2ID].Count, ShouldEqual, 0)
+					So(snapshots[s.Tenant2ID].Deleted, ShouldEqual, 1)
+					So(snapshots[s.Tenant2ID].Orphaned, ShouldEqual, -1)
+				})
+			})
+
+			Convey("and GetSnapshots called with metadata keys", func() {
+				dispatch := ServiceV2{}
+				opts := types.ListOptions{
+					AllTenants:             true,
+					SnapshotMetadataKeys:   []string{"backup", "job"},
+					SnapshotMetadataCounts: map[string]map[string]map[string]uint{},
+				}
+				_, err := dispatch.GetSnapshots(provider, opts)
+
+				Convey("Then snapshots are counted by metadata values", func() {
+					So(err, ShouldBeNil)
+					So(opts.SnapshotMetadataCounts[s.Tenant1ID]["backup"]["forced"], ShouldEqual, 1)
+					So(opts.SnapshotMetadataCounts[s.Tenant1ID]["job"][types.UnsetMetadataValue], ShouldEqual, 1)
+				})
+			})
+
+			Convey("and GetSnapshots called with set of existing volumes", func() {
+				dispatch := ServiceV2{}
+				orphaned, err1 := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, VolumeIDs: map[string]bool{s.Vol1: true}})
+				attached, err2 := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, VolumeIDs: map[string]bool{"495a1698-ca2f-4e84-8d34-fa544c65ae3d": true}})
+
+				Convey("Then snapshots of missing volumes are counted as orphaned", func() {
+					So(orphaned[s.Tenant1ID].Orphaned, ShouldEqual, 1)
+					So(attached[s.Tenant1ID].Orphaned, ShouldEqual, 0)
+				})
+
+				Convey("and no error reported", func() {
+					So(err1, ShouldBeNil)
+					So(err2, ShouldBeNil)
+				})
+			})
+
+			Convey("and GetSnapshots called with volume hosts", func() {
+				dispatch := ServiceV2{}
+				opts := types.ListOptions{
+					AllTenants:         true,
+					VolumeHosts:        map[string]string{"495a1698-ca2f-4e84-8d34-fa544c65ae3d": "node1@lvm", s.Vol1: "node2@ceph"},
+					SnapshotHostCounts: map[string]uint{},
+				}
+				_, err := dispatch.GetSnapshots(provider, opts)
+
+				Convey("Then snapshots are counted by host of their volume and hosts without snapshots are counted with 0", func() {
+					So(err, ShouldBeNil)
+					So(opts.SnapshotHostCounts, ShouldResemble, map[string]uint{"node1@lvm": 1, "node2@ceph": 0})
+				})
+			})
+
+			Convey("and GetSnapshots called with page size while one page fails", func() {
+				s.SnapshotsPageFailures = 1
+				defer func() { s.SnapshotsPageFailures = 0 }()
+				retried := 0
+				retryPage := func(list func() error) error {
+					err := list()
+					for ; err != nil && retried < 3; retried++ {
+						err = list()
+					}
+					return err
+				}
+				dispatch := ServiceV2{}
+				snapshots, err := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, PageSize: 1, RetryPage: retryPage})
+
+				Convey("Then failed page is retried and snapshots of all pages are counted", func() {
+					So(err, ShouldBeNil)
+					So(retried, ShouldEqual, 1)
+					So(snapshots[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(snapshots[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(snapshots[s.Tenant2ID].Bytes, ShouldEqual, 2*1024*1024*1024)
+				})
+			})
+
+			Convey("and GetSnapshots called with page size while marker is ignored", func() {
+				s.MarkerIgnored = true
+				defer func() { s.MarkerIgnored = false }()
+				dispatch := ServiceV2{}
+				_, err := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, PageSize: 1})
+
+				Convey("Then listing stops with error instead of repeating the same page", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "does not advance")
+				})
+			})
+
+			Convey("and GetSnapshots called with page size while pages overlap", func() {
+				s.SnapshotsPageOverlap = true
+				defer func() { s.SnapshotsPageOverlap = false }()
+				dispatch := ServiceV2{}
+				opts := types.ListOptions{
+					AllTenants:             true,
+					PageSize:               1,
+					SnapshotMetadataKeys:   []string{"backup"},
+					SnapshotMetadataCounts: map[string]map[string]map[string]uint{},
+				}
+				snapshots, err := dispatch.GetSnapshots(provider, opts)
+
+				Convey("Then snapshot listed on both pages is counted once", func() {
+					So(err, ShouldBeNil)
+					So(snapshots[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(snapshots[s.Tenant1ID].Bytes, ShouldEqual, 1024*1024*1024)
+					So(snapshots[s.Tenant2ID].Count, ShouldEqual, 1)
+					So(opts.SnapshotMetadataCounts[s.Tenant1ID]["backup"][types.UnsetMetadataValue], ShouldEqual, 1)
+					So(opts.SnapshotMetadataCounts[s.Tenant2ID]["backup"][types.UnsetMetadataValue], ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *CinderV2Suite) TestGetSnapshotsIncremental() {
+	Convey("Given Cinder snapshots are requested incrementally", s.T(), func() {
+
+		Convey("When authentication is required", func() {
+			provider, err := openstackintel.Authenticate(openstackintel.AuthOpts{Endpoint: th.Endpoint(), User: "me", Password: "secret", Tenant: "tenant"})
+			th.AssertNoErr(s.T(), err)
+			th.CheckEquals(s.T(), s.Token, provider.TokenID)
+
+			Convey("and GetSnapshots called twice with the same index", func() {
+				dispatch := ServiceV2{}
+				idx := types.NewSnapshotIndex()
+				_, err := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, Snapshots: idx})
+				th.AssertNoErr(s.T(), err)
+				So(s.SnapshotsChangesSince, ShouldBeEmpty)
+
+				snapshots, err := dispatch.GetSnapshots(provider, types.ListOptions{AllTenants: true, Snapshots: idx})
+
+				Convey("Then changes-since filter is sent", func() {
+					So(s.SnapshotsChangesSince, ShouldNotBeEmpty)
+				})
+
+				Convey("and snapshots returned again are not counted twice", func() {
+					So(len(snapshots), ShouldEqual, 1)
+					So(snapshots[s.Tenant1ID].Count, ShouldEqual, 1)
+					So(snapshots[s.Tenant1ID].Bytes, ShouldEqual, s.SnapShotSize*1024*1024*1024)
 				})
 
 				Convey("and no error reported", func() {
@@ -245,6 +1085,13 @@ func registerLimits(s *CinderV2Suite) {
 	s.MaxTotalVolumeGigabytes = 1000
 	s.MaxTotalVolumes = 10
 	th.Mux.HandleFunc(s.LimitsV2, func(w http.ResponseWriter, r *http.Request) {
+		if s.LimitsETag != "" {
+			w.Header().Set("ETag", s.LimitsETag)
+			if r.Header.Get("If-None-Match") == s.LimitsETag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
 		fmt.Fprintf(w, `
 				{
 					"limits": {
@@ -265,12 +1112,193 @@ func registerLimits(s *CinderV2Suite) {
 				}
 			`, s.MaxTotalVolumeGigabytes, s.MaxTotalVolumes)
 	})
+	th.Mux.HandleFunc("/"+s.V2+"/types", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `
+				{
+					"volume_types": [
+						{"id": "6685584b", "name": "ssd", "os-volume-type-access:is_public": true},
+						{"id": "8eb69a46", "name": "gold", "os-volume-type-access:is_public": false}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/types/6685584b/extra_specs", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `{"extra_specs": {"volume_backend_name": "lvm", "replication": "true"}}`)
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/types/8eb69a46/extra_specs", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `{"extra_specs": {}}`)
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/os-quota-class-sets/default", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `
+				{
+					"quota_class_set": {
+						"id": "default",
+						"gigabytes": 1000,
+						"volumes": 10
+					}
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/"+s.V2+"/os-quota-sets/v2ffff", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		th.TestFormValues(s.T(), r, map[string]string{"usage": "true"})
+		fmt.Fprintf(w, `
+				{
+					"quota_set": {
+						"id": "v2ffff",
+						"volumes": {"in_use": 4, "limit": 10, "reserved": 2},
+						"gigabytes": {"in_use": 580, "limit": 1000, "reserved": 0},
+						"gigabytes_ssd": {"in_use": 80, "limit": 100, "reserved": 0},
+						"gigabytes_hdd": {"in_use": 500, "limit": -1, "reserved": 0},
+						"groups": {"in_use": 3, "limit": 10, "reserved": 0}
+					}
+				}
+			`)
+	})
+}
+
+func registerVolumeGroups(s *CinderV2Suite) {
+	th.Mux.HandleFunc("/v3/v2ffff/groups/detail", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		th.TestHeader(s.T(), r, "OpenStack-API-Version", "volume 3.13")
+		fmt.Fprintf(w, `
+				{
+					"groups": [
+						{"id": "grp1", "name": "group1", "status": "available"},
+						{"id": "grp2", "name": "group2", "status": "available"},
+						{"id": "grp3", "name": "group3", "status": "error"}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/v3/v2ffff/os-quota-sets/v2ffff", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		th.TestFormValues(s.T(), r, map[string]string{"usage": "true"})
+		fmt.Fprintf(w, `
+				{
+					"quota_set": {
+						"id": "v2ffff",
+						"groups": {"in_use": 3, "limit": 10, "reserved": 0}
+					}
+				}
+			`)
+	})
+}
+
+func registerQoSSpecs(s *CinderV2Suite) {
+	th.Mux.HandleFunc("/v2/v2ffff/qos-specs", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `
+				{
+					"qos_specs": [
+						{"id": "qos1", "name": "gold", "consumer": "back-end", "specs": {"total_iops_sec": "1000"}},
+						{"id": "qos2", "name": "silver", "consumer": "front-end", "specs": {"total_iops_sec": "500"}}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/v2/v2ffff/qos-specs/qos1/associations", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `
+				{
+					"qos_associations": [
+						{"association_type": "volume_type", "name": "ssd", "id": "type1"},
+						{"association_type": "volume_type", "name": "nvme", "id": "type2"}
+					]
+				}
+			`)
+	})
+	th.Mux.HandleFunc("/v2/v2ffff/qos-specs/qos2/associations", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `{"qos_associations": []}`)
+	})
+}
+
+func registerPools(s *CinderV2Suite) {
+	th.Mux.HandleFunc("/v2/v2ffff/scheduler-stats/get_pools", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		th.TestFormValues(s.T(), r, map[string]string{"detail": "true"})
+		fmt.Fprintf(w, `
+				{
+					"pools": [
+						{"name": "node1@lvm#thin", "capabilities": {"total_capacity_gb": 100, "provisioned_capacity_gb": 250, "free_capacity_gb": 40}},
+						{"name": "node1@lvm#thick", "capabilities": {"total_capacity_gb": 200, "free_capacity_gb": 150}},
+						{"name": "node2@ceph#rbd", "capabilities": {"total_capacity_gb": "infinite", "provisioned_capacity_gb": 30}}
+					]
+				}
+			`)
+	})
+}
+
+func registerServices(s *CinderV2Suite) {
+	th.Mux.HandleFunc("/v2/v2ffff/os-services", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(s.T(), r, "GET")
+		fmt.Fprintf(w, `
+				{
+					"services": [
+						{"binary": "cinder-scheduler", "host": "controller", "zone": "nova", "status": "enabled", "state": "up", "updated_at": "2016-10-01T12:00:00.000000"},
+						{"binary": "cinder-volume", "host": "node1@lvm", "zone": "nova", "status": "enabled", "state": "down", "updated_at": "2016-10-01T11:58:30.123000"},
+						{"binary": "cinder-backup", "host": "controller", "zone": "nova", "status": "disabled", "state": "down", "updated_at": null}
+					]
+				}
+			`)
+	})
 }
 
 func registerVolumes(s *CinderV2Suite) {
 	url := "/v2/v2ffff/volumes/detail" //?all_tenants=true
 	th.Mux.HandleFunc(url, func(w http.ResponseWriter, r *http.Request) {
-		th.TestFormValues(s.T(), r, map[string]string{"all_tenants": "true"})
+		// deleted listing returns second volume again, as if it was deleted while volumes were listed
+		if r.URL.Query().Get("deleted") != "" {
+			th.TestFormValues(s.T(), r, map[string]string{"all_tenants": "true", "deleted": "true"})
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"volumes": [{"id": "%s", "os-vol-tenant-attr:tenant_id": "%s", "size": 2, "status": "deleted"},
+				{"id": "vol3dddd", "os-vol-tenant-attr:tenant_id": "%s", "size": 3, "status": "deleted"}]}`, s.Vol2, s.Tenant2ID, s.Tenant2ID)
+			return
+		}
+		// paginated listing returns single volume per page, page following first volume fails given number of times
+		if r.URL.Query().Get("limit") != "" {
+			marker := r.URL.Query().Get("marker")
+			values := map[string]string{"all_tenants": "true", "limit": "1"}
+			if marker != "" {
+				values["marker"] = marker
+			}
+			th.TestFormValues(s.T(), r, values)
+			// server not supporting pagination returns first page again
+			if s.MarkerIgnored {
+				marker = ""
+			}
+			volumes := map[string]string{"": `{"id": "` + s.Vol1 + `", "os-vol-tenant-attr:tenant_id": "` + s.Tenant1ID + `", "size": 1, "status": "available"}`,
+				s.Vol1: `{"id": "` + s.Vol2 + `", "os-vol-tenant-attr:tenant_id": "` + s.Tenant2ID + `", "size": 2, "status": "available"}`}
+			// page following first volume starts with it again, as if volume listed before it was deleted meanwhile
+			if marker == s.Vol1 && s.VolumesPageOverlap {
+				volumes[marker] = volumes[""] + ", " + volumes[marker]
+			}
+			if marker == s.Vol1 && s.VolumesPageFailures > 0 {
+				s.VolumesPageFailures--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"volumes": [%s]}`, volumes[marker])
+			return
+		}
+		s.VolumesStatus = r.URL.Query().Get("status")
+		s.VolumesAllTenants = r.URL.Query().Get("all_tenants")
+		values := map[string]string{}
+		if s.VolumesAllTenants != "" {
+			values["all_tenants"] = s.VolumesAllTenants
+		}
+		if s.VolumesStatus != "" {
+			values["status"] = s.VolumesStatus
+		}
+		th.TestFormValues(s.T(), r, values)
 		th.TestMethod(s.T(), r, "GET")
 		th.TestHeader(s.T(), r, "X-Auth-Token", s.Token)
 		w.Header().Add("Content-Type", "application/json")
@@ -280,7 +1308,7 @@ func registerVolumes(s *CinderV2Suite) {
 			{
 				"volumes": [
 					{
-						"attachments": [],
+						"attachments": %s,
 						"availability_zone": "nova",
 						"bootable": "true",
 						"consistencygroup_id": null,
@@ -301,8 +1329,8 @@ func registerVolumes(s *CinderV2Suite) {
 						"metadata": {},
 						"multiattach": false,
 						"name": "test_tenant_volume",
-						"os-vol-host-attr:host": "rbd:volumes#DEFAULT",
-						"os-vol-mig-status-attr:migstat": null,
+						"os-vol-host-attr:host": %s,
+						"os-vol-mig-status-attr:migstat": %s,
 						"os-vol-mig-status-attr:name_id": null,
 						"os-vol-tenant-attr:tenant_id": "%s",
 						"os-volume-replication:driver_data": null,
@@ -311,7 +1339,7 @@ func registerVolumes(s *CinderV2Suite) {
 						"size": %d,
 						"snapshot_id": null,
 						"source_volid": null,
-						"status": "available",
+						"status": "%s",
 						"user_id": "a3edd7a918fc4373981051c975295dc8",
 						"volume_image_metadata": {
 							"checksum": "ee1eca47dc88f4879d8a229cc70a07c6",
@@ -332,7 +1360,7 @@ func registerVolumes(s *CinderV2Suite) {
 						"consistencygroup_id": null,
 						"created_at": "2016-02-09T15:24:27.000000",
 						"description": null,
-						"encrypted": false,
+						"encrypted": true,
 						"id": "%s",
 						"links": [
 							{
@@ -344,20 +1372,21 @@ func registerVolumes(s *CinderV2Suite) {
 								"rel": "bookmark"
 							}
 						],
-						"metadata": {},
+						"metadata": {"managed": "true"},
 						"multiattach": false,
 						"name": "test-volume",
 						"os-vol-host-attr:host": "rbd:volumes#DEFAULT",
 						"os-vol-mig-status-attr:migstat": null,
 						"os-vol-mig-status-attr:name_id": null,
-						"os-vol-tenant-attr:tenant_id": "%s",
+						"migration_status": "success",
+						"%s": "%s",
 						"os-volume-replication:driver_data": null,
 						"os-volume-replication:extended_status": null,
 						"replication_status": "disabled",
 						"size": %d,
 						"snapshot_id": null,
 						"source_volid": null,
-						"status": "available",
+						"status": "in-use",
 						"user_id": "a3edd7a918fc4373981051c975295dc8",
 						"volume_image_metadata": {
 							"checksum": "ee1eca47dc88f4879d8a229cc70a07c6",
@@ -373,7 +1402,7 @@ func registerVolumes(s *CinderV2Suite) {
 					}
     			]
        		 }
-		`, s.Vol1, s.Tenant1ID, s.Vol1Size, s.Vol2, s.Tenant2ID, s.Vol2Size)
+		`, s.Vol1Attachments, s.Vol1, s.Vol1Host, s.Vol1Migstat, s.Tenant1ID, s.Vol1Size, s.Vol1Status, s.Vol2, s.VolumesTenantField, s.Tenant2ID, s.Vol2Size)
 	})
 }
 
@@ -382,7 +1411,52 @@ func registerSnapshots(s *CinderV2Suite) {
 	th.Mux.HandleFunc(snapshots, func(w http.ResponseWriter, r *http.Request) {
 		th.TestMethod(s.T(), r, "GET")
 		th.TestHeader(s.T(), r, "X-Auth-Token", s.Token)
-		th.TestFormValues(s.T(), r, map[string]string{"all_tenants": "true"})
+		if r.FormValue("deleted") != "" {
+			th.TestFormValues(s.T(), r, map[string]string{"all_tenants": "true", "deleted": "true"})
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"snapshots": [{"id": "snap1cccc", "os-extended-snapshot-attributes:project_id": "%s", "size": 1, "status": "deleted"},
+				{"id": "snap2cccc", "os-extended-snapshot-attributes:project_id": "%s", "size": 1, "status": "deleted"}]}`, s.Tenant1ID, s.Tenant2ID)
+			return
+		}
+		// paginated listing returns single snapshot per page, page following first snapshot fails given number of times
+		if r.URL.Query().Get("limit") != "" {
+			marker := r.URL.Query().Get("marker")
+			values := map[string]string{"all_tenants": "true", "limit": "1"}
+			if marker != "" {
+				values["marker"] = marker
+			}
+			th.TestFormValues(s.T(), r, values)
+			if s.MarkerIgnored {
+				marker = ""
+			}
+			snapshots := map[string]string{"": `{"id": "snap1cccc", "os-extended-snapshot-attributes:project_id": "` + s.Tenant1ID + `", "size": 1, "status": "available"}`,
+				"snap1cccc": `{"id": "snap2cccc", "os-extended-snapshot-attributes:project_id": "` + s.Tenant2ID + `", "size": 2, "status": "available"}`}
+			// page following first snapshot starts with it again, as if snapshot listed before it was deleted meanwhile
+			if marker == "snap1cccc" && s.SnapshotsPageOverlap {
+				snapshots[marker] = snapshots[""] + ", " + snapshots[marker]
+			}
+			if marker == "snap1cccc" && s.SnapshotsPageFailures > 0 {
+				s.SnapshotsPageFailures--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"snapshots": [%s]}`, snapshots[marker])
+			return
+		}
+		s.SnapshotsChangesSince = r.FormValue("changes-since")
+		values := map[string]string{"all_tenants": "true"}
+		if s.SnapshotsChangesSince != "" {
+			values["changes-since"] = s.SnapshotsChangesSince
+			s.ChangesSinceRequests++
+			if s.ChangesSinceRejected {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		th.TestFormValues(s.T(), r, values)
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
@@ -393,7 +1467,7 @@ func registerSnapshots(s *CinderV2Suite) {
 						"created_at": "2016-02-21T19:59:15.000000",
 						"description": "description",
 						"id": "snap1cccc",
-						"metadata": {},
+						"metadata": {"backup": "forced"},
 						"name": "snapshot_1",
 						"os-extended-snapshot-attributes:progress": "100",
             			"os-extended-snapshot-attributes:project_id": "%s",
@@ -32,6 +32,11 @@ specific language governing permissions and limitations under the License.
 // Package contains code from Rackspace Gophercloud (https://github.com/rackspace/gophercloud) with following changes:
 // - structure ListOpts:
 //   - added AllTenants field
+//   - added ProjectID field
+//   - added ChangesSince field
+//   - added Deleted field
+//   - added Limit field
+//   - added Marker field
 package snapshots
 
 import (
@@ -48,10 +53,15 @@ type ListOptsBuilder interface {
 // ListOpts hold options for listing Snapshots. It is passed to the
 // snapshots.List function.
 type ListOpts struct {
-	Name       string `q:"display_name"`
-	Status     string `q:"status"`
-	VolumeID   string `q:"volume_id"`
-	AllTenants bool   `q:"all_tenants"`
+	Name         string `q:"display_name"`
+	Status       string `q:"status"`
+	VolumeID     string `q:"volume_id"`
+	AllTenants   bool   `q:"all_tenants"`
+	ProjectID    string `q:"project_id"`
+	ChangesSince string `q:"changes-since"`
+	Deleted      bool   `q:"deleted"`
+	Limit        int    `q:"limit"`
+	Marker       string `q:"marker"`
 }
 
 // ToSnapshotListQuery formats a ListOpts into a query string.
@@ -29,7 +29,12 @@ CONDITIONS OF ANY KIND, either express or implied.  See the License for the
 specific language governing permissions and limitations under the License.
 */
 
-// Package contains code from Rackspace Gophercloud (https://github.com/rackspace/gophercloud)
+// Package contains code from Rackspace Gophercloud (https://github.com/rackspace/gophercloud) with following changes:
+// - structure ListOpts:
+//   - added Limit field
+//   - added Marker field
+//   - added ProjectID field
+//   - added Deleted field
 package volumes
 
 import (
@@ -57,12 +62,20 @@ func (opts ListOpts) ToVolumeListQuery() (string, error) {
 type ListOpts struct {
 	// admin-only option. Set it to true to see all tenant volumes.
 	AllTenants bool `q:"all_tenants"`
+	// admin-only option. List only volumes of project with ID of ProjectID, used together with AllTenants.
+	ProjectID string `q:"project_id"`
 	// List only volumes that contain Metadata.
 	Metadata map[string]string `q:"metadata"`
 	// List only volumes that have Name as the display name.
 	Name string `q:"name"`
 	// List only volumes that have a status of Status.
 	Status string `q:"status"`
+	// Maximal number of volumes listed in single page.
+	Limit int `q:"limit"`
+	// List only volumes following volume with ID of Marker.
+	Marker string `q:"marker"`
+	// admin-only option. Set it to true to list deleted volumes still retained by Cinder instead of existing ones.
+	Deleted bool `q:"deleted"`
 }
 
 // List returns Volumes optionally limited by the conditions provided in ListOpts.
@@ -30,6 +30,10 @@ specific language governing permissions and limitations under the License.
 */
 
 // Package contains code from Rackspace Gophercloud (https://github.com/rackspace/gophercloud) with following changes:
+// - added ExtractRawVolumes function
+// - added TenantID method
+// - added MigrationState method
+// - changed Encrypted field to pointer, so volumes not reporting encryption are told apart
 // - Volume structure:
 //   - changed field order
 //   - added VolImageMeta field
@@ -40,6 +44,8 @@ specific language governing permissions and limitations under the License.
 //   - added OsVolTenantAttrTenantID field
 //   - added OsVolumeReplicationDriverData field
 //   - added OsVolumeReplicationExtendedStatus field
+//   - added ProjectID field
+//   - added MigrationStatus field
 package volumes
 
 import (
@@ -95,8 +101,8 @@ type Volume struct {
 	// User ID
 	UserID string `mapstructure:"user_id"`
 
-	// If true volume is encrypted
-	Encrypted bool `json:"encrypted" mapstructure:"encrypted"`
+	// If true volume is encrypted, nil when not reported
+	Encrypted *bool `json:"encrypted" mapstructure:"encrypted"`
 
 	// Volume links
 	Links []map[string]interface{} `json:"links" mapstructure:"links"`
@@ -127,6 +133,29 @@ type Volume struct {
 
 	// The volume replication status
 	ReplicationStatus string `json:"replication_status" mapstructure:"replication_status"`
+
+	// The project ID which the volume belongs to, reported instead of tenant ID by some microversions
+	ProjectID string `json:"project_id" mapstructure:"project_id"`
+
+	// The status of this volume migration, reported instead of migstat by some microversions
+	MigrationStatus string `json:"migration_status" mapstructure:"migration_status"`
+}
+
+// TenantID returns ID of tenant owning the volume, falling back to project ID when tenant ID is not reported
+func (v Volume) TenantID() string {
+	if v.OsVolTenantAttrTenantID != "" {
+		return v.OsVolTenantAttrTenantID
+	}
+	return v.ProjectID
+}
+
+// MigrationState returns migration status of the volume, falling back to migration status reported by newer
+// microversions, empty when volume was never migrated or status is not visible with scoped role
+func (v Volume) MigrationState() string {
+	if v.OsVolMigStatusAttrMigstat != "" {
+		return v.OsVolMigStatusAttrMigstat
+	}
+	return v.MigrationStatus
 }
 
 // GetResult contains the response body and error from a Get request.
@@ -159,6 +188,18 @@ func ExtractVolumes(page pagination.Page) ([]Volume, error) {
 	return response.Volumes, err
 }
 
+// ExtractRawVolumes extracts and returns volumes as decoded JSON objects, so fields not defined in Volume
+// (eg. added by vendor extensions) are available. Volumes are in the same order as returned by ExtractVolumes.
+func ExtractRawVolumes(page pagination.Page) ([]map[string]interface{}, error) {
+	var response struct {
+		Volumes []map[string]interface{} `mapstructure:"volumes"`
+	}
+
+	err := mapstructure.Decode(page.(ListResult).Body, &response)
+
+	return response.Volumes, err
+}
+
 // Extract will get the Volume object out of the commonResult object.
 func (r commonResult) Extract() (*Volume, error) {
 	if r.Err != nil {
@@ -0,0 +1,38 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v3
+
+import (
+	"strings"
+
+	"github.com/rackspace/gophercloud"
+)
+
+// NewBlockStorageV3 creates a ServiceClient that may be used with Block Storage API v3 packages.
+// Clouds not registering volumev3 service in catalog are reached at v3 counterpart of volumev2 endpoint.
+func NewBlockStorageV3(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (*gophercloud.ServiceClient, error) {
+	v3 := eo
+	v3.ApplyDefaults("volumev3")
+	url, err := client.EndpointLocator(v3)
+	if err != nil {
+		eo.ApplyDefaults("volumev2")
+		v2, e := client.EndpointLocator(eo)
+		if e != nil || !strings.Contains(v2, "/v2/") {
+			return nil, err
+		}
+		url = strings.Replace(v2, "/v2/", "/v3/", 1)
+	}
+	return &gophercloud.ServiceClient{ProviderClient: client, Endpoint: url}, nil
+}
@@ -0,0 +1,36 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// requests contains Block Storage API v3 requests for generic volume groups
+
+package volumegroups
+
+import (
+	"github.com/rackspace/gophercloud"
+	"github.com/rackspace/gophercloud/pagination"
+)
+
+// Microversion is minimal Block Storage API microversion supporting generic volume groups
+const Microversion = "3.13"
+
+// List returns generic volume groups of tenant which client is scoped to
+func List(client *gophercloud.ServiceClient) pagination.Pager {
+	createPage := func(r pagination.PageResult) pagination.Page {
+		return ListResult{pagination.SinglePageBase(r)}
+	}
+
+	pager := pagination.NewPager(client, listURL(client), createPage)
+	pager.Headers = map[string]string{"OpenStack-API-Version": "volume " + Microversion}
+	return pager
+}
@@ -0,0 +1,53 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Block Storage API v3 responses and their processing for generic volume groups
+
+package volumegroups
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud/pagination"
+)
+
+// VolumeGroup contains information associated with generic volume group
+type VolumeGroup struct {
+	ID     string `mapstructure:"id"`
+	Name   string `mapstructure:"name"`
+	Status string `mapstructure:"status"`
+}
+
+// ListResult is a pagination.Page that is returned from a call to the List function.
+type ListResult struct {
+	pagination.SinglePageBase
+}
+
+// IsEmpty returns true if a ListResult contains no generic volume groups.
+func (r ListResult) IsEmpty() (bool, error) {
+	groups, err := ExtractVolumeGroups(r)
+	if err != nil {
+		return true, err
+	}
+	return len(groups) == 0, nil
+}
+
+// ExtractVolumeGroups extracts and returns generic volume groups. It is used while iterating over a volumegroups.List call.
+func ExtractVolumeGroups(page pagination.Page) ([]VolumeGroup, error) {
+	var response struct {
+		Groups []VolumeGroup `mapstructure:"groups"`
+	}
+
+	err := mapstructure.Decode(page.(ListResult).Body, &response)
+	return response.Groups, err
+}
@@ -0,0 +1,37 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// requests contains Cinder API requests for volume types
+
+package volumetypes
+
+import (
+	"github.com/rackspace/gophercloud"
+)
+
+// List prepares http GET call listing volume types accessible in scope of client
+func List(client *gophercloud.ServiceClient) ListResult {
+	var res ListResult
+	_, err := client.Get(client.ServiceURL("types"), &res.Body, nil)
+	res.Err = err
+	return res
+}
+
+// GetExtraSpecs prepares http GET call for extra specs of volume type of given ID, it requires admin role
+func GetExtraSpecs(client *gophercloud.ServiceClient, id string) ExtraSpecsResult {
+	var res ExtraSpecsResult
+	_, err := client.Get(client.ServiceURL("types", id, "extra_specs"), &res.Body, nil)
+	res.Err = err
+	return res
+}
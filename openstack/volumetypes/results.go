@@ -0,0 +1,67 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// results contains Cinder API responses and their processing for volume types
+
+package volumetypes
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/rackspace/gophercloud"
+)
+
+// VolumeType contains information associated with Cinder volume type
+type VolumeType struct {
+	ID       string `mapstructure:"id"`
+	Name     string `mapstructure:"name"`
+	IsPublic bool   `mapstructure:"os-volume-type-access:is_public"`
+}
+
+// ListResult contains the response body and error from a List request
+type ListResult struct {
+	gophercloud.Result
+}
+
+// Extract will get volume types out of the ListResult object
+func (r ListResult) Extract() ([]VolumeType, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		VolumeTypes []VolumeType `mapstructure:"volume_types"`
+	}
+
+	err := mapstructure.Decode(r.Body, &res)
+	return res.VolumeTypes, err
+}
+
+// ExtraSpecsResult contains the response body and error from a GetExtraSpecs request
+type ExtraSpecsResult struct {
+	gophercloud.Result
+}
+
+// Extract will get extra specs by key out of the ExtraSpecsResult object
+func (r ExtraSpecsResult) Extract() (map[string]string, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var res struct {
+		ExtraSpecs map[string]string `mapstructure:"extra_specs"`
+	}
+	err := mapstructure.Decode(r.Body, &res)
+
+	return res.ExtraSpecs, err
+}
@@ -0,0 +1,129 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import "time"
+
+// Tenants holds cloud-wide summary of tenants
+// Count - number of discovered tenants
+// OverQuota - number of tenants which reached volumes or gigabytes quota, nil when limits were not collected
+type Tenants struct {
+	Count     uint  `json:"count"`
+	OverQuota *uint `json:"over_quota"`
+}
+
+// CloudVolumes holds cloud-wide rollup of volumes, nil when volumes were not collected
+// Total - number of volumes of all tenants
+// GigabytesTotal - total size in GB of volumes of all tenants
+type CloudVolumes struct {
+	Total          *uint `json:"total"`
+	GigabytesTotal *int  `json:"gigabytes_total"`
+}
+
+// DefaultQuota holds cloud-wide default quotas (default quota class), nil when not collected
+// Volumes - default quota for number of volumes
+// Gigabytes - default quota for size in GB of volumes and snapshots
+type DefaultQuota struct {
+	Volumes   *int `json:"volumes"`
+	Gigabytes *int `json:"gigabytes"`
+}
+
+// QoSSpecs holds cloud-wide summary of QoS specs, nil when not collected
+// Count - number of QoS specs, 0 when cloud has none
+type QoSSpecs struct {
+	Count *uint `json:"count"`
+}
+
+// Pools holds cloud-wide summary of back-end storage pools, nil when not collected
+// Count - number of pools reported by scheduler
+type Pools struct {
+	Count *uint `json:"count"`
+}
+
+// ServiceHeartbeat holds last heartbeat of Cinder service
+// Host - host service runs on, back-end of volume service is part of it (eg. node1@lvm)
+// Binary - service binary (eg. cinder-scheduler, cinder-volume)
+// UpdatedAt - time service reported its state last time
+type ServiceHeartbeat struct {
+	Host      string
+	Binary    string
+	UpdatedAt time.Time
+}
+
+// Meta holds metrics of plugin itself, measured per collection
+// KeystoneLatencyMs - total time in milliseconds spent in identity calls (authentication, tenants listing)
+// CinderLatencyMs - total time in milliseconds spent in block storage calls (volumes, snapshots, limits)
+// APICalls - number of block storage requests per family
+// AllTenantsOK - 1 when admin scoped volumes listing returned volumes of enough distinct tenants, 0 otherwise,
+// nil when volumes were not listed with admin scope
+// PagesRetried - number of volumes listing pages which were listed again after failure
+// Goroutines - number of goroutines of plugin process, measured only when requested
+// HeapBytes - bytes of allocated heap objects of plugin process, measured only when requested
+// CatalogOK - 0 when Cinder service was missing in service catalog of any token authenticated in collection, 1 otherwise
+// TenantsVisibleOK - 1 when at least expected minimal number of tenants is visible, 0 otherwise
+// FailedTenantsPercent - percentage of requested tenants which any family failed to be collected in best-effort mode
+// APIErrorRate - fraction of block storage requests of all families which failed, including retried ones
+// KeystoneReachable, CinderReachable - 1 when endpoint responded to version discovery, 0 otherwise, nil when endpoint
+// was not probed
+type Meta struct {
+	KeystoneLatencyMs    float64  `json:"keystone_latency_ms"`
+	CinderLatencyMs      float64  `json:"cinder_latency_ms"`
+	APICalls             APICalls `json:"api_calls"`
+	AllTenantsOK         *int     `json:"all_tenants_ok"`
+	PagesRetried         uint     `json:"pages_retried"`
+	Goroutines           int      `json:"goroutines"`
+	HeapBytes            uint64   `json:"heap_bytes"`
+	CatalogOK            int      `json:"catalog_ok"`
+	TenantsVisibleOK     int      `json:"tenants_visible_ok"`
+	FailedTenantsPercent float64  `json:"failed_tenants_percent"`
+	APIErrorRate         float64  `json:"api_error_rate"`
+	KeystoneReachable    *int     `json:"keystone_reachable"`
+	CinderReachable      *int     `json:"cinder_reachable"`
+}
+
+// APICalls holds number of HTTP requests made to Cinder per family, including pagination pages and retries
+type APICalls struct {
+	Volumes      uint `json:"volumes"`
+	Snapshots    uint `json:"snapshots"`
+	Limits       uint `json:"limits"`
+	DefaultQuota uint `json:"default_quota"`
+	VolumeTypes  uint `json:"volume_types"`
+	VolumeGroups uint `json:"groups"`
+	QoSSpecs     uint `json:"qos_specs"`
+	Pools        uint `json:"pools"`
+	Services     uint `json:"services"`
+}
+
+// CloudSnapshots holds cloud-wide rollup of snapshots, nil when snapshots were not collected
+// Total - number of snapshots of all tenants
+type CloudSnapshots struct {
+	Total *uint `json:"total"`
+}
+
+// CloudSummary holds overview of cloud computed in single collection, families which were not requested in it are
+// counted as 0
+// Tenants - number of discovered tenants
+// Volumes, Snapshots - number of volumes and snapshots of all tenants
+// Gigabytes - total size in GB of volumes of all tenants
+// Errors - number of volumes of all tenants in any of error statuses
+// Collected - time collection finished, zero when no collection finished yet
+type CloudSummary struct {
+	Tenants   uint
+	Volumes   uint
+	Snapshots uint
+	Gigabytes int
+	Errors    uint
+	Collected time.Time
+}
@@ -0,0 +1,65 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import "strconv"
+
+// VolumeGroupFields are volume fields which volumes can be grouped by, size_bucket is derived from volume size
+var VolumeGroupFields = []string{"status", "volume_type", "availability_zone", "bootable", "size_bucket"}
+
+// SnapshotGroupFields are snapshot fields which snapshots can be grouped by
+var SnapshotGroupFields = []string{"status"}
+
+// Group holds aggregate of volumes or snapshots sharing value of group-by field
+// Count - number of volumes or snapshots
+// Gigabytes - total size in GB of volumes or snapshots
+type Group struct {
+	Count     uint `json:"count"`
+	Gigabytes int  `json:"gigabytes"`
+}
+
+// SizeBucket returns label of bucket which volume of given size in GB falls into, buckets are given by ascending
+// upper bounds (inclusive) in GB, eg. bounds 10, 100 give buckets 0-10, 10-100 and 100+
+func SizeBucket(size int, bounds []int) string {
+	lower := 0
+	for _, upper := range bounds {
+		if size <= upper {
+			return strconv.Itoa(lower) + "-" + strconv.Itoa(upper)
+		}
+		lower = upper
+	}
+	return strconv.Itoa(lower) + "+"
+}
+
+// Groups holds aggregates by group-by field and its value
+type Groups map[string]map[string]Group
+
+// Add aggregates item of given size under its value of each group-by field, items with empty value
+// are aggregated under UnsetMetadataValue
+func (g Groups) Add(fields []string, values map[string]string, size int) {
+	for _, field := range fields {
+		if g[field] == nil {
+			g[field] = map[string]Group{}
+		}
+		value := values[field]
+		if value == "" {
+			value = UnsetMetadataValue
+		}
+		group := g[field][value]
+		group.Count++
+		group.Gigabytes += size
+		g[field][value] = group
+	}
+}
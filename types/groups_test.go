@@ -0,0 +1,53 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSizeBucket(t *testing.T) {
+	Convey("Given upper bounds of volume size buckets", t, func() {
+		bounds := []int{10, 100, 1024}
+
+		Convey("When volumes of various sizes are bucketed", func() {
+			buckets := map[int]string{}
+			for _, size := range []int{0, 10, 11, 100, 1024, 1025} {
+				buckets[size] = SizeBucket(size, bounds)
+			}
+
+			Convey("Then each volume falls into bucket of first bound not smaller than its size", func() {
+				So(buckets, ShouldResemble, map[int]string{
+					0:    "0-10",
+					10:   "0-10",
+					11:   "10-100",
+					100:  "10-100",
+					1024: "100-1024",
+					1025: "1024+",
+				})
+			})
+		})
+
+		Convey("When no bounds are given", func() {
+			Convey("Then all volumes fall into single bucket", func() {
+				So(SizeBucket(5, nil), ShouldEqual, "0+")
+			})
+		})
+	})
+}
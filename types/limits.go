@@ -14,8 +14,66 @@ limitations under the License.
 
 package types
 
-// Limits represent cinder quota metrics
+// Limits represent cinder quota metrics, quota of -1 means unlimited
+// VolumesUsed, GigabytesUsed - usage of volumes quotas
+// Backups, BackupGigabytes - backups quotas and their usage, nil when not reported by Cinder
+// VolumesReserved, GigabytesReserved - usage of volumes quotas reserved by operations in progress, leaked reservations
+// block creating volumes despite apparent headroom; nil when not requested or not reported by Cinder
+// ReservedQueried - reserved usage was requested from quota usage, so nil reserved usage is not reported by Cinder
+// Groups, GroupsUsed, GroupsRemaining - generic volume groups quota, its usage and headroom (-1 when unlimited) from
+// quota usage; nil when not requested or not reported by Cinder
+// GroupsQueried - groups quota was requested from quota usage, so nil groups quota is not reported by Cinder
+// FromCache - 1 when limits were served from plugin cache, 0 when fetched in current collection
+// CacheAgeSeconds - seconds since limits were fetched, 0 when fetched in current collection
+// Changed - 1 when quotas fetched in current collection differ from previously fetched ones, 0 otherwise
 type Limits struct {
-	MaxTotalVolumeGigabytes int `json:"MaxTotalVolumeGigabytes"`
-	MaxTotalVolumes         int `json:"MaxTotalVolumes"`
+	MaxTotalVolumeGigabytes int  `json:"MaxTotalVolumeGigabytes"`
+	MaxTotalVolumes         int  `json:"MaxTotalVolumes"`
+	VolumesUsed             int  `json:"volumes_used"`
+	GigabytesUsed           int  `json:"gigabytes_used"`
+	Backups                 *int `json:"backups"`
+	BackupsUsed             *int `json:"backups_used"`
+	BackupGigabytes         *int `json:"backup_gigabytes"`
+	BackupGigabytesUsed     *int `json:"backup_gigabytes_used"`
+	VolumesReserved         *int `json:"volumes_reserved"`
+	GigabytesReserved       *int `json:"gigabytes_reserved"`
+	ReservedQueried         bool `json:"-"`
+	Groups                  *int `json:"groups"`
+	GroupsUsed              *int `json:"groups_used"`
+	GroupsRemaining         *int `json:"groups_remaining"`
+	GroupsQueried           bool `json:"-"`
+	FromCache               int  `json:"from_cache"`
+	CacheAgeSeconds         int  `json:"cache_age_seconds"`
+	Changed                 int  `json:"changed"`
+}
+
+// TypeLimits represent cinder quota of given volume type
+// Gigabytes - quota for size in GB of volumes and snapshots of given type
+// GigabytesUsed - size in GB of volumes and snapshots of given type in use
+type TypeLimits struct {
+	Gigabytes     int `json:"gigabytes"`
+	GigabytesUsed int `json:"gigabytes_used"`
+}
+
+// OverQuota checks if usage reached volumes or gigabytes quota, unlimited quotas are never reached
+func (l Limits) OverQuota() bool {
+	if l.MaxTotalVolumes >= 0 && l.VolumesUsed >= l.MaxTotalVolumes {
+		return true
+	}
+	return l.MaxTotalVolumeGigabytes >= 0 && l.GigabytesUsed >= l.MaxTotalVolumeGigabytes
+}
+
+// QuotasEqual checks if quotas (not their usage) are the same as other ones, quotas not reported by Cinder are
+// equal only to other not reported ones
+func (l Limits) QuotasEqual(other Limits) bool {
+	optionalEqual := func(a, b *int) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return *a == *b
+	}
+	return l.MaxTotalVolumeGigabytes == other.MaxTotalVolumeGigabytes &&
+		l.MaxTotalVolumes == other.MaxTotalVolumes &&
+		optionalEqual(l.Backups, other.Backups) &&
+		optionalEqual(l.BackupGigabytes, other.BackupGigabytes)
 }
@@ -0,0 +1,59 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimitsQuotasEqual(t *testing.T) {
+	Convey("Given limits of tenant", t, func() {
+		backups := 10
+		limits := Limits{MaxTotalVolumes: 10, MaxTotalVolumeGigabytes: 1000, VolumesUsed: 2, Backups: &backups}
+
+		Convey("When only usage differs", func() {
+			other := limits
+			other.VolumesUsed = 5
+			other.Backups = new(int)
+			*other.Backups = 10
+
+			Convey("Then quotas are equal", func() {
+				So(limits.QuotasEqual(other), ShouldBeTrue)
+			})
+		})
+
+		Convey("When quota differs", func() {
+			other := limits
+			other.MaxTotalVolumeGigabytes = 2000
+
+			Convey("Then quotas are not equal", func() {
+				So(limits.QuotasEqual(other), ShouldBeFalse)
+			})
+		})
+
+		Convey("When optional quota is no longer reported", func() {
+			other := limits
+			other.Backups = nil
+
+			Convey("Then quotas are not equal", func() {
+				So(limits.QuotasEqual(other), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,240 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import "time"
+
+// ListOptions holds optional parameters for volumes and snapshots listing and aggregation
+// AllTenants - list volumes and snapshots of all tenants (all_tenants filter), requires admin role in scoped project,
+// otherwise only those of scoped project are listed
+// ProjectID - limits listing of all tenants to volumes and snapshots of given project ID, empty means all projects
+// Snapshots - index of already known snapshots used for incremental listing, nil means full listing
+// ManagedMetadataKey - metadata key marking volumes imported to Cinder by manage operation
+// VolumeStatus - limits volumes listing to given status, empty means all volumes
+// VolumeIDs - set of existing volumes filled by volumes listing and used for counting orphaned snapshots,
+// volumes have to be listed before snapshots, nil disables orphaned snapshots counting
+// VolumeHosts - back-end host by volume ID filled by volumes listing, volumes not exposing host (admin only attribute)
+// are skipped, volumes have to be listed before snapshots, nil disables counting snapshots by host
+// SnapshotHostCounts - snapshot counts by back-end host of their source volume filled by snapshots listing, hosts
+// of all volumes in VolumeHosts are included, required when VolumeHosts is set
+// ExtraVolumeFields - numeric volume payload fields (dot separated path for nested ones) summed into named metrics
+// ExtraVolumeValues - sums of extra volume fields by tenant ID and metric name filled by volumes listing,
+// required when ExtraVolumeFields are set
+// SnapshotMetadataKeys - snapshot metadata keys which values snapshots are counted by
+// SnapshotMetadataCounts - snapshot counts by tenant ID, metadata key and value filled by snapshots listing,
+// required when SnapshotMetadataKeys are set
+// GroupVolumesBy - volume fields (one of VolumeGroupFields) which volumes are grouped by
+// VolumeGroups - volume groups by tenant ID filled by volumes listing, required when GroupVolumesBy are set
+// SizeBuckets - ascending upper bounds in GB of volume size buckets, used when volumes are grouped by size_bucket
+// GroupSnapshotsBy - snapshot fields (one of SnapshotGroupFields) which snapshots are grouped by
+// SnapshotGroups - snapshot groups by tenant ID filled by snapshots listing, required when GroupSnapshotsBy are set
+// PageSize - number of volumes and snapshots listed per page, those are listed in single request when not positive
+// RetryPage - repeats failed listing of single page of volumes or snapshots, so items of pages already listed are kept,
+// page is listed once when not set
+// IncludeDeleted - additionally list deleted volumes and snapshots retained by Cinder (deleted filter, requires
+// admin role) and count them separately as deleted, they are never counted into other metrics
+type ListOptions struct {
+	AllTenants         bool
+	ProjectID          string
+	Snapshots          *SnapshotIndex
+	ManagedMetadataKey string
+	VolumeStatus       string
+	VolumeIDs          map[string]bool
+	VolumeHosts        map[string]string
+	SnapshotHostCounts map[string]uint
+	ExtraVolumeFields  map[string]string
+	ExtraVolumeValues  map[string]map[string]float64
+
+	SnapshotMetadataKeys   []string
+	SnapshotMetadataCounts map[string]map[string]map[string]uint
+
+	GroupVolumesBy   []string
+	VolumeGroups     map[string]Groups
+	SizeBuckets      []int
+	GroupSnapshotsBy []string
+	SnapshotGroups   map[string]Groups
+
+	PageSize  int
+	RetryPage func(list func() error) error
+
+	IncludeDeleted bool
+}
+
+// LimitsOptions holds optional parameters for limits collection
+// VolumeTypes - volume types which quotas are collected from quota usage, empty means quota usage is not queried
+// ByType - quotas by volume type filled by limits collection, types absent from quota usage are skipped,
+// required when VolumeTypes are set
+// Reserved - collect reserved usage of volumes quotas from quota usage
+// Groups - collect generic volume groups quota and its usage from quota usage
+// Conditional - validator of cached limits, limits are fetched conditionally when it is set and quota usage is not
+// queried; nil means limits are fetched unconditionally
+// TenantID - tenant which limits are read from quota usage of, so limits of any tenant are collected with single
+// admin scoped token (requires admin role), Conditional is not used then; empty means limits of scoped tenant
+type LimitsOptions struct {
+	VolumeTypes []string
+	ByType      map[string]TypeLimits
+	Reserved    bool
+	Groups      bool
+	Conditional *ConditionalGet
+	TenantID    string
+}
+
+// ConditionalGet holds validator of conditional request
+// ETag - ETag of cached response sent as If-None-Match, empty means request is unconditional; replaced by collection
+// with ETag of response, empty when Cinder does not report it
+// NotModified - set by collection when Cinder reports response unchanged since given ETag, nothing is returned then
+type ConditionalGet struct {
+	ETag        string
+	NotModified bool
+}
+
+// ExtraSpecsOptions holds optional parameters for volume types extra specs collection
+// Known - extra specs by volume type ID known from previous collection, those are not requested again; nil means
+// extra specs of all volume types are requested
+type ExtraSpecsOptions struct {
+	Known map[string]map[string]string
+}
+
+// VolumeGroupsOptions holds optional parameters for generic volume groups collection
+// ByStatus - generic volume groups grouped by status filled by groups collection, required
+type VolumeGroupsOptions struct {
+	ByStatus Groups
+}
+
+// QoSSpecsOptions holds optional parameters for QoS specs collection
+// Associations - number of associations (volume types) by QoS spec name filled by QoS specs collection, required
+type QoSSpecsOptions struct {
+	Associations map[string]uint
+}
+
+// PoolsOptions holds optional parameters for back-end storage pools collection
+// OvercommitRatio - ratio of provisioned to total capacity by pool name filled by pools collection, pools not
+// reporting provisioned or total capacity are skipped, required
+type PoolsOptions struct {
+	OvercommitRatio map[string]float64
+}
+
+// SnapshotIndex keeps last known state of snapshots between incremental listings
+// Since - time of last listing, zero value forces full listing
+// Resynced - time of last full listing
+// Items - known snapshots keyed by snapshot ID
+// Unsupported - Cinder rejected changes-since filter, snapshots are listed fully without trying it again
+type SnapshotIndex struct {
+	Since       time.Time
+	Resynced    time.Time
+	Items       map[string]SnapshotEntry
+	Unsupported bool
+}
+
+// SnapshotEntry represents last known state of single snapshot
+type SnapshotEntry struct {
+	TenantID string
+	VolumeID string
+	Size     int
+	Status   string
+	Metadata map[string]string
+}
+
+// UnsetMetadataValue is value which snapshots not carrying given metadata key are counted by
+const UnsetMetadataValue = "unset"
+
+// NewSnapshotIndex creates empty snapshot index, first listing using it is always full
+func NewSnapshotIndex() *SnapshotIndex {
+	return &SnapshotIndex{Items: map[string]SnapshotEntry{}}
+}
+
+// Aggregate sums known snapshots per tenant, snapshots which source volume is not in volumeIDs are counted as orphaned,
+// snapshots of volumes in volumeIDs are counted per volume to find highest number of snapshots of single volume
+// Orphaned count and maximum per volume are -1 when volumeIDs is nil
+func (idx *SnapshotIndex) Aggregate(volumeIDs map[string]bool) map[string]Snapshots {
+	snaps := map[string]Snapshots{}
+	perVolume := map[string]int{}
+	for _, entry := range idx.Items {
+		snapCounts, found := snaps[entry.TenantID]
+		if !found && volumeIDs == nil {
+			snapCounts.Orphaned = -1
+			snapCounts.MaxPerVolume = -1
+		}
+		snapCounts.Count += 1
+		snapCounts.Bytes += entry.Size * 1024 * 1024 * 1024
+		if volumeIDs != nil && !volumeIDs[entry.VolumeID] {
+			snapCounts.Orphaned += 1
+		}
+		if volumeIDs != nil && volumeIDs[entry.VolumeID] {
+			perVolume[entry.VolumeID] += 1
+			if perVolume[entry.VolumeID] > snapCounts.MaxPerVolume {
+				snapCounts.MaxPerVolume = perVolume[entry.VolumeID]
+			}
+		}
+		if entry.Status == "creating" {
+			snapCounts.Creating += 1
+		}
+		snaps[entry.TenantID] = snapCounts
+	}
+	return snaps
+}
+
+// CountByMetadata counts snapshots by tenant ID, metadata key and its value, snapshots not carrying
+// given key are counted under UnsetMetadataValue
+func (idx *SnapshotIndex) CountByMetadata(keys []string) map[string]map[string]map[string]uint {
+	counts := map[string]map[string]map[string]uint{}
+	for _, entry := range idx.Items {
+		tenantCounts, found := counts[entry.TenantID]
+		if !found {
+			tenantCounts = map[string]map[string]uint{}
+			counts[entry.TenantID] = tenantCounts
+		}
+		for _, key := range keys {
+			if tenantCounts[key] == nil {
+				tenantCounts[key] = map[string]uint{}
+			}
+			value, found := entry.Metadata[key]
+			if !found {
+				value = UnsetMetadataValue
+			}
+			tenantCounts[key][value]++
+		}
+	}
+	return counts
+}
+
+// CountByHost counts known snapshots by back-end host of their source volume, hosts without snapshots are counted
+// with 0 and snapshots of volumes of unknown host are skipped
+func (idx *SnapshotIndex) CountByHost(volumeHosts map[string]string) map[string]uint {
+	counts := map[string]uint{}
+	for _, host := range volumeHosts {
+		counts[host] = 0
+	}
+	for _, entry := range idx.Items {
+		if host, found := volumeHosts[entry.VolumeID]; found {
+			counts[host]++
+		}
+	}
+	return counts
+}
+
+// Group aggregates known snapshots by tenant ID, group-by field and its value
+func (idx *SnapshotIndex) Group(fields []string) map[string]Groups {
+	groups := map[string]Groups{}
+	for _, entry := range idx.Items {
+		tenantGroups, found := groups[entry.TenantID]
+		if !found {
+			tenantGroups = Groups{}
+			groups[entry.TenantID] = tenantGroups
+		}
+		tenantGroups.Add(fields, map[string]string{"status": entry.Status}, entry.Size)
+	}
+	return groups
+}
@@ -0,0 +1,137 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSnapshotIndexAggregate(t *testing.T) {
+	Convey("Given snapshot index", t, func() {
+		idx := NewSnapshotIndex()
+		idx.Items["s1"] = SnapshotEntry{TenantID: "t1", VolumeID: "v1", Size: 1, Status: "available"}
+		idx.Items["s2"] = SnapshotEntry{TenantID: "t1", VolumeID: "v2", Size: 2, Status: "creating"}
+		idx.Items["s3"] = SnapshotEntry{TenantID: "t2", VolumeID: "v3", Size: 3, Status: "available"}
+
+		Convey("When it is aggregated with set of existing volumes", func() {
+			snaps := idx.Aggregate(map[string]bool{"v1": true, "v3": true})
+
+			Convey("Then snapshots are summed per tenant", func() {
+				So(snaps["t1"].Count, ShouldEqual, 2)
+				So(snaps["t1"].Bytes, ShouldEqual, 3*1024*1024*1024)
+				So(snaps["t2"].Count, ShouldEqual, 1)
+			})
+
+			Convey("and snapshots of missing volumes are counted as orphaned", func() {
+				So(snaps["t1"].Orphaned, ShouldEqual, 1)
+				So(snaps["t2"].Orphaned, ShouldEqual, 0)
+			})
+
+			Convey("and snapshots being created are counted", func() {
+				So(snaps["t1"].Creating, ShouldEqual, 1)
+				So(snaps["t2"].Creating, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When volume has several snapshots", func() {
+			idx.Items["s4"] = SnapshotEntry{TenantID: "t1", VolumeID: "v1", Size: 1, Status: "available"}
+			idx.Items["s5"] = SnapshotEntry{TenantID: "t1", VolumeID: "v2", Size: 1, Status: "available"}
+			idx.Items["s6"] = SnapshotEntry{TenantID: "t1", VolumeID: "v2", Size: 1, Status: "available"}
+			snaps := idx.Aggregate(map[string]bool{"v1": true, "v3": true})
+
+			Convey("Then highest number of snapshots of single existing volume is found", func() {
+				So(snaps["t1"].MaxPerVolume, ShouldEqual, 2)
+				So(snaps["t2"].MaxPerVolume, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When snapshots are counted by metadata", func() {
+			idx.Items["s1"] = SnapshotEntry{TenantID: "t1", Metadata: map[string]string{"backup": "forced"}}
+			counts := idx.CountByMetadata([]string{"backup"})
+
+			Convey("Then snapshots are counted by value with missing keys bucketed as unset", func() {
+				So(counts["t1"]["backup"]["forced"], ShouldEqual, 1)
+				So(counts["t1"]["backup"][UnsetMetadataValue], ShouldEqual, 1)
+				So(counts["t2"]["backup"][UnsetMetadataValue], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When it is aggregated without volumes", func() {
+			snaps := idx.Aggregate(nil)
+
+			Convey("Then orphaned snapshots are not counted", func() {
+				So(snaps["t1"].Orphaned, ShouldEqual, -1)
+				So(snaps["t2"].Orphaned, ShouldEqual, -1)
+				So(snaps["t1"].MaxPerVolume, ShouldEqual, -1)
+			})
+		})
+	})
+}
+
+func TestSnapshotIndexGroup(t *testing.T) {
+	Convey("Given snapshot index", t, func() {
+		idx := NewSnapshotIndex()
+		idx.Items["s1"] = SnapshotEntry{TenantID: "t1", Size: 1, Status: "available"}
+		idx.Items["s2"] = SnapshotEntry{TenantID: "t1", Size: 2, Status: "available"}
+		idx.Items["s3"] = SnapshotEntry{TenantID: "t1", Size: 3, Status: ""}
+		idx.Items["s4"] = SnapshotEntry{TenantID: "t2", Size: 4, Status: "error"}
+
+		Convey("When it is grouped by status", func() {
+			groups := idx.Group([]string{"status"})
+
+			Convey("Then snapshots are aggregated per tenant and status", func() {
+				So(groups["t1"]["status"]["available"], ShouldResemble, Group{Count: 2, Gigabytes: 3})
+				So(groups["t2"]["status"]["error"], ShouldResemble, Group{Count: 1, Gigabytes: 4})
+			})
+
+			Convey("and snapshots without status are aggregated as unset", func() {
+				So(groups["t1"]["status"][UnsetMetadataValue], ShouldResemble, Group{Count: 1, Gigabytes: 3})
+			})
+		})
+	})
+}
+
+func TestGroupsAdd(t *testing.T) {
+	Convey("Given empty groups", t, func() {
+		groups := Groups{}
+
+		Convey("When items are added with several group-by fields", func() {
+			fields := []string{"status", "volume_type"}
+			groups.Add(fields, map[string]string{"status": "available", "volume_type": "ssd"}, 10)
+			groups.Add(fields, map[string]string{"status": "available", "volume_type": "hdd"}, 20)
+			groups.Add(fields, map[string]string{"status": "in-use", "volume_type": "ssd", "bootable": "true"}, 30)
+
+			Convey("Then each item is aggregated under each field", func() {
+				So(groups["status"], ShouldResemble, map[string]Group{
+					"available": {Count: 2, Gigabytes: 30},
+					"in-use":    {Count: 1, Gigabytes: 30},
+				})
+				So(groups["volume_type"], ShouldResemble, map[string]Group{
+					"ssd": {Count: 2, Gigabytes: 40},
+					"hdd": {Count: 1, Gigabytes: 20},
+				})
+			})
+
+			Convey("and fields not grouped by are ignored", func() {
+				_, found := groups["bootable"]
+				So(found, ShouldBeFalse)
+			})
+		})
+	})
+}
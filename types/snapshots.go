@@ -17,7 +17,18 @@ package types
 // Snapshots represents cinder volumes snapshots metric
 // Count - total number of snapshots counted
 // Bytes - total number of bytes counted
+// Orphaned - number of snapshots which source volume no longer exists, -1 when volumes were not collected
+// Creating - number of snapshots being created (creating status)
+// MaxPerVolume - highest number of snapshots of single existing volume, -1 when volumes were not collected
+// DeltaAbs - absolute change of Count since previous collection, set by collector, 0 in first collection
+// Deleted - number of deleted snapshots still retained by Cinder, not included in Count, 0 unless deleted snapshots
+// are listed (requires admin role)
 type Snapshots struct {
-	Count uint `json:"count"`
-	Bytes int  `json:"bytes"`
+	Count        uint `json:"count"`
+	Bytes        int  `json:"bytes"`
+	Orphaned     int  `json:"orphaned"`
+	Creating     uint `json:"creating"`
+	MaxPerVolume int  `json:"max_snapshots_per_volume"`
+	DeltaAbs     uint `json:"delta_abs"`
+	Deleted      uint `json:"deleted"`
 }
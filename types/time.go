@@ -0,0 +1,38 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// cinderTimeLayouts lists timestamp formats emitted by Cinder, fraction of second is optional in each of them
+var cinderTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// ParseCinderTime parses Cinder timestamp (ex. created_at, updated_at), like 2016-01-02T15:04:05.000000
+// Timestamps without timezone are in UTC
+func ParseCinderTime(value string) (time.Time, error) {
+	for _, layout := range cinderTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown Cinder time format: %q", value)
+}
@@ -0,0 +1,53 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseCinderTime(t *testing.T) {
+	Convey("Given timestamps in formats emitted by Cinder", t, func() {
+		expected := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+
+		Convey("Then all of them are parsed as UTC time", func() {
+			for value, want := range map[string]time.Time{
+				"2016-01-02T15:04:05.000000":       expected,
+				"2016-01-02T15:04:05.123456":       expected.Add(123456 * time.Microsecond),
+				"2016-01-02T15:04:05":              expected,
+				"2016-01-02 15:04:05.000000":       expected,
+				"2016-01-02T15:04:05Z":             expected,
+				"2016-01-02T15:04:05.000000+00:00": expected,
+				"2016-01-02T17:04:05+02:00":        expected,
+			} {
+				parsed, err := ParseCinderTime(value)
+				So(err, ShouldBeNil)
+				So(parsed, ShouldEqual, want)
+			}
+		})
+
+		Convey("and unknown format is reported as error", func() {
+			_, err := ParseCinderTime("02/01/2016")
+			So(err, ShouldNotBeNil)
+			_, err = ParseCinderTime("")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,26 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+// VolumeGroups represents cinder generic volume groups metric, fields are nil when Cinder does not support
+// generic volume groups
+// Count - number of generic volume groups of tenant
+// Quota - quota for number of generic volume groups, nil when not reported by quota set
+// QuotaInUse - number of generic volume groups counted against quota, nil when not reported by quota set
+type VolumeGroups struct {
+	Count      *uint `json:"count"`
+	Quota      *int  `json:"quota"`
+	QuotaInUse *int  `json:"quota_in_use"`
+}
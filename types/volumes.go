@@ -17,7 +17,44 @@ package types
 // Volumes represents cinder volumes metric
 // Count - total number of volumes counted
 // Bytes - total number of bytes counted
+// InUseGB - total size in GB of volumes attached to instances (in-use status)
+// AvailableGB - total size in GB of volumes ready to be attached (available status)
+// Managed - number of volumes imported from backend by manage operation
+// Deleting - number of volumes being deleted (deleting status), stuck deletions keep consuming backend capacity
+// AvgSizeGB - average size in GB of volumes, 0 when there are no volumes
+// InconsistentAttachment - number of volumes in in-use status without any attachment, Cinder and Nova state drifted apart
+// Migrating - number of volumes with migration status other than success, in progress or stuck migrations,
+// 0 when migration status is not visible (requires admin role)
+// Multiattach - number of volumes attached to more than one instance
+// AttachmentsTotal - total number of attachments of volumes
+// DistinctHosts - number of distinct back-end hosts (host@backend, without pool) volumes are placed on,
+// 0 when host is not visible (requires admin role)
+// DeltaAbs - absolute change of Count since previous collection, set by collector, 0 in first collection
+// Deleted - number of deleted volumes still retained by Cinder, not included in Count, 0 unless deleted volumes are
+// listed (requires admin role)
+// ErrorPercent - percentage of volumes in any of error statuses (error, error_deleting etc.), 0 when there are no volumes
+// Errors - number of volumes in any of error statuses, kept for cloud summary and not emitted
+// EncryptedGB, UnencryptedGB - total size in GB of volumes reported as encrypted and not encrypted
+// UnknownEncryptionGB - total size in GB of volumes not reporting whether they are encrypted
 type Volumes struct {
-	Count uint `json:"count"`
-	Bytes int  `json:"bytes"`
+	Count       uint    `json:"count"`
+	Bytes       int     `json:"bytes"`
+	InUseGB     int     `json:"inuse_gb"`
+	AvailableGB int     `json:"available_gb"`
+	Managed     uint    `json:"managed"`
+	Deleting    uint    `json:"deleting"`
+	AvgSizeGB   float64 `json:"avg_size_gb"`
+
+	InconsistentAttachment uint    `json:"inconsistent_attachment"`
+	ErrorPercent           float64 `json:"error_percent"`
+	Errors                 uint    `json:"-"`
+	Migrating              uint    `json:"migrating"`
+	Multiattach            uint    `json:"multiattach"`
+	AttachmentsTotal       uint    `json:"attachments_total"`
+	DistinctHosts          uint    `json:"distinct_hosts"`
+	DeltaAbs               uint    `json:"delta_abs"`
+	Deleted                uint    `json:"deleted"`
+	EncryptedGB            int     `json:"encrypted_gb"`
+	UnencryptedGB          int     `json:"unencrypted_gb"`
+	UnknownEncryptionGB    int     `json:"unknown_encryption_gb"`
 }